@@ -1,6 +1,7 @@
 package api
 
 import(
+	"context"
 	"bytes"
 	"crypto/hmac"
 	"crypto/sha256"
@@ -12,9 +13,11 @@ import(
 	"net/url"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 	"github.com/niklaskunkel/market-maker/logger"
 	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
 
@@ -22,6 +25,7 @@ import(
 const (
 	APIHostUrl = "https://api.gatecoin.com"
 	APIUserAgent = "MakerDAO Market-Maker"
+	DefaultRequestTimeout = 30 * time.Second	//Bounds every API call including its retries unless the caller's context ends sooner
 )
 
 //Globals
@@ -44,26 +48,68 @@ type GatecoinClient struct {
 	Name	string 			//Name of client
 	key 	string			//Gatecoin API Key
 	secret 	string			//Gatecoin Secret Key
+	host 	string			//Gatecoin API host, defaults to APIHostUrl
 	client 	*http.Client 	
+	limiter	*RateLimiter	//Request budgets, defaults to the registry's
+	retry	RetryPolicy		//Retries of transient failures, defaults to DefaultRetryPolicy
+	timeout	time.Duration	//Deadline of each call, defaults to DefaultRequestTimeout
+	mutex	sync.Mutex
+	known	map[string]bool	//Ids of orders open at the last GetOrders or created since, nil before the first GetOrders
 }
 
 func NewGatecoinClient(name, key, secret string) (*GatecoinClient) {
 	client := &http.Client{}
-	return &GatecoinClient{strings.ToUpper(name), key, secret, client}
+	return &GatecoinClient{Name: strings.ToUpper(name), key: key, secret: secret, host: APIHostUrl, client: client, limiter: NewRateLimiter(registry.LookupExchangeRateLimits("GATECOIN")), retry: DefaultRetryPolicy, timeout: DefaultRequestTimeout}
+}
+
+//Points the client at a different API host, e.g. a local emulator
+func (gatecoin *GatecoinClient) SetHost(host string) {
+	gatecoin.host = strings.TrimRight(host, "/")
+}
+
+//Replaces the request budgets of the client, resetting its wait metrics
+func (gatecoin *GatecoinClient) SetRateLimits(limits registry.RateLimits) {
+	gatecoin.limiter = NewRateLimiter(limits)
+}
+
+func (gatecoin *GatecoinClient) SetRetryPolicy(policy RetryPolicy) {
+	gatecoin.retry = policy
+}
+
+//Sets the default deadline of each call, zero leaves calls bounded by their context only
+func (gatecoin *GatecoinClient) SetTimeout(timeout time.Duration) {
+	gatecoin.timeout = timeout
+}
+
+//Returns how long requests waited for their budget, keyed by endpoint
+func (gatecoin *GatecoinClient) RateLimitMetrics() (map[string]WaitMetrics) {
+	return gatecoin.limiter.Metrics()
+}
+
+func (gatecoin *GatecoinClient) GetName() (string) {
+	return gatecoin.Name
+}
+
+func (gatecoin *GatecoinClient) GetTokenPairName(pair string) (string) {
+	return registry.LookupGatecoinTokenPairName(pair)
+}
+
+func (gatecoin *GatecoinClient) GetTokenPairPrecision(pair string) (registry.Precision) {
+	return registry.LookupGatecoinTokenPairPrecision(pair)
 }
 
 /////////////////////////////////////////////////////////////////////////
 //                          REQUEST CONSTRUCTION                       //
 /////////////////////////////////////////////////////////////////////////
-func (gatecoin *GatecoinClient) queryPublic(params []string, typ interface{}) (interface{}, error) {
+func (gatecoin *GatecoinClient) queryPublic(ctx context.Context, params []string, typ interface{}) (interface{}, error) {
 	//check if valid command
 	cmd := params[0]
 	if !IsStringInSlice(cmd, publicMethods) {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "queryPublic", "Command": cmd}).Error("Command is not in supported Public Commands list")
-		return nil, fmt.Errorf("Unsupported Public Method")
+		return nil, fmt.Errorf("Unsupported Public Method %s", cmd)
 	}
 	//format request URL w/ path and URL parameters
-	reqURL, _ := url.Parse(APIHostUrl)
+	reqURL, _ := url.Parse(gatecoin.host)
 	reqURL.Path = "/Public"
 	for _, param := range params {
 		reqURL.Path += "/" + param
@@ -72,28 +118,30 @@ func (gatecoin *GatecoinClient) queryPublic(params []string, typ interface{}) (i
 	//set type of request
 	requestType := "GET"
 
-	//wait until api call limit interval has been exceeded
-	timeout := time.Duration(registry.GetExchangeApiPublicTimeout("gatecoin")) * time.Millisecond
-	time.Sleep(timeout)
-	log.WithFields(logrus.Fields{"client": "Gatecoin", "interval": timeout}).Debug("Sleeping until public API Timeout reset")
-
-	//record time in registry
-	registry.SetExchangeApiPublicTimeout("gatecoin")
+	ctx, cancel := withTimeout(ctx, gatecoin.timeout)
+	defer cancel()
+	return gatecoin.retry.Do(ctx, "Gatecoin", cmd, true, func() (interface{}, error) {
+		//wait until the request fits the public budget
+		wait, err := gatecoin.limiter.Wait(ctx, false, cmd)
+		log.WithFields(logrus.Fields{"client": "Gatecoin", "endpoint": cmd, "wait": wait}).Debug("Waited for public API budget")
+		if err != nil {
+			return nil, &RequestError{ErrorNetwork, cmd, false, err}
+		}
 
-	resp, err := gatecoin.doRequest(reqURL, requestType, nil, []byte{}, typ)
-	return resp, err
+		return gatecoin.doRequest(ctx, cmd, reqURL, requestType, nil, []byte{}, typ)
+	})
 }
 
-func (gatecoin *GatecoinClient) queryPrivate(requestType string, params []string, data []byte, responseType interface{}) (interface{}, error) {
+func (gatecoin *GatecoinClient) queryPrivate(ctx context.Context, requestType string, params []string, data []byte, responseType interface{}) (interface{}, error) {
 	cmd := params[0]
 	//check if valid command
 	if !IsStringInSlice(cmd, privateMethods) {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "queryPrivate", "Command": cmd}).Error("Command is not in supported Private Commands list")
-		return nil, fmt.Errorf("Unsupported Private Method.", cmd)
+		return nil, fmt.Errorf("Unsupported Private Method %s", cmd)
 	}
 
 	//Set url for request
-	reqURL, _ := url.Parse(APIHostUrl)
+	reqURL, _ := url.Parse(gatecoin.host)
 	for _, param := range params {
 		if param != "" {
 			reqURL.Path += "/" + param
@@ -106,40 +154,45 @@ func (gatecoin *GatecoinClient) queryPrivate(requestType string, params []string
 		contentType = "application/json"
 	}
 
-	//set nonce
-	nonce := strconv.FormatInt(time.Now().Unix(), 10) + ".000"
-
-	//construct message
-	msg := fmt.Sprintf("%s%s%s%s",requestType, reqURL, contentType, nonce)
+	//orders must not be placed twice, the other methods are safe to repeat
+	idempotent := requestType != "POST"
+	ctx, cancel := withTimeout(ctx, gatecoin.timeout)
+	defer cancel()
+	return gatecoin.retry.Do(ctx, "Gatecoin", cmd, idempotent, func() (interface{}, error) {
+		//wait until the request fits the private budget
+		wait, err := gatecoin.limiter.Wait(ctx, true, cmd)
+		log.WithFields(logrus.Fields{"client": "Gatecoin", "endpoint": cmd, "wait": wait}).Debug("Waited for private API budget")
+		if err != nil {
+			return nil, &RequestError{ErrorNetwork, cmd, false, err}
+		}
 
-	//Create signature using secret
-	signature := createSignature(msg, gatecoin.secret)
+		//set nonce, every attempt is signed anew
+		nonce := strconv.FormatInt(time.Now().Unix(), 10) + ".000"
 
-	//Add api key and encrypted signature to headers
-	headers := map[string]string {
-		"API_PUBLIC_KEY": gatecoin.key,
-		"API_REQUEST_SIGNATURE": signature,
-		"API_REQUEST_DATE": nonce,
-	}
+		//construct message
+		msg := fmt.Sprintf("%s%s%s%s",requestType, reqURL, contentType, nonce)
 
-	//wait until api call limit interval has been exceeded
-	timeout := time.Duration(registry.GetExchangeApiPrivateTimeout("gatecoin")) * time.Millisecond
-	time.Sleep(timeout)
-	log.WithFields(logrus.Fields{"client": "Gatecoin", "interval": timeout}).Debug("Sleeping until private API timeout reset")
+		//Create signature using secret
+		signature := createSignature(msg, gatecoin.secret)
 
-	//record time in registry
-	registry.SetExchangeApiPrivateTimeout("Gatecoin")
+		//Add api key and encrypted signature to headers
+		headers := map[string]string {
+			"API_PUBLIC_KEY": gatecoin.key,
+			"API_REQUEST_SIGNATURE": signature,
+			"API_REQUEST_DATE": nonce,
+		}
 
-	resp, err := gatecoin.doRequest(reqURL, requestType, headers, data, responseType)
-	return resp, err
+		return gatecoin.doRequest(ctx, cmd, reqURL, requestType, headers, data, responseType)
+	})
 }
 
-func (gatecoin *GatecoinClient) doRequest(reqURL *url.URL, requestType string, headers map[string]string, data []byte, responseType interface{}) (interface{}, error) {
+//Executes a request, failures are returned as *RequestError
+func (gatecoin *GatecoinClient) doRequest(ctx context.Context, cmd string, reqURL *url.URL, requestType string, headers map[string]string, data []byte, responseType interface{}) (interface{}, error) {
 	//Create request
-	req, err := http.NewRequest(requestType, reqURL.String(), bytes.NewReader(data))
+	req, err := http.NewRequestWithContext(ctx, requestType, reqURL.String(), bytes.NewReader(data))
 	if err != nil {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequest", "requestType": requestType, "requestURL": reqURL.String(), "data": data, "error": err.Error()}).Error("Failed to create new request")
-		return nil, err
+		return nil, &RequestError{ErrorUnknown, cmd, false, err}
 	}
 
 	//Add headers to request
@@ -158,7 +211,7 @@ func (gatecoin *GatecoinClient) doRequest(reqURL *url.URL, requestType string, h
 	resp, err := gatecoin.client.Do(req)
 	if err != nil {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequest", "requestType": requestType, "requestURL": reqURL.String(), "data": data, "request": req, "error": err.Error()}).Error("Failed to execute request")
-		return nil, err
+		return nil, networkError(cmd, err)
 	}
 	defer resp.Body.Close()
 
@@ -171,35 +224,60 @@ func (gatecoin *GatecoinClient) doRequest(reqURL *url.URL, requestType string, h
 	//Check if parsing response failed
 	if err != nil {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequest", "body": resp.Body, "request": req, "error": err.Error()}).Error("Failed to parse response for query")
-		return nil, err
+		return nil, &RequestError{ErrorNetwork, cmd, true, err}
 	}
 
 	//Convert JSON to ErrorResponse struct to check if API returned error
 	apiError := ErrorResponse{}
 	err = json.Unmarshal(body, &apiError)
-	if err != nil {
+	if err != nil && resp.StatusCode < http.StatusBadRequest {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequest", "body": string(body), "request": req, "error": err.Error()}).Error("Failed to convert JSON response into error struct")
-		return nil, err
+		return nil, &RequestError{ErrorUnknown, cmd, true, err}
 	}
-	if apiError.Status.Message != "OK" {
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequest", "errorCode": apiError.Status.ErrorCode}).Error(apiError.Status.Message)
-		return nil, fmt.Errorf(apiError.Status.Message)
+	if err != nil || apiError.Status.Message != "OK" {
+		requestError := classifyGatecoinError(cmd, resp.StatusCode, apiError.Status)
+		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequest", "httpStatus": resp.StatusCode, "errorCode": apiError.Status.ErrorCode, "class": requestError.Class}).Error(requestError.Err.Error())
+		return nil, requestError
 	}
 
 	//Convert JSON to Response struct
 	err = json.Unmarshal(body, &responseType)
 	if err != nil {
 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "doRequestuest", "body": string(body), "request": req, "error": err.Error()}).Error("Failed to convert JSON response into struct")
-		return nil, err
+		return nil, &RequestError{ErrorUnknown, cmd, true, err}
 	}
 	return responseType, nil
 }
+
+//Emulator error codes with a known class, other errors are classified by HTTP status and message
+var gatecoinErrorClasses = map[string]ErrorClass{
+	GatecoinErrorAuth: ErrorAuth,
+	GatecoinErrorNotFound: ErrorNotFound,
+	GatecoinErrorInvalidOrder: ErrorInvalidOrder,
+	GatecoinErrorInvalidPrice: ErrorInvalidPrice,
+	GatecoinErrorInvalidAmount: ErrorInvalidAmount,
+	GatecoinErrorInsufficientFunds: ErrorInsufficientFunds,
+	GatecoinErrorRateLimited: ErrorRateLimited,
+}
+
+func classifyGatecoinError(cmd string, httpStatus int, status ResponseStatus) (*RequestError) {
+	message := status.Message
+	if message == "" {
+		message = http.StatusText(httpStatus)
+	}
+	class, maybeProcessed := classifyResponse(httpStatus, message)
+	if codeClass, ok := gatecoinErrorClasses[status.ErrorCode]; ok {
+		class, maybeProcessed = codeClass, false
+	}
+	return &RequestError{class, cmd, maybeProcessed, &Error{status.ErrorCode, message, httpStatus, cmd, class}}
+}
 /////////////////////////////////////////////////////////////////////////
 //                          PUBLIC API METHODS                         //
 /////////////////////////////////////////////////////////////////////////
 
-func (gatecoin *GatecoinClient) GetTickers() (*TickersResponse, error) {
+func (gatecoin *GatecoinClient) GetTickers(ctx context.Context) (*TickersResponse, error) {
 	resp, err := gatecoin.queryPublic(
+		ctx,
 		[]string{"LiveTickers"},
 		&TickersResponse{})
 	if err != nil {
@@ -208,9 +286,10 @@ func (gatecoin *GatecoinClient) GetTickers() (*TickersResponse, error) {
 	return resp.(*TickersResponse), nil
 }
 
-func (gatecoin *GatecoinClient) GetMarketDepth(pair string) (*MarketDepthResponse, error) {
+func (gatecoin *GatecoinClient) GetMarketDepth(ctx context.Context, pair string) (*MarketDepthResponse, error) {
 	//Make request
 	resp, err := gatecoin.queryPublic(
+		ctx,
 		[]string{"MarketDepth", pair},
 		&MarketDepthResponse{})
 	if err != nil {
@@ -220,8 +299,9 @@ func (gatecoin *GatecoinClient) GetMarketDepth(pair string) (*MarketDepthRespons
 }
 
 //TODO - add TransactionID as query parameter ?TransactionId=BK11538053033
-func (gatecoin *GatecoinClient) GetTransactions(pair string) (*TransactionsResponse, error) {
+func (gatecoin *GatecoinClient) GetTransactions(ctx context.Context, pair string) (*TransactionsResponse, error) {
 	resp, err := gatecoin.queryPublic(
+		ctx,
 		[]string{"Transactions", pair},
 		&TransactionsResponse{})
 	if err != nil {
@@ -234,8 +314,9 @@ func (gatecoin *GatecoinClient) GetTransactions(pair string) (*TransactionsRespo
 //                          PRIVATE API METHODS                        //
 /////////////////////////////////////////////////////////////////////////
 
-func (gatecoin *GatecoinClient) GetBalances() (*BalancesResponse, error) {
+func (gatecoin *GatecoinClient) GetBalances(ctx context.Context) (*BalancesResponse, error) {
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"GET",
 		[]string{"Balance/Balances"},
 		[]byte{},
@@ -246,8 +327,9 @@ func (gatecoin *GatecoinClient) GetBalances() (*BalancesResponse, error) {
 	return resp.(*BalancesResponse), nil
 }
 
-func (gatecoin *GatecoinClient) GetBalance(currency string) (*BalanceResponse, error) {
+func (gatecoin *GatecoinClient) GetBalance(ctx context.Context, currency string) (*BalanceResponse, error) {
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"GET",
 		[]string{"Balance/Balances", strings.ToUpper(currency)},
 		[]byte{},
@@ -258,30 +340,98 @@ func (gatecoin *GatecoinClient) GetBalance(currency string) (*BalanceResponse, e
 	return resp.(*BalanceResponse), nil
 }
 
-func (gatecoin *GatecoinClient) CreateOrder(pair string, way string, amount string, price string) (*CreateOrderResponse, error) {
+func (gatecoin *GatecoinClient) CreateOrder(ctx context.Context, pair string, way string, amount decimal.Decimal, price decimal.Decimal) (*CreateOrderResponse, error) {
 	//compose order obj
 	//price denominated in quote / base
 	//amount denominated in base
-	order := NewOrder{pair, way, amount, price}
+	order := NewOrder{pair, way, amount.String(), price.String()}
 	//convert to json string
 	orderJson, err := json.Marshal(order)
-	fmt.Printf("\nOrder JSON string = %s\n", orderJson)
 	if err != nil {
 		return nil, err
 	}
+	known := gatecoin.knownOrders(ctx)
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"POST",
 		[]string{"Trade/Orders"},
 		orderJson,
 		&CreateOrderResponse{})
+	if err != nil && MaybeProcessed(err) && known != nil {
+		//the order may rest on the book although the response was lost, report it instead of placing it again
+		if placed := gatecoin.findPlacedOrder(order, known); placed != nil {
+			log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CreateOrder", "order": order, "orderId": placed.OrderId, "error": err.Error()}).Warn("Recovered order placed by failed request")
+			gatecoin.rememberOrder(placed.OrderId)
+			return &CreateOrderResponse{OrderId: placed.OrderId, Status: ResponseStatus{Message: "OK"}}, nil
+		}
+	}
 	if err != nil {
 		return nil, err
 	}
-	return resp.(*CreateOrderResponse), nil
+	created := resp.(*CreateOrderResponse)
+	gatecoin.rememberOrder(created.OrderId)
+	return created, nil
+}
+
+//Returns a copy of the ids of orders known to be open before a new order is sent, fetching them on first use.
+//Returns nil if they can't be fetched, in which case a lost order is not recovered.
+func (gatecoin *GatecoinClient) knownOrders(ctx context.Context) (map[string]bool) {
+	gatecoin.mutex.Lock()
+	fetched := gatecoin.known != nil
+	gatecoin.mutex.Unlock()
+	if !fetched {
+		if _, err := gatecoin.GetOrders(ctx); err != nil {
+			return nil
+		}
+	}
+	gatecoin.mutex.Lock()
+	defer gatecoin.mutex.Unlock()
+	known := make(map[string]bool)
+	for id, _ := range gatecoin.known {
+		known[id] = true
+	}
+	return known
+}
+
+func (gatecoin *GatecoinClient) rememberOrder(id string) {
+	gatecoin.mutex.Lock()
+	defer gatecoin.mutex.Unlock()
+	if gatecoin.known != nil && id != "" {
+		gatecoin.known[id] = true
+	}
 }
 
-func (gatecoin *GatecoinClient) GetOrders() (*GetOrdersResponse, error) {
+//Returns an open order matching a new order which was not among the known orders before it was sent, nil if there is none.
+//Comparing ids rather than dates keeps identical resting orders apart and does not depend on the exchange's clock.
+//Orders filled in full right away are no longer open and cannot be found.
+func (gatecoin *GatecoinClient) findPlacedOrder(order NewOrder, known map[string]bool) (*Order) {
+	//look up even when the caller's context has ended, which is a common cause of lost responses
+	ctx, cancel := withTimeout(context.Background(), gatecoin.timeout)
+	defer cancel()
+	resp, err := gatecoin.GetOrders(ctx)
+	if err != nil {
+		return nil
+	}
+	amount, _ := decimal.NewFromString(order.Amount)
+	price, _ := decimal.NewFromString(order.Price)
+	side := int64(0)
+	if strings.ToLower(order.Way) == "ask" {
+		side = 1
+	}
+	for i, open := range resp.Orders {
+		if known[open.OrderId] {
+			continue
+		}
+		if open.Code == order.Pair && open.Side == side && open.Price.Equal(price) && open.InitQuantity.Equal(amount) {
+			return &resp.Orders[i]
+		}
+	}
+	return nil
+}
+
+func (gatecoin *GatecoinClient) GetOrders(ctx context.Context) (*GetOrdersResponse, error) {
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"GET",
 		[]string{"Trade/Orders"},
 		[]byte{},
@@ -289,11 +439,19 @@ func (gatecoin *GatecoinClient) GetOrders() (*GetOrdersResponse, error) {
 	if err != nil {
 		return nil, err
 	}
-	return resp.(*GetOrdersResponse), nil
+	orders := resp.(*GetOrdersResponse)
+	gatecoin.mutex.Lock()
+	gatecoin.known = make(map[string]bool)
+	for _, open := range orders.Orders {
+		gatecoin.known[open.OrderId] = true
+	}
+	gatecoin.mutex.Unlock()
+	return orders, nil
 }
 
-func (gatecoin *GatecoinClient) GetOrder(id string) (*GetOrderResponse, error) {
+func (gatecoin *GatecoinClient) GetOrder(ctx context.Context, id string) (*GetOrderResponse, error) {
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"GET",
 		[]string{"Trade/Orders", id},
 		[]byte{},
@@ -304,8 +462,9 @@ func (gatecoin *GatecoinClient) GetOrder(id string) (*GetOrderResponse, error) {
 	return resp.(*GetOrderResponse), nil
 }
 
-func (gatecoin *GatecoinClient) DeleteOrder(id string) (*KillOrderResponse, error) {
+func (gatecoin *GatecoinClient) DeleteOrder(ctx context.Context, id string) (*KillOrderResponse, error) {
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"DELETE",
 		[]string{"Trade/Orders", id},
 		[]byte{},
@@ -317,8 +476,9 @@ func (gatecoin *GatecoinClient) DeleteOrder(id string) (*KillOrderResponse, erro
 }
 
 //UNFINISHED - args and formatting 
-func (gatecoin *GatecoinClient) Withdraw(currency string) (*WithdrawResponse, error) {
+func (gatecoin *GatecoinClient) Withdraw(ctx context.Context, currency string) (*WithdrawResponse, error) {
 	resp, err := gatecoin.queryPrivate(
+		ctx,
 		"POST",
 		[]string{"ElectronicWallet/Withdrawals", currency},
 		[]byte{},
@@ -1,36 +1,39 @@
 package api
 
 import(
-	"io/ioutil"
-	"encoding/json"
-	"os"
+	"context"
+	"errors"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
 	"github.com/stretchr/testify/assert"
-	"github.com/niklaskunkel/market-maker/config"
 )
 
-//CONSTANTS
-const API_PUBLIC_RATE_LIMIT = 1000 * time.Millisecond
-const API_PRIVATE_RATE_LIMIT = 1000 * time.Millisecond
+//Emulator shared by all tests so orders created in one test are visible to the next
+var emulator *GatecoinEmulator
 
 func SetupGatecoinClient(t *testing.T) (*GatecoinClient) {
-	credentials := new(config.Auth)
-	goPath, ok := os.LookupEnv("GOPATH")
-	assert.True(t, ok)
-	credentialsPath := goPath + "/src/github.com/niklaskunkel/market-maker/credentials.json"
-	raw, err := ioutil.ReadFile(credentialsPath)
-	assert.Nil(t, err)
-	err = json.Unmarshal(raw, credentials)
-	assert.Nil(t, err)
-	client := NewGatecoinClient(credentials.Key, credentials.Secret)
-	return client
+	if emulator == nil {
+		emulator = NewGatecoinEmulator("key", "secret", map[string]float64{"DAI": 100.0, "USD": 50.0})
+		emulator.SetTickers([]Ticker{Ticker{"BTCUSD", 9800, 10000, 0.5, 10100, 9700, 120, 120, 9990, 1.2, 10010, 0.8, 9950, "1515755942"}})
+		emulator.SetMarketDepth("BTCUSD", []Offer{Offer{dec("10010"), dec("0.8")}, Offer{dec("10020"), dec("2.5")}}, []Offer{Offer{dec("9990"), dec("1.2")}, Offer{dec("9980"), dec("3.0")}})
+		emulator.SetTransactions("BTCUSD", []Transaction{Transaction{11538053033, "1515755942", dec("10000"), dec("0.5"), "BTCUSD", "bid", "BK01", "BK02"}})
+	}
+	return emulator.NewClient()
+}
+
+//Shorthand for decimal literals
+func dec(value string) (decimal.Decimal) {
+	return decimal.RequireFromString(value)
 }
 
 func Test_Api_GetTickers(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PUBLIC_RATE_LIMIT)
-	resp, err := gatecoin.GetTickers()
+	resp, err := gatecoin.GetTickers(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	assert.NotEmpty(t, resp.Tickers)
@@ -54,34 +57,32 @@ func Test_Api_GetTickers(t *testing.T) {
 
 func Test_Api_GetMarketDepth(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PUBLIC_RATE_LIMIT)
-	resp, err := gatecoin.GetMarketDepth("BTCUSD")
+	resp, err := gatecoin.GetMarketDepth(context.Background(), "BTCUSD")
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	assert.NotEmpty(t, resp.Asks)
 	assert.NotEmpty(t, resp.Bids)
 	for _, ask := range resp.Asks {
-		assert.NotZero(t, ask.Price)
-		assert.NotZero(t, ask.Volume)
+		assert.True(t, ask.Price.IsPositive())
+		assert.True(t, ask.Volume.IsPositive())
 	}
 	for _, bid := range resp.Bids {
-		assert.NotZero(t, bid.Price)
-		assert.NotZero(t, bid.Volume)
+		assert.True(t, bid.Price.IsPositive())
+		assert.True(t, bid.Volume.IsPositive())
 	}
 }
 
 func Test_Api_GetTransactions(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PUBLIC_RATE_LIMIT)
-	resp, err := gatecoin.GetTransactions("BTCUSD")
+	resp, err := gatecoin.GetTransactions(context.Background(), "BTCUSD")
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	assert.NotEmpty(t, resp.Transactions)
 	for _, tx := range resp.Transactions {
 		assert.NotZero(t, tx.Id)
 		assert.NotEqual(t, tx.Time, "")
-		assert.NotZero(t, tx.Price)
-		assert.NotZero(t, tx.Quantity)
+		assert.True(t, tx.Price.IsPositive())
+		assert.True(t, tx.Quantity.IsPositive())
 		assert.NotEqual(t, tx.Pair, "")
 		//assert.NotEqual(t, tx.Way, "")	//some orders do not have these fields
 		//assert.NotEqual(t, tx.AskId, "")	//some orders do not have these fields
@@ -91,8 +92,7 @@ func Test_Api_GetTransactions(t *testing.T) {
 
 func Test_Api_GetBalances(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PRIVATE_RATE_LIMIT)
-	resp, err := gatecoin.GetBalances()
+	resp, err := gatecoin.GetBalances(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	for _, token := range resp.Balances {
@@ -103,12 +103,11 @@ func Test_Api_GetBalances(t *testing.T) {
 
 func Test_Api_GetBalance(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PRIVATE_RATE_LIMIT)
-	resp, err := gatecoin.GetBalance("DAI")
+	resp, err := gatecoin.GetBalance(context.Background(), "DAI")
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	assert.NotEqual(t, resp.Balance.Currency, "")
-	assert.NotZero(t, resp.Balance.Balance)
+	assert.True(t, resp.Balance.Balance.IsPositive())
 	assert.True(t, resp.Balance.IsDigital)
 }
 
@@ -116,8 +115,7 @@ var OrderId = ""
 
 func Test_Api_CreateOrder(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PRIVATE_RATE_LIMIT)
-	resp, err := gatecoin.CreateOrder("DAIUSD", "bid", "1", "0.01")
+	resp, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "bid", dec("1"), dec("0.01"))
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	assert.NotEqual(t, resp.OrderId, "")
@@ -126,17 +124,16 @@ func Test_Api_CreateOrder(t *testing.T) {
 
 func Test_Api_GetOrders(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PRIVATE_RATE_LIMIT)
-	resp, err := gatecoin.GetOrders()
+	resp, err := gatecoin.GetOrders(context.Background())
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	for _, order := range resp.Orders {
 		assert.NotEqual(t, order.Code, "")
 		assert.NotEqual(t, order,OrderId, "")
 		//assert.NotZero(t, order.Side)		//0 is valid response, so no way to check from default val of 0
-		assert.NotZero(t, order.Price)
-		assert.NotZero(t, order.InitQuantity)
-		assert.NotZero(t, order.RemQuantity)
+		assert.True(t, order.Price.IsPositive())
+		assert.True(t, order.InitQuantity.IsPositive())
+		assert.True(t, order.RemQuantity.IsPositive())
 		assert.NotZero(t, order.Status)
 		assert.NotEqual(t, order.StatusDesc, "")
 		//assert.NotZero(t, order.TxSeqNo)	//0 is valid response, so no way to check from default val of 0
@@ -146,17 +143,16 @@ func Test_Api_GetOrders(t *testing.T) {
 
 func Test_Api_GetOrder(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PRIVATE_RATE_LIMIT)
-	resp, err := gatecoin.GetOrder(OrderId)
+	resp, err := gatecoin.GetOrder(context.Background(), OrderId)
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
 	order := resp.Order
 	assert.NotEqual(t, order.Code, "")
 	assert.NotEqual(t, order,OrderId, "")
 	//assert.NotZero(t, order.Side)			//0 is valid response, so no way to check from default val of 0
-	assert.NotZero(t, order.Price)
-	assert.NotZero(t, order.InitQuantity)
-	assert.NotZero(t, order.RemQuantity)
+	assert.True(t, order.Price.IsPositive())
+	assert.True(t, order.InitQuantity.IsPositive())
+	assert.True(t, order.RemQuantity.IsPositive())
 	assert.NotZero(t, order.Status)
 	assert.NotEqual(t, order.StatusDesc, "")
 	//assert.NotZero(t, order.TxSeqNo)		//0 is valid response, so no way to check from default val of 0
@@ -165,8 +161,227 @@ func Test_Api_GetOrder(t *testing.T) {
 
 func Test_Api_DeleteOrder(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	time.Sleep(API_PRIVATE_RATE_LIMIT)
-	resp, err := gatecoin.DeleteOrder(OrderId)
+	resp, err := gatecoin.DeleteOrder(context.Background(), OrderId)
 	assert.Nil(t, err)
 	assert.Equal(t, "OK", resp.Status.Message)
-}
\ No newline at end of file
+}
+
+//Test requests signed with the wrong secret are rejected
+func Test_Api_InvalidSignature(t *testing.T) {
+	SetupGatecoinClient(t)
+	gatecoin := NewGatecoinClient("GATECOIN", "key", "wrong secret")
+	gatecoin.SetHost(emulator.URL)
+	resp, err := gatecoin.GetBalances(context.Background())
+	assert.Nil(t, resp)
+	assert.EqualError(t, err, "Invalid API signature")
+}
+
+//Test orders without funds are rejected and leave balances untouched
+func Test_Api_CreateOrderInsufficientFunds(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	_, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("1000"), dec("1.01"))
+	assert.EqualError(t, err, "Insufficient funds")
+	resp, err := gatecoin.GetBalance(context.Background(), "DAI")
+	assert.Nil(t, err)
+	assert.Equal(t, "100", resp.Balance.AvailableBalance.String())
+}
+
+//Test failures are classified for retries
+func Test_Api_ErrorClasses(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	gatecoin.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	_, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("1000"), dec("1.01"))
+	assert.Equal(t, ErrorInsufficientFunds, ClassOf(err))
+	_, err = gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("-1"), dec("1.01"))
+	assert.Equal(t, ErrorInvalidAmount, ClassOf(err))
+	emulator.FailRequests(1, http.StatusTooManyRequests)
+	_, err = gatecoin.GetBalances(context.Background())
+	assert.Equal(t, ErrorRateLimited, ClassOf(err))
+	assert.False(t, MaybeProcessed(err))
+	emulator.FailRequests(1, http.StatusBadGateway)
+	_, err = gatecoin.GetBalances(context.Background())
+	assert.Equal(t, ErrorNetwork, ClassOf(err))
+	assert.True(t, MaybeProcessed(err))
+
+	gatecoin.SetHost("http://127.0.0.1:1")
+	_, err = gatecoin.GetBalances(context.Background())
+	assert.Equal(t, ErrorNetwork, ClassOf(err))
+	assert.False(t, MaybeProcessed(err))
+
+	unsigned := NewGatecoinClient("GATECOIN", "key", "wrong secret")
+	unsigned.SetHost(emulator.URL)
+	unsigned.SetRateLimits(registry.RateLimits{})
+	_, err = unsigned.GetBalances(context.Background())
+	assert.Equal(t, ErrorAuth, ClassOf(err))
+}
+
+//Test transient failures are retried with backoff and permanent ones are not
+func Test_Api_Retry(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	var delays []time.Duration
+	defer func() { retrySleep, retryRandom = sleepContext, rand.Float64 }()
+	retrySleep = func(ctx context.Context, delay time.Duration) (error) {
+		delays = append(delays, delay)
+		return nil
+	}
+	retryRandom = func() (float64) { return 1 }
+
+	emulator.FailRequests(2, http.StatusServiceUnavailable)
+	resp, err := gatecoin.GetMarketDepth(context.Background(), "BTCUSD")
+	assert.Nil(t, err)
+	assert.NotEmpty(t, resp.Asks)
+	assert.Equal(t, []time.Duration{250 * time.Millisecond, 500 * time.Millisecond}, delays)
+
+	delays = nil
+	emulator.FailRequests(3, http.StatusServiceUnavailable)
+	_, err = gatecoin.GetMarketDepth(context.Background(), "BTCUSD")
+	assert.Equal(t, ErrorNetwork, ClassOf(err))
+	assert.Len(t, delays, 2)
+
+	delays = nil
+	_, err = gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("1000"), dec("1.01"))
+	assert.Equal(t, ErrorInsufficientFunds, ClassOf(err))
+	assert.Empty(t, delays)
+
+	//throttled orders were not placed and are safe to repeat
+	emulator.FailRequests(1, http.StatusTooManyRequests)
+	created, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("1"), dec("1.50"))
+	assert.Nil(t, err)
+	assert.Len(t, delays, 1)
+	gatecoin.DeleteOrder(context.Background(), created.OrderId)
+}
+
+//Test an order whose response was lost is recovered instead of placed again
+func Test_Api_CreateOrderIdempotent(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	defer func() { retrySleep = sleepContext }()
+	retrySleep = func(ctx context.Context, delay time.Duration) (error) { return nil }
+	before, _ := gatecoin.GetOrders(context.Background())
+
+	emulator.DropResponses(1)
+	resp, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("2"), dec("1.60"))
+	assert.Nil(t, err)
+	after, _ := gatecoin.GetOrders(context.Background())
+	assert.Len(t, after.Orders, len(before.Orders) + 1)
+	assert.Equal(t, after.Orders[len(after.Orders) - 1].OrderId, resp.OrderId)
+
+	//a server error after placing is not repeated either
+	emulator.FailRequests(1, http.StatusInternalServerError)
+	_, err = gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("2"), dec("1.70"))
+	assert.Equal(t, ErrorNetwork, ClassOf(err))
+	final, _ := gatecoin.GetOrders(context.Background())
+	assert.Len(t, final.Orders, len(after.Orders))
+	gatecoin.DeleteOrder(context.Background(), resp.OrderId)
+}
+
+//Test a lost order is told apart from an identical order which was already resting
+func Test_Api_CreateOrderIdempotentDuplicate(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	defer func() { retrySleep = sleepContext }()
+	retrySleep = func(ctx context.Context, delay time.Duration) (error) { return nil }
+	resting, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("2"), dec("1.65"))
+	assert.Nil(t, err)
+
+	emulator.DropResponses(1)
+	recovered, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("2"), dec("1.65"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, resting.OrderId, recovered.OrderId)
+	orders, _ := gatecoin.GetOrders(context.Background())
+	assert.Equal(t, orders.Orders[len(orders.Orders) - 1].OrderId, recovered.OrderId)
+	gatecoin.DeleteOrder(context.Background(), resting.OrderId)
+	gatecoin.DeleteOrder(context.Background(), recovered.OrderId)
+}
+
+//Test a lost order is recovered when the exchange's clock is behind the local one
+func Test_Api_CreateOrderIdempotentClockSkew(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	defer func() { retrySleep = sleepContext }()
+	retrySleep = func(ctx context.Context, delay time.Duration) (error) { return nil }
+	defer func() { emulator.Exchange.clock = time.Now }()
+	emulator.Exchange.clock = func() (time.Time) { return time.Now().Add(-time.Hour) }
+	before, _ := gatecoin.GetOrders(context.Background())
+
+	emulator.DropResponses(1)
+	recovered, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("2"), dec("1.75"))
+	assert.Nil(t, err)
+	after, _ := gatecoin.GetOrders(context.Background())
+	assert.Len(t, after.Orders, len(before.Orders) + 1)	//not placed twice
+	assert.Equal(t, after.Orders[len(after.Orders) - 1].OrderId, recovered.OrderId)
+	gatecoin.DeleteOrder(context.Background(), recovered.OrderId)
+}
+
+//Test a hung request ends with the caller's deadline or the client's default timeout
+func Test_Api_Deadline(t *testing.T) {
+	hung := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer hung.Close()
+	gatecoin := SetupGatecoinClient(t)
+	gatecoin.SetHost(hung.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50 * time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := gatecoin.GetMarketDepth(ctx, "BTCUSD")
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second)
+
+	gatecoin.SetTimeout(50 * time.Millisecond)
+	start = time.Now()
+	_, err = gatecoin.GetBalances(context.Background())
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < time.Second)
+}
+
+//Test cancelling stops retries
+func Test_Api_RetryCancelled(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	emulator.FailRequests(3, http.StatusServiceUnavailable)
+	defer emulator.FailRequests(0, 0)
+	defer func() { retrySleep = sleepContext }()
+	attempts := 0
+	retrySleep = func(ctx context.Context, delay time.Duration) (error) {
+		attempts++
+		cancel()
+		return sleepContext(ctx, delay)
+	}
+	_, err := gatecoin.GetTickers(ctx)
+	assert.Equal(t, ErrorNetwork, ClassOf(err))
+	assert.Equal(t, 1, attempts)
+}
+
+//Test exchange errors keep their code and status and match the sentinels
+func Test_Api_TypedErrors(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	gatecoin.SetRetryPolicy(RetryPolicy{MaxAttempts: 1})
+	_, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("1000"), dec("1.01"))
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+	assert.False(t, errors.Is(err, ErrInvalidPrice))
+	var apiError *Error
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, Error{GatecoinErrorInsufficientFunds, "Insufficient funds", http.StatusOK, "Trade/Orders", ErrorInsufficientFunds}, *apiError)
+
+	_, err = gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec("1"), dec("-1"))
+	assert.True(t, errors.Is(err, ErrInvalidPrice))
+	_, err = gatecoin.DeleteOrder(context.Background(), "BK00000000")
+	assert.True(t, errors.Is(err, ErrOrderNotFound))
+
+	unsigned := NewGatecoinClient("GATECOIN", "key", "wrong secret")
+	unsigned.SetHost(emulator.URL)
+	unsigned.SetRateLimits(registry.RateLimits{})
+	_, err = unsigned.GetBalances(context.Background())
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.True(t, errors.As(err, &apiError))
+	assert.Equal(t, http.StatusUnauthorized, apiError.HTTPStatus)
+
+	_, err = gatecoin.queryPrivate(context.Background(), "GET", []string{"Trade/Trades"}, []byte{}, &GetOrdersResponse{})
+	assert.EqualError(t, err, "Unsupported Private Method Trade/Trades")
+
+	//codes the emulator does not use fall back to matching by class
+	assert.True(t, errors.Is(classifyGatecoinError("Balance/Balances", http.StatusForbidden, ResponseStatus{ErrorCode: "E42"}), ErrAuthFailed))
+	assert.True(t, errors.Is(classifyGatecoinError("Trade/Orders", http.StatusOK, ResponseStatus{Message: "Insufficient balance"}), ErrInsufficientBalance))
+	assert.True(t, errors.Is(classifyGatecoinError("Trade/Orders", http.StatusNotFound, ResponseStatus{}), ErrOrderNotFound))
+	assert.False(t, errors.Is(classifyGatecoinError("Trade/Orders", http.StatusOK, ResponseStatus{ErrorCode: GatecoinErrorInvalidPrice}), ErrInvalidOrder))
+	assert.True(t, errors.Is(classifyGatecoinError("Trade/Orders", http.StatusBadRequest, ResponseStatus{ErrorCode: "E7", Message: "Invalid price"}), ErrInvalidPrice))
+}
@@ -1,5 +1,9 @@
 package api
 
+import(
+	"github.com/shopspring/decimal"
+)
+
 type ResponseStatus struct {
 	ErrorCode 	string 			`json:"errorCode"`
 	Message 	string 			`json:"message"`
@@ -14,6 +18,8 @@ type TickersResponse struct {
 	Status 		ResponseStatus 	`json:"responseStatus"`
 }
 
+//Ticker prices are floats, they are only used as a reference price by the feed package, which
+//aggregates and checks prices as floats. Order prices and amounts are decimals, see Order.
 type Ticker struct {
 	Pair 	string 		`json:"currencyPair"`
 	Open 	float64 	`json:"open,omitmepty"`
@@ -37,9 +43,10 @@ type MarketDepthResponse struct {
 	Status 	ResponseStatus 	`json:"responseStatus"`
 }
 
+//Prices and amounts are decimals decoded from the exchange's JSON as is, see Order
 type Offer 	struct {
-	Price 	float64  	`json:"price"`
-	Volume 	float64 	`json:"volume"`
+	Price 	decimal.Decimal 	`json:"price"`
+	Volume 	decimal.Decimal 	`json:"volume"`
 }
 
 type TransactionsResponse struct {
@@ -50,8 +57,8 @@ type TransactionsResponse struct {
 type Transaction struct {
 	Id 			int64 		`json:"transactionId"`
 	Time 		string		`json:"transactionTime"`
-	Price 		float64 	`json:"price"`
-	Quantity 	float64 	`json:"quantity"`
+	Price 		decimal.Decimal 	`json:"price"`
+	Quantity 	decimal.Decimal 	`json:"quantity"`
 	Pair 		string 		`json:"currencyPair"`
 	Way 		string 		`json:"way"`
 	AskId 		string 		`json:"askOrderId"`
@@ -70,11 +77,11 @@ type BalanceResponse struct {
 
 type Balance struct {
 	Currency 			string		`json:"currency"`
-	Balance 			float64		`json:"balance"`
-	AvailableBalance 	float64		`json:"availableBalance"`
-	PendingIncoming 	float64		`json:"pendingIncoming"`
-	PendingOutgoing 	float64		`json:"pendingOutgoing"`
-	OpenOrder 			float64		`json:"openOrder"`
+	Balance 			decimal.Decimal		`json:"balance"`
+	AvailableBalance 	decimal.Decimal		`json:"availableBalance"`
+	PendingIncoming 	decimal.Decimal		`json:"pendingIncoming"`
+	PendingOutgoing 	decimal.Decimal		`json:"pendingOutgoing"`
+	OpenOrder 			decimal.Decimal		`json:"openOrder"`
 	IsDigital 			bool		`json:"isDigital"`
 }
 
@@ -100,18 +107,31 @@ type GetOrderResponse struct {
 	Status 	ResponseStatus 	`json:"responseStatus"`
 }
 
+//Order is a resting order. Adapters report InitQuantity and RemQuantity in base token for both sides,
+//whatever the venue uses, so amounts in quote token are always quantity times the order's own price.
+//Prices and quantities are exact decimals, whether the venue sends them as JSON numbers or strings.
 type Order struct {
-	Code 			string 	`json:"code"`
-	OrderId 		string 	`json:"clOrderId"`
-	Side 			int64 	`json:"side"`
-	Price 			float64 `json:"price"`
-	InitQuantity 	float64 `json:"initialQuantity"`
-	RemQuantity 	float64 `json:"remainingQuantity"`
-	Status 			int64 	`json:"status"`
-	StatusDesc 		string 	`json:"statusDesc"`
-	TxSeqNo 		int64 	`json:"tranSeqNo"`
-	Type 			int64 	`json:"type"`
-	Date 			string 	`json:"date"`
+	Code 			string 			`json:"code"`
+	OrderId 		string 			`json:"clOrderId"`
+	Side 			int64 			`json:"side"`
+	Price 			decimal.Decimal `json:"price"`
+	InitQuantity 	decimal.Decimal `json:"initialQuantity"`
+	RemQuantity 	decimal.Decimal `json:"remainingQuantity"`
+	Status 			int64 			`json:"status"`
+	StatusDesc 		string 			`json:"statusDesc"`
+	TxSeqNo 		int64 			`json:"tranSeqNo"`
+	Type 			int64 			`json:"type"`
+	Date 			string 			`json:"date"`
+}
+
+//Returns the remaining amount of the order in base token
+func (order Order) BaseAmount() (decimal.Decimal) {
+	return order.RemQuantity
+}
+
+//Returns the remaining amount of the order in quote token at the order's own price
+func (order Order) QuoteAmount() (decimal.Decimal) {
+	return order.RemQuantity.Mul(order.Price)
 }
 
 type KillOrderResponse struct {
@@ -0,0 +1,161 @@
+package api
+
+import(
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+)
+
+//Error codes in responseStatus.errorCode as returned by GatecoinEmulator and SimulatedClient.
+//Gatecoin does not document its error codes, so these are the emulator's own and the live exchange
+//is not expected to return them. Live errors are matched to the sentinels below by their class.
+const (
+	GatecoinErrorAuth 				= "1001"
+	GatecoinErrorNotFound 			= "1002"
+	GatecoinErrorInvalidOrder 		= "1003"
+	GatecoinErrorInsufficientFunds 	= "1004"
+	GatecoinErrorRateLimited 		= "1005"
+	GatecoinErrorInvalidPrice 		= "1006"
+	GatecoinErrorInvalidAmount 		= "1007"
+)
+
+//Error is a failure reported by an exchange. Compare it with the sentinels below using errors.Is,
+//or use errors.As to read the code and status.
+type Error struct {
+	Code 		string 		//Exchange error code, empty if the exchange gave none
+	Message 	string
+	HTTPStatus 	int 		//Status of the response, 0 if the error did not come over HTTP
+	Endpoint 	string 		//API method which failed
+	Class 		ErrorClass 	//How the caller should react, empty if unclassified
+}
+
+func (apiError *Error) Error() (string) {
+	return apiError.Message
+}
+
+//Errors match if they have the same code. Errors whose code is missing or not one of the codes above,
+//e.g. from Ethfinex or the live Gatecoin API, match the sentinel of their class instead.
+func (apiError *Error) Is(target error) (bool) {
+	targetError, ok := target.(*Error)
+	if !ok {
+		return false
+	}
+	if targetError.Code != "" && targetError.Code == apiError.Code {
+		return true
+	}
+	if _, known := gatecoinErrorClasses[apiError.Code]; known {
+		return false
+	}
+	return targetError.Class != "" && targetError.Class == apiError.Class
+}
+
+//Sentinels of common exchange errors
+var (
+	ErrAuthFailed 			= &Error{Code: GatecoinErrorAuth, Message: "Authentication failed", Class: ErrorAuth}
+	ErrOrderNotFound 		= &Error{Code: GatecoinErrorNotFound, Message: "Order not found", Class: ErrorNotFound}
+	ErrInvalidOrder 		= &Error{Code: GatecoinErrorInvalidOrder, Message: "Invalid order", Class: ErrorInvalidOrder}
+	ErrInsufficientBalance 	= &Error{Code: GatecoinErrorInsufficientFunds, Message: "Insufficient funds", Class: ErrorInsufficientFunds}
+	ErrRateLimited 			= &Error{Code: GatecoinErrorRateLimited, Message: "Rate limit exceeded", Class: ErrorRateLimited}
+	ErrInvalidPrice 		= &Error{Code: GatecoinErrorInvalidPrice, Message: "Invalid order price", Class: ErrorInvalidPrice}
+	ErrInvalidAmount 		= &Error{Code: GatecoinErrorInvalidAmount, Message: "Invalid order amount", Class: ErrorInvalidAmount}
+)
+
+//ErrorClass groups request failures by how the caller should react to them
+type ErrorClass string
+
+const (
+	ErrorNetwork 			ErrorClass = "network"				//Connection failed or the server errored, retryable
+	ErrorRateLimited 		ErrorClass = "rateLimited"			//Request was throttled and not processed, retryable
+	ErrorAuth 				ErrorClass = "auth"					//Credentials or signature were rejected
+	ErrorInvalidOrder 		ErrorClass = "invalidOrder"			//Order parameters were rejected
+	ErrorInvalidPrice 		ErrorClass = "invalidPrice"			//Order price was rejected, e.g. too many decimals
+	ErrorInvalidAmount 		ErrorClass = "invalidAmount"		//Order amount was rejected, e.g. below the minimum size
+	ErrorInsufficientFunds 	ErrorClass = "insufficientFunds"	//Balance does not cover the order
+	ErrorNotFound 			ErrorClass = "notFound"				//Order or endpoint does not exist
+	ErrorUnknown 			ErrorClass = "unknown"				//Anything else, e.g. an undecodable response
+)
+
+//Returns whether a request failing with the class may succeed when repeated unchanged
+func (class ErrorClass) Retryable() (bool) {
+	return class == ErrorNetwork || class == ErrorRateLimited
+}
+
+//RequestError is a failed API request classified for retries. Err is an *Error if the exchange rejected the request.
+type RequestError struct {
+	Class 			ErrorClass
+	Endpoint 		string
+	MaybeProcessed 	bool 		//The exchange may have acted on the request despite the failure
+	Err 			error
+}
+
+func (requestError *RequestError) Error() (string) {
+	return requestError.Err.Error()
+}
+
+func (requestError *RequestError) Unwrap() (error) {
+	return requestError.Err
+}
+
+//Returns the class of an error, ErrorUnknown if it was not classified
+func ClassOf(err error) (ErrorClass) {
+	var requestError *RequestError
+	if errors.As(err, &requestError) {
+		return requestError.Class
+	}
+	var apiError *Error
+	if errors.As(err, &apiError) && apiError.Class != "" {
+		return apiError.Class
+	}
+	return ErrorUnknown
+}
+
+//Classifies an exchange error without a known code by its HTTP status and message.
+//Returns whether the exchange may have acted on the request, i.e. the server failed.
+func classifyResponse(httpStatus int, message string) (ErrorClass, bool) {
+	message = strings.ToLower(message)
+	switch {
+	case httpStatus == http.StatusUnauthorized || httpStatus == http.StatusForbidden:
+		return ErrorAuth, false
+	case httpStatus == http.StatusTooManyRequests:
+		return ErrorRateLimited, false
+	case httpStatus >= http.StatusInternalServerError:
+		//the server may have failed after acting on the request
+		return ErrorNetwork, true
+	case httpStatus == http.StatusNotFound || strings.Contains(message, "not found"):
+		return ErrorNotFound, false
+	case strings.Contains(message, "insufficient") || strings.Contains(message, "not enough"):
+		return ErrorInsufficientFunds, false
+	case httpStatus == http.StatusBadRequest || strings.Contains(message, "invalid"):
+		return invalidOrderClass(message), false
+	}
+	return ErrorUnknown, false
+}
+
+//Narrows a rejected order down to its price or amount when the message names it, e.g. Ethfinex answers
+//"Key price should be a decimal number" or "Invalid order: minimum size for ETHUSD is 0.04"
+func invalidOrderClass(message string) (ErrorClass) {
+	switch {
+	case strings.Contains(message, "price"):
+		return ErrorInvalidPrice
+	case strings.Contains(message, "amount") || strings.Contains(message, "size") || strings.Contains(message, "quantity"):
+		return ErrorInvalidAmount
+	}
+	return ErrorInvalidOrder
+}
+
+//Returns whether the exchange may have acted on a failed request, so repeating it could apply it twice
+func MaybeProcessed(err error) (bool) {
+	var requestError *RequestError
+	if errors.As(err, &requestError) {
+		return requestError.MaybeProcessed
+	}
+	return false
+}
+
+//Classifies a transport error. Only failures to connect are known to leave the request unsent.
+func networkError(endpoint string, err error) (*RequestError) {
+	var opError *net.OpError
+	sent := !(errors.As(err, &opError) && opError.Op == "dial")
+	return &RequestError{ErrorNetwork, endpoint, sent, err}
+}
@@ -0,0 +1,379 @@
+package api
+
+import(
+	"context"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+//Constants
+const (
+	EthfinexAPIHostUrl = "https://api.ethfinex.com"
+	EthfinexAPIVersion = "/v1"
+)
+
+var ethfinexPublicMethods = []string {
+	"book",
+}
+
+var ethfinexPrivateMethods = []string {
+	"balances",
+	"orders",
+	"order/new",
+	"order/cancel",
+}
+
+//Type Structs
+type EthfinexClient struct {
+	Name	string 			//Name of client
+	key 	string			//Ethfinex API Key
+	secret 	string			//Ethfinex Secret Key
+	host 	string			//Ethfinex API host
+	client 	*http.Client
+	limiter	*RateLimiter	//Request budgets, defaults to the registry's
+	timeout	time.Duration	//Deadline of each call, defaults to DefaultRequestTimeout
+}
+
+func NewEthfinexClient(name, key, secret string) (*EthfinexClient) {
+	client := &http.Client{}
+	return &EthfinexClient{strings.ToUpper(name), key, secret, EthfinexAPIHostUrl, client, NewRateLimiter(registry.LookupExchangeRateLimits("ETHFINEX")), DefaultRequestTimeout}
+}
+
+//Replaces the request budgets of the client, resetting its wait metrics
+func (ethfinex *EthfinexClient) SetRateLimits(limits registry.RateLimits) {
+	ethfinex.limiter = NewRateLimiter(limits)
+}
+
+//Sets the default deadline of each call, zero leaves calls bounded by their context only
+func (ethfinex *EthfinexClient) SetTimeout(timeout time.Duration) {
+	ethfinex.timeout = timeout
+}
+
+//Returns how long requests waited for their budget, keyed by endpoint
+func (ethfinex *EthfinexClient) RateLimitMetrics() (map[string]WaitMetrics) {
+	return ethfinex.limiter.Metrics()
+}
+
+//Points the client at a different API host, e.g. a local stub
+func (ethfinex *EthfinexClient) SetHost(host string) {
+	ethfinex.host = strings.TrimRight(host, "/")
+}
+
+func (ethfinex *EthfinexClient) GetName() (string) {
+	return ethfinex.Name
+}
+
+func (ethfinex *EthfinexClient) GetTokenPairName(pair string) (string) {
+	return registry.LookupEthfinexTokenPairName(pair)
+}
+
+func (ethfinex *EthfinexClient) GetTokenPairPrecision(pair string) (registry.Precision) {
+	return registry.LookupEthfinexTokenPairPrecision(pair)
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          REQUEST CONSTRUCTION                       //
+/////////////////////////////////////////////////////////////////////////
+func (ethfinex *EthfinexClient) queryPublic(ctx context.Context, params []string, responseType interface{}) (interface{}, error) {
+	//check if valid command
+	cmd := params[0]
+	if !IsStringInSlice(cmd, ethfinexPublicMethods) {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "queryPublic", "Command": cmd}).Error("Command is not in supported Public Commands list")
+		return nil, fmt.Errorf("Unsupported Public Method %s", cmd)
+	}
+	//format request URL w/ path and URL parameters
+	reqURL, _ := url.Parse(ethfinex.host)
+	reqURL.Path = EthfinexAPIVersion
+	for _, param := range params {
+		reqURL.Path += "/" + param
+	}
+
+	ctx, cancel := withTimeout(ctx, ethfinex.timeout)
+	defer cancel()
+
+	//wait until the request fits the public budget
+	wait, err := ethfinex.limiter.Wait(ctx, false, cmd)
+	log.WithFields(logrus.Fields{"client": "Ethfinex", "endpoint": cmd, "wait": wait}).Debug("Waited for public API budget")
+	if err != nil {
+		return nil, err
+	}
+
+	return ethfinex.doRequest(ctx, reqURL, "GET", nil, []byte{}, responseType)
+}
+
+//Private requests are always POSTs whose JSON body carries the request path and nonce.
+//The body is base64 encoded into the payload header and signed with HMAC-SHA384.
+func (ethfinex *EthfinexClient) queryPrivate(ctx context.Context, cmd string, payload interface{}, responseType interface{}) (interface{}, error) {
+	//check if valid command
+	if !IsStringInSlice(cmd, ethfinexPrivateMethods) {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "queryPrivate", "Command": cmd}).Error("Command is not in supported Private Commands list")
+		return nil, fmt.Errorf("Unsupported Private Method %s", cmd)
+	}
+
+	//Set url for request
+	reqURL, _ := url.Parse(ethfinex.host)
+	reqURL.Path = EthfinexAPIVersion + "/" + cmd
+
+	//encode request body
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "queryPrivate", "Command": cmd, "error": err.Error()}).Error("Failed to encode request payload")
+		return nil, err
+	}
+	encodedPayload := base64.StdEncoding.EncodeToString(data)
+
+	//Add api key, payload and signature to headers
+	headers := map[string]string {
+		"X-BFX-APIKEY": ethfinex.key,
+		"X-BFX-PAYLOAD": encodedPayload,
+		"X-BFX-SIGNATURE": createEthfinexSignature(encodedPayload, ethfinex.secret),
+	}
+
+	ctx, cancel := withTimeout(ctx, ethfinex.timeout)
+	defer cancel()
+
+	//wait until the request fits the private budget
+	wait, err := ethfinex.limiter.Wait(ctx, true, cmd)
+	log.WithFields(logrus.Fields{"client": "Ethfinex", "endpoint": cmd, "wait": wait}).Debug("Waited for private API budget")
+	if err != nil {
+		return nil, err
+	}
+
+	return ethfinex.doRequest(ctx, reqURL, "POST", headers, data, responseType)
+}
+
+func (ethfinex *EthfinexClient) doRequest(ctx context.Context, reqURL *url.URL, requestType string, headers map[string]string, data []byte, responseType interface{}) (interface{}, error) {
+	//Create request
+	req, err := http.NewRequestWithContext(ctx, requestType, reqURL.String(), bytes.NewReader(data))
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "doRequest", "requestType": requestType, "requestURL": reqURL.String(), "error": err.Error()}).Error("Failed to create new request")
+		return nil, err
+	}
+
+	//Add headers to request
+	req.Header.Set("User-Agent", APIUserAgent)
+	req.Header.Add("Accept", "application/json")
+	req.Header.Add("Content-Type", "application/json")
+
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+
+	//Log copy of request to debug
+	log.WithFields(logrus.Fields{"client": "Ethfinex", "request": req}).Debug("Request being sent")
+
+	//Execute request
+	resp, err := ethfinex.client.Do(req)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "doRequest", "requestType": requestType, "requestURL": reqURL.String(), "error": err.Error()}).Error("Failed to execute request")
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	//Read response
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "doRequest", "request": req, "error": err.Error()}).Error("Failed to read response for query")
+		return nil, err
+	}
+
+	//Log copy of response to debug
+	log.WithFields(logrus.Fields{"client": "Ethfinex", "response": string(body)}).Debug("Response received")
+
+	//Ethfinex reports errors with a non 2xx status code and a message body
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiError := EthfinexErrorResponse{}
+		if err := json.Unmarshal(body, &apiError); err != nil || apiError.Message == "" {
+			apiError.Message = resp.Status
+		}
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "doRequest", "statusCode": resp.StatusCode}).Error(apiError.Message)
+		//Ethfinex gives no error codes
+		class, _ := classifyResponse(resp.StatusCode, apiError.Message)
+		return nil, &Error{Message: apiError.Message, HTTPStatus: resp.StatusCode, Endpoint: strings.TrimPrefix(reqURL.Path, EthfinexAPIVersion + "/"), Class: class}
+	}
+
+	//Convert JSON to Response struct
+	err = json.Unmarshal(body, responseType)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "doRequest", "body": string(body), "request": req, "error": err.Error()}).Error("Failed to convert JSON response into struct")
+		return nil, err
+	}
+	return responseType, nil
+}
+
+//Returns payload header fields for a private request
+func (ethfinex *EthfinexClient) newPayload(cmd string) (EthfinexPayload) {
+	return EthfinexPayload{EthfinexAPIVersion + "/" + cmd, strconv.FormatInt(time.Now().UnixNano(), 10)}
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          PUBLIC API METHODS                         //
+/////////////////////////////////////////////////////////////////////////
+
+func (ethfinex *EthfinexClient) GetMarketDepth(ctx context.Context, pair string) (*MarketDepthResponse, error) {
+	resp, err := ethfinex.queryPublic(
+		ctx,
+		[]string{"book", strings.ToLower(pair)},
+		&EthfinexBook{})
+	if err != nil {
+		return nil, err
+	}
+	book := resp.(*EthfinexBook)
+	depth := &MarketDepthResponse{Status: ResponseStatus{Message: "OK"}}
+	for _, ask := range book.Asks {
+		depth.Asks = append(depth.Asks, Offer{ask.Price, ask.Amount})
+	}
+	for _, bid := range book.Bids {
+		depth.Bids = append(depth.Bids, Offer{bid.Price, bid.Amount})
+	}
+	return depth, nil
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          PRIVATE API METHODS                        //
+/////////////////////////////////////////////////////////////////////////
+
+func (ethfinex *EthfinexClient) GetBalances(ctx context.Context) (*BalancesResponse, error) {
+	resp, err := ethfinex.queryPrivate(
+		ctx,
+		"balances",
+		ethfinex.newPayload("balances"),
+		&[]EthfinexBalance{})
+	if err != nil {
+		return nil, err
+	}
+	balances := &BalancesResponse{Status: ResponseStatus{Message: "OK"}}
+	for _, balance := range *resp.(*[]EthfinexBalance) {
+		//only exchange wallet balances can be used for trading
+		if balance.Type != "exchange" {
+			continue
+		}
+		balances.Balances = append(balances.Balances, Balance{
+			Currency: strings.ToUpper(balance.Currency),
+			Balance: balance.Amount,
+			AvailableBalance: balance.Available,
+			OpenOrder: balance.Amount.Sub(balance.Available),
+			IsDigital: true})
+	}
+	return balances, nil
+}
+
+func (ethfinex *EthfinexClient) GetBalance(ctx context.Context, currency string) (*BalanceResponse, error) {
+	balances, err := ethfinex.GetBalances(ctx)
+	if err != nil {
+		return nil, err
+	}
+	//Ethfinex omits currencies with no balance
+	resp := &BalanceResponse{Balance: Balance{Currency: strings.ToUpper(currency), IsDigital: true}, Status: ResponseStatus{Message: "OK"}}
+	for _, balance := range balances.Balances {
+		if balance.Currency == strings.ToUpper(currency) {
+			resp.Balance = balance
+		}
+	}
+	return resp, nil
+}
+
+func (ethfinex *EthfinexClient) GetOrders(ctx context.Context) (*GetOrdersResponse, error) {
+	resp, err := ethfinex.queryPrivate(
+		ctx,
+		"orders",
+		ethfinex.newPayload("orders"),
+		&[]EthfinexOrder{})
+	if err != nil {
+		return nil, err
+	}
+	orders := &GetOrdersResponse{Status: ResponseStatus{Message: "OK"}}
+	for _, order := range *resp.(*[]EthfinexOrder) {
+		orders.Orders = append(orders.Orders, order.toOrder())
+	}
+	return orders, nil
+}
+
+func (ethfinex *EthfinexClient) CreateOrder(ctx context.Context, pair string, way string, amount decimal.Decimal, price decimal.Decimal) (*CreateOrderResponse, error) {
+	//translate Gatecoin style bid/ask into Ethfinex buy/sell
+	side := "buy"
+	if strings.ToLower(way) == "ask" {
+		side = "sell"
+	}
+	//price denominated in quote / base
+	//amount denominated in base
+	order := EthfinexNewOrder{ethfinex.newPayload("order/new"), strings.ToLower(pair), amount.String(), price.String(), "ethfinex", side, "exchange limit"}
+	resp, err := ethfinex.queryPrivate(
+		ctx,
+		"order/new",
+		order,
+		&EthfinexOrder{})
+	if err != nil {
+		return nil, err
+	}
+	return &CreateOrderResponse{OrderId: strconv.FormatInt(resp.(*EthfinexOrder).Id, 10), Status: ResponseStatus{Message: "OK"}}, nil
+}
+
+func (ethfinex *EthfinexClient) DeleteOrder(ctx context.Context, id string) (*KillOrderResponse, error) {
+	orderId, err := strconv.ParseInt(id, 10, 64)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": "Ethfinex", "function": "DeleteOrder", "orderId": id, "error": err.Error()}).Error("Invalid order id")
+		return nil, err
+	}
+	_, err = ethfinex.queryPrivate(
+		ctx,
+		"order/cancel",
+		EthfinexCancelOrder{ethfinex.newPayload("order/cancel"), orderId},
+		&EthfinexOrder{})
+	if err != nil {
+		return nil, err
+	}
+	return &KillOrderResponse{Status: ResponseStatus{Message: "OK"}}, nil
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                              ENCRYPTION                             //
+/////////////////////////////////////////////////////////////////////////
+
+//Creates hex encoded HMAC-SHA384 signature of the base64 payload
+func createEthfinexSignature(payload string, secret string) string {
+	mac := hmac.New(sha512.New384, []byte(secret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          UTILITY METHODS                            //
+/////////////////////////////////////////////////////////////////////////
+
+//Converts an Ethfinex order into the shared order type (side 0 = bid, 1 = ask)
+func (order EthfinexOrder) toOrder() (Order) {
+	side := int64(0)
+	if order.Side == "sell" {
+		side = 1
+	}
+	status, statusDesc := int64(1), "New"
+	if order.ExecAmount.IsPositive() {
+		status, statusDesc = 2, "Partially Executed"
+	}
+	return Order{
+		Code: strings.ToUpper(order.Symbol),
+		OrderId: strconv.FormatInt(order.Id, 10),
+		Side: side,
+		Price: order.Price,
+		InitQuantity: order.OrigAmount,
+		RemQuantity: order.RemAmount,
+		Status: status,
+		StatusDesc: statusDesc,
+		Type: 0,
+		Date: strings.Split(order.Timestamp, ".")[0]}
+}
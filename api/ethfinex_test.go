@@ -0,0 +1,147 @@
+package api
+
+import(
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+//Creates an Ethfinex client pointed at a stub server which verifies request signatures
+func SetupEthfinexStub(t *testing.T, handler func(path string, payload map[string]interface{}) (int, string)) (*EthfinexClient, *httptest.Server) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		payload := map[string]interface{}{}
+		if r.Method == "POST" {
+			encoded := r.Header.Get("X-BFX-PAYLOAD")
+			assert.Equal(t, "key", r.Header.Get("X-BFX-APIKEY"))
+			assert.Equal(t, createEthfinexSignature(encoded, "secret"), r.Header.Get("X-BFX-SIGNATURE"))
+			body, _ := ioutil.ReadAll(r.Body)
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			assert.Nil(t, err)
+			assert.Equal(t, string(body), string(decoded))	//payload header must match body
+			assert.Nil(t, json.Unmarshal(body, &payload))
+			assert.Equal(t, r.URL.Path, payload["request"])	//payload must carry request path
+			assert.NotEmpty(t, payload["nonce"])
+		}
+		status, resp := handler(r.URL.Path, payload)
+		w.WriteHeader(status)
+		w.Write([]byte(resp))
+	}))
+	client := NewEthfinexClient("ETHFINEX", "key", "secret")
+	client.SetHost(server.URL)
+	return client, server
+}
+
+func Test_Ethfinex_GetMarketDepth(t *testing.T) {
+	ethfinex, server := SetupEthfinexStub(t, func(path string, payload map[string]interface{}) (int, string) {
+		assert.Equal(t, "/v1/book/ethdai", path)
+		return 200, `{"bids":[{"price":"990.5","amount":"1.5","timestamp":"1515755942.0"}],"asks":[{"price":"1010.5","amount":"2.0","timestamp":"1515755942.0"}]}`
+	})
+	defer server.Close()
+	resp, err := ethfinex.GetMarketDepth(context.Background(), "ETHDAI")
+	assert.Nil(t, err)
+	assert.Equal(t, "OK", resp.Status.Message)
+	assert.Equal(t, []Offer{Offer{dec("990.5"), dec("1.5")}}, resp.Bids)
+	assert.Equal(t, []Offer{Offer{dec("1010.5"), dec("2.0")}}, resp.Asks)
+}
+
+func Test_Ethfinex_GetBalance(t *testing.T) {
+	ethfinex, server := SetupEthfinexStub(t, func(path string, payload map[string]interface{}) (int, string) {
+		assert.Equal(t, "/v1/balances", path)
+		return 200, `[{"type":"deposit","currency":"dai","amount":"50.0","available":"50.0"},{"type":"exchange","currency":"dai","amount":"100.0","available":"80.0"}]`
+	})
+	defer server.Close()
+	resp, err := ethfinex.GetBalance(context.Background(), "DAI")
+	assert.Nil(t, err)
+	assert.Equal(t, "DAI", resp.Balance.Currency)
+	assert.Equal(t, "100", resp.Balance.Balance.String())			//only exchange wallet is counted
+	assert.Equal(t, "80", resp.Balance.AvailableBalance.String())
+	assert.Equal(t, "20", resp.Balance.OpenOrder.String())
+}
+
+func Test_Ethfinex_GetOrders(t *testing.T) {
+	ethfinex, server := SetupEthfinexStub(t, func(path string, payload map[string]interface{}) (int, string) {
+		assert.Equal(t, "/v1/orders", path)
+		return 200, `[{"id":448364249,"symbol":"ethdai","exchange":"ethfinex","price":"990.0","avg_execution_price":"0.0","side":"buy","type":"exchange limit","timestamp":"1515755942.0","is_live":true,"is_cancelled":false,"original_amount":"2.0","remaining_amount":"1.5","executed_amount":"0.5"},
+			{"id":448364250,"symbol":"ethdai","exchange":"ethfinex","price":"1010.0","avg_execution_price":"0.0","side":"sell","type":"exchange limit","timestamp":"1515755943.0","is_live":true,"is_cancelled":false,"original_amount":"1.0","remaining_amount":"1.0","executed_amount":"0.0"}]`
+	})
+	defer server.Close()
+	resp, err := ethfinex.GetOrders(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, resp.Orders, 2)
+	assert.Equal(t, Order{Code: "ETHDAI", OrderId: "448364249", Side: 0, Price: dec("990.0"), InitQuantity: dec("2.0"), RemQuantity: dec("1.5"), Status: 2, StatusDesc: "Partially Executed", Date: "1515755942"}, resp.Orders[0])
+	assert.Equal(t, int64(1), resp.Orders[1].Side)
+}
+
+func Test_Ethfinex_CreateOrder(t *testing.T) {
+	ethfinex, server := SetupEthfinexStub(t, func(path string, payload map[string]interface{}) (int, string) {
+		assert.Equal(t, "/v1/order/new", path)
+		assert.Equal(t, "ethdai", payload["symbol"])
+		assert.Equal(t, "sell", payload["side"])
+		assert.Equal(t, "1.5", payload["amount"])
+		assert.Equal(t, "1010", payload["price"])
+		assert.Equal(t, "exchange limit", payload["type"])
+		return 200, `{"id":448364251,"symbol":"ethdai","price":"1010.0","side":"sell","original_amount":"1.5","remaining_amount":"1.5","executed_amount":"0.0","avg_execution_price":"0.0","timestamp":"1515755943.0"}`
+	})
+	defer server.Close()
+	resp, err := ethfinex.CreateOrder(context.Background(), "ETHDAI", "ask", dec("1.5"), dec("1010.00"))
+	assert.Nil(t, err)
+	assert.Equal(t, "448364251", resp.OrderId)
+}
+
+func Test_Ethfinex_DeleteOrder(t *testing.T) {
+	ethfinex, server := SetupEthfinexStub(t, func(path string, payload map[string]interface{}) (int, string) {
+		assert.Equal(t, "/v1/order/cancel", path)
+		assert.Equal(t, 448364251.0, payload["order_id"])
+		return 400, `{"message":"Order could not be cancelled."}`
+	})
+	defer server.Close()
+	_, err := ethfinex.DeleteOrder(context.Background(), "448364251")
+	assert.EqualError(t, err, "Order could not be cancelled.")		//api error message is surfaced
+	_, err = ethfinex.DeleteOrder(context.Background(), "not-a-number")
+	assert.Error(t, err)
+}
+
+//Test Ethfinex errors, which carry no code, match the sentinels of their class
+func Test_Ethfinex_ErrorClasses(t *testing.T) {
+	responses := map[string]string{
+		"/v1/order/new": `{"message":"Invalid order: not enough exchange balance for 1.0 ETHUSD at 1000.0"}`,
+		"/v1/orders": `{"message":"Could not find a key matching the given X-BFX-APIKEY."}`,
+	}
+	statuses := map[string]int{"/v1/order/new": 400, "/v1/orders": 401}
+	ethfinex, server := SetupEthfinexStub(t, func(path string, payload map[string]interface{}) (int, string) {
+		return statuses[path], responses[path]
+	})
+	defer server.Close()
+	ethfinex.SetRateLimits(registry.RateLimits{})
+	_, err := ethfinex.CreateOrder(context.Background(), "ETHDAI", "bid", dec("1"), dec("1000"))
+	assert.True(t, errors.Is(err, ErrInsufficientBalance))
+	assert.False(t, errors.Is(err, ErrInvalidPrice))
+	assert.Equal(t, ErrorInsufficientFunds, ClassOf(err))
+	_, err = ethfinex.GetOrders(context.Background())
+	assert.True(t, errors.Is(err, ErrAuthFailed))
+	assert.False(t, errors.Is(err, ErrOrderNotFound))
+
+	//order rejections as Ethfinex words them
+	rejections := []struct {
+		body		string
+		sentinel	error
+	}{
+		{`{"message":"Invalid order: minimum size for ETHUSD is 0.04"}`, ErrInvalidAmount},
+		{`{"message":"Key amount should be a decimal number, e.g. \"123.456\""}`, ErrInvalidAmount},
+		{`{"message":"Key price should be a decimal number, e.g. \"123.456\""}`, ErrInvalidPrice},
+		{`{"message":"Invalid order type."}`, ErrInvalidOrder},
+	}
+	for _, rejection := range rejections {
+		responses["/v1/order/new"] = rejection.body
+		_, err = ethfinex.CreateOrder(context.Background(), "ETHDAI", "bid", dec("1"), dec("1000"))
+		assert.True(t, errors.Is(err, rejection.sentinel), rejection.body)
+		assert.False(t, errors.Is(err, ErrInsufficientBalance), rejection.body)
+	}
+}
@@ -0,0 +1,63 @@
+package api
+
+import(
+	"github.com/shopspring/decimal"
+)
+
+type EthfinexErrorResponse struct {
+	Message 	string 		`json:"message"`
+}
+
+type EthfinexBalance struct {
+	Type 		string 			`json:"type"`
+	Currency 	string 			`json:"currency"`
+	Amount 		decimal.Decimal `json:"amount"`
+	Available 	decimal.Decimal `json:"available"`
+}
+
+type EthfinexOrder struct {
+	Id 				int64 			`json:"id"`
+	Symbol 			string 			`json:"symbol"`
+	Exchange 		string 			`json:"exchange"`
+	Price 			decimal.Decimal `json:"price"`
+	AvgPrice 		decimal.Decimal `json:"avg_execution_price"`
+	Side 			string 			`json:"side"`
+	Type 			string 			`json:"type"`
+	Timestamp 		string 			`json:"timestamp"`
+	IsLive 			bool 			`json:"is_live"`
+	IsCancelled 	bool 			`json:"is_cancelled"`
+	OrigAmount 		decimal.Decimal `json:"original_amount"`
+	RemAmount 		decimal.Decimal `json:"remaining_amount"`
+	ExecAmount 		decimal.Decimal `json:"executed_amount"`
+}
+
+type EthfinexNewOrder struct {
+	EthfinexPayload
+	Symbol 		string 	`json:"symbol"`
+	Amount 		string 	`json:"amount"`
+	Price 		string 	`json:"price"`
+	Exchange 	string 	`json:"exchange"`
+	Side 		string 	`json:"side"`
+	Type 		string 	`json:"type"`
+}
+
+type EthfinexCancelOrder struct {
+	EthfinexPayload
+	OrderId 	int64 	`json:"order_id"`
+}
+
+type EthfinexPayload struct {
+	Request 	string 	`json:"request"`
+	Nonce 		string 	`json:"nonce"`
+}
+
+type EthfinexBook struct {
+	Bids 	[]EthfinexOffer 	`json:"bids"`
+	Asks 	[]EthfinexOffer 	`json:"asks"`
+}
+
+type EthfinexOffer struct {
+	Price 		decimal.Decimal `json:"price"`
+	Amount 		decimal.Decimal `json:"amount"`
+	Timestamp 	string 			`json:"timestamp"`
+}
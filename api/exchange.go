@@ -0,0 +1,37 @@
+package api
+
+import(
+	"context"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
+)
+
+//Exchange is the set of venue operations the market maker relies on.
+//Every venue adapter returns the shared response types of this package so
+//the maker loop never needs to know which venue it is quoting on.
+//Requests end when ctx is done, venue clients bound every call by a default timeout.
+type Exchange interface {
+	GetName() string										//Name of the venue, used for logging and registry lookups
+	GetTokenPairName(pair string) string					//Venue specific symbol of a registry token pair
+	GetTokenPairPrecision(pair string) registry.Precision	//Venue specific price and amount precision of a token pair
+	GetMarketDepth(ctx context.Context, pair string) (*MarketDepthResponse, error)
+	GetBalances(ctx context.Context) (*BalancesResponse, error)
+	GetBalance(ctx context.Context, currency string) (*BalanceResponse, error)
+	GetOrders(ctx context.Context) (*GetOrdersResponse, error)
+	CreateOrder(ctx context.Context, pair string, way string, amount decimal.Decimal, price decimal.Decimal) (*CreateOrderResponse, error)	//Amount in base token, both already rounded to the pair's precision
+	DeleteOrder(ctx context.Context, id string) (*KillOrderResponse, error)
+}
+
+//Compile time checks that venue clients satisfy Exchange
+var _ Exchange = (*GatecoinClient)(nil)
+var _ Exchange = (*EthfinexClient)(nil)
+var _ Exchange = (*SimulatedClient)(nil)
+
+//TradesExchange is implemented by venues which publish their recent trades
+type TradesExchange interface {
+	GetTransactions(ctx context.Context, pair string) (*TransactionsResponse, error)
+}
+
+var _ TradesExchange = (*GatecoinClient)(nil)
+var _ RateLimited = (*GatecoinClient)(nil)
+var _ RateLimited = (*EthfinexClient)(nil)
@@ -0,0 +1,266 @@
+package api
+
+import(
+	"errors"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
+)
+
+//GatecoinEmulator is a local stand-in for api.gatecoin.com. It serves the public and
+//private endpoints used by GatecoinClient, verifies request signatures and keeps
+//orders and balances in a SimulatedClient so tests can run full maker cycles offline.
+type GatecoinEmulator struct {
+	URL 			string 						//Base url to point GatecoinClient at
+	Exchange 		*SimulatedClient 			//Order and balance state behind the private endpoints
+	server 			*httptest.Server
+	key 			string
+	secret 			string
+	mutex 			sync.Mutex
+	tickers 		[]Ticker
+	depth 			map[string]*MarketDepthResponse
+	transactions 	map[string][]Transaction
+	failures 		int 						//Requests left to reject with failureStatus
+	failureStatus 	int
+	drops 			int 						//Requests left to handle without responding
+}
+
+func NewGatecoinEmulator(key, secret string, balances map[string]float64) (*GatecoinEmulator) {
+	emulator := &GatecoinEmulator{
+		Exchange: NewSimulatedClient("GATECOIN", balances),
+		key: key,
+		secret: secret,
+		depth: make(map[string]*MarketDepthResponse),
+		transactions: make(map[string][]Transaction)}
+	emulator.server = httptest.NewServer(http.HandlerFunc(emulator.handle))
+	emulator.URL = emulator.server.URL
+	return emulator
+}
+
+//Returns a client with the emulator's credentials pointed at the emulator
+func (emulator *GatecoinEmulator) NewClient() (*GatecoinClient) {
+	client := NewGatecoinClient("GATECOIN", emulator.key, emulator.secret)
+	client.SetHost(emulator.URL)
+	//emulator has no rate limit
+	client.SetRateLimits(registry.RateLimits{})
+	return client
+}
+
+func (emulator *GatecoinEmulator) Close() {
+	emulator.server.Close()
+}
+
+func (emulator *GatecoinEmulator) SetTickers(tickers []Ticker) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.tickers = tickers
+}
+
+//Sets the public order book of a pair. Without one the emulator serves its resting orders.
+func (emulator *GatecoinEmulator) SetMarketDepth(pair string, asks []Offer, bids []Offer) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.depth[pair] = &MarketDepthResponse{Asks: asks, Bids: bids}
+}
+
+func (emulator *GatecoinEmulator) SetTransactions(pair string, transactions []Transaction) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.transactions[pair] = transactions
+}
+
+//Rejects the next count requests with the HTTP status without handling them,
+//429 responds as rate limited and other statuses as a server error
+func (emulator *GatecoinEmulator) FailRequests(count int, status int) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.failures, emulator.failureStatus = count, status
+}
+
+//Handles the next count requests but closes the connection instead of responding,
+//like a connection lost after the exchange acted on the request
+func (emulator *GatecoinEmulator) DropResponses(count int) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	emulator.drops = count
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                              ROUTING                                //
+/////////////////////////////////////////////////////////////////////////
+
+func (emulator *GatecoinEmulator) handle(w http.ResponseWriter, r *http.Request) {
+	emulator.mutex.Lock()
+	fail, drop := emulator.failures > 0, emulator.failures == 0 && emulator.drops > 0
+	if fail {
+		emulator.failures--
+	} else if drop {
+		emulator.drops--
+	}
+	failureStatus := emulator.failureStatus
+	emulator.mutex.Unlock()
+
+	if fail {
+		code := ""
+		if failureStatus == http.StatusTooManyRequests {
+			code = GatecoinErrorRateLimited
+		}
+		emulator.writeError(w, failureStatus, code, http.StatusText(failureStatus))
+		return
+	}
+	if drop {
+		emulator.route(httptest.NewRecorder(), r)
+		if conn, _, err := w.(http.Hijacker).Hijack(); err == nil {
+			conn.Close()
+		}
+		return
+	}
+	emulator.route(w, r)
+}
+
+func (emulator *GatecoinEmulator) route(w http.ResponseWriter, r *http.Request) {
+	path := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if path[0] == "Public" {
+		emulator.handlePublic(w, r, path[1:])
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	if !emulator.verifySignature(r) {
+		emulator.writeError(w, http.StatusUnauthorized, GatecoinErrorAuth, "Invalid API signature")
+		return
+	}
+	switch {
+	case len(path) >= 2 && path[0] == "Balance" && path[1] == "Balances":
+		emulator.handleBalances(w, r, path[2:])
+	case len(path) >= 2 && path[0] == "Trade" && path[1] == "Orders":
+		emulator.handleOrders(w, r, path[2:], body)
+	default:
+		emulator.writeError(w, http.StatusNotFound, GatecoinErrorNotFound, "Unknown endpoint")
+	}
+}
+
+func (emulator *GatecoinEmulator) handlePublic(w http.ResponseWriter, r *http.Request, path []string) {
+	emulator.mutex.Lock()
+	defer emulator.mutex.Unlock()
+	ok := ResponseStatus{Message: "OK"}
+	switch {
+	case len(path) == 1 && path[0] == "LiveTickers":
+		emulator.writeJSON(w, TickersResponse{Tickers: emulator.tickers, Status: ok})
+	case len(path) == 2 && path[0] == "MarketDepth":
+		if depth, found := emulator.depth[path[1]]; found {
+			emulator.writeJSON(w, MarketDepthResponse{Asks: depth.Asks, Bids: depth.Bids, Status: ok})
+			return
+		}
+		depth, _ := emulator.Exchange.GetMarketDepth(r.Context(), path[1])
+		emulator.writeJSON(w, depth)
+	case len(path) == 2 && path[0] == "Transactions":
+		emulator.writeJSON(w, TransactionsResponse{Transactions: emulator.transactions[path[1]], Status: ok})
+	default:
+		emulator.writeError(w, http.StatusNotFound, GatecoinErrorNotFound, "Unknown endpoint")
+	}
+}
+
+func (emulator *GatecoinEmulator) handleBalances(w http.ResponseWriter, r *http.Request, path []string) {
+	if len(path) == 0 {
+		balances, _ := emulator.Exchange.GetBalances(r.Context())
+		emulator.writeJSON(w, balances)
+		return
+	}
+	balance, _ := emulator.Exchange.GetBalance(r.Context(), path[0])
+	emulator.writeJSON(w, balance)
+}
+
+func (emulator *GatecoinEmulator) handleOrders(w http.ResponseWriter, r *http.Request, path []string, body []byte) {
+	switch {
+	case r.Method == "GET" && len(path) == 0:
+		orders, _ := emulator.Exchange.GetOrders(r.Context())
+		emulator.writeJSON(w, orders)
+	case r.Method == "GET" && len(path) == 1:
+		orders, _ := emulator.Exchange.GetOrders(r.Context())
+		for _, order := range orders.Orders {
+			if order.OrderId == path[0] {
+				emulator.writeJSON(w, GetOrderResponse{Order: order, Status: ResponseStatus{Message: "OK"}})
+				return
+			}
+		}
+		emulator.writeError(w, http.StatusOK, GatecoinErrorNotFound, "Order not found")
+	case r.Method == "POST" && len(path) == 0:
+		order := NewOrder{}
+		if err := json.Unmarshal(body, &order); err != nil {
+			emulator.writeError(w, http.StatusBadRequest, GatecoinErrorInvalidOrder, err.Error())
+			return
+		}
+		amount, err := decimal.NewFromString(order.Amount)
+		if err != nil {
+			emulator.writeError(w, http.StatusOK, GatecoinErrorInvalidAmount, fmt.Sprintf("Invalid order amount %s", order.Amount))
+			return
+		}
+		price, err := decimal.NewFromString(order.Price)
+		if err != nil {
+			emulator.writeError(w, http.StatusOK, GatecoinErrorInvalidPrice, fmt.Sprintf("Invalid order price %s", order.Price))
+			return
+		}
+		resp, err := emulator.Exchange.CreateOrder(r.Context(), order.Pair, order.Way, amount, price)
+		if err != nil {
+			emulator.writeError(w, http.StatusOK, errorCode(err, GatecoinErrorInvalidOrder), err.Error())
+			return
+		}
+		emulator.writeJSON(w, resp)
+	case r.Method == "DELETE" && len(path) == 1:
+		resp, err := emulator.Exchange.DeleteOrder(r.Context(), path[0])
+		if err != nil {
+			emulator.writeError(w, http.StatusOK, errorCode(err, GatecoinErrorNotFound), err.Error())
+			return
+		}
+		emulator.writeJSON(w, resp)
+	default:
+		emulator.writeError(w, http.StatusNotFound, GatecoinErrorNotFound, "Unknown endpoint")
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          UTILITY METHODS                            //
+/////////////////////////////////////////////////////////////////////////
+
+//Rebuilds the signed message the same way queryPrivate does and compares signatures
+func (emulator *GatecoinEmulator) verifySignature(r *http.Request) (bool) {
+	if r.Header.Get("API_PUBLIC_KEY") != emulator.key {
+		return false
+	}
+	nonce := r.Header.Get("API_REQUEST_DATE")
+	if nonce == "" {
+		return false
+	}
+	contentType := ""
+	if r.Method != "GET" {
+		contentType = "application/json"
+	}
+	msg := r.Method + "http://" + r.Host + r.URL.Path + contentType + nonce
+	return createSignature(msg, emulator.secret) == r.Header.Get("API_REQUEST_SIGNATURE")
+}
+
+//Returns the code of an exchange error, fallback for other errors
+func errorCode(err error, fallback string) (string) {
+	var apiError *Error
+	if errors.As(err, &apiError) && apiError.Code != "" {
+		return apiError.Code
+	}
+	return fallback
+}
+
+func (emulator *GatecoinEmulator) writeJSON(w http.ResponseWriter, resp interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func (emulator *GatecoinEmulator) writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(ErrorResponse{ResponseStatus{ErrorCode: code, Message: message}})
+}
@@ -0,0 +1,123 @@
+package api
+
+import(
+	"context"
+	"sync"
+	"time"
+	"github.com/niklaskunkel/market-maker/registry"
+)
+
+//RateLimiter spaces the requests of a client with token buckets, one for public and one for private requests
+//plus one per endpoint with its own budget. Safe for concurrent use.
+type RateLimiter struct {
+	mutex	sync.Mutex
+	limits	registry.RateLimits
+	buckets	map[string]*bucket
+	metrics	map[string]*WaitMetrics
+	now		func() (time.Time)
+	sleep	func(context.Context, time.Duration) (error)
+}
+
+//WaitMetrics summarises how long the requests to an endpoint waited for their budget
+type WaitMetrics struct {
+	Requests	int64			//Requests made
+	Delayed		int64			//Requests which had to wait
+	TotalWait	time.Duration
+	MaxWait		time.Duration
+}
+
+//RateLimited is implemented by clients which report how long their requests wait
+type RateLimited interface {
+	RateLimitMetrics() map[string]WaitMetrics
+}
+
+type bucket struct {
+	tokens	float64
+	updated	time.Time
+}
+
+func NewRateLimiter(limits registry.RateLimits) (*RateLimiter) {
+	return &RateLimiter{
+		limits: limits,
+		buckets: make(map[string]*bucket),
+		metrics: make(map[string]*WaitMetrics),
+		now: time.Now,
+		sleep: sleepContext}
+}
+
+//Blocks until a request to endpoint fits its budgets and returns how long it waited.
+//Returns the context's error if ctx is done first, the reserved budget is not given back.
+func (limiter *RateLimiter) Wait(ctx context.Context, private bool, endpoint string) (time.Duration, error) {
+	limiter.mutex.Lock()
+	now := limiter.now()
+	scope, budget := "public", limiter.limits.Public
+	if private {
+		scope, budget = "private", limiter.limits.Private
+	}
+	//reserve from every budget which applies, the request waits for the slowest
+	wait := limiter.reserve(scope, budget, now)
+	if endpointBudget, ok := limiter.limits.Endpoints[endpoint]; ok {
+		if endpointWait := limiter.reserve(scope + "/" + endpoint, endpointBudget, now); endpointWait > wait {
+			wait = endpointWait
+		}
+	}
+	metrics, ok := limiter.metrics[endpoint]
+	if !ok {
+		metrics = &WaitMetrics{}
+		limiter.metrics[endpoint] = metrics
+	}
+	metrics.Requests++
+	if wait > 0 {
+		metrics.Delayed++
+		metrics.TotalWait += wait
+		if wait > metrics.MaxWait {
+			metrics.MaxWait = wait
+		}
+	}
+	limiter.mutex.Unlock()
+
+	if wait > 0 {
+		if err := limiter.sleep(ctx, wait); err != nil {
+			return wait, err
+		}
+	}
+	return wait, nil
+}
+
+//Takes a token from a bucket, which may go into debt, and returns how long until the debt is repaid. Caller must hold mutex.
+func (limiter *RateLimiter) reserve(key string, budget registry.RateBudget, now time.Time) (time.Duration) {
+	if budget.Rate <= 0 {
+		return 0
+	}
+	burst := float64(budget.Burst)
+	if burst < 1 {
+		burst = 1
+	}
+	b, ok := limiter.buckets[key]
+	if !ok {
+		//start with a full burst
+		b = &bucket{burst, now}
+		limiter.buckets[key] = b
+	}
+	b.tokens += now.Sub(b.updated).Seconds() * budget.Rate
+	if b.tokens > burst {
+		b.tokens = burst
+	}
+	b.updated = now
+	b.tokens--
+	if b.tokens >= 0 {
+		return 0
+	}
+	return time.Duration(-b.tokens / budget.Rate * float64(time.Second))
+}
+
+//Returns a copy of the wait metrics keyed by endpoint
+func (limiter *RateLimiter) Metrics() (map[string]WaitMetrics) {
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	metrics := make(map[string]WaitMetrics)
+	for endpoint, endpointMetrics := range limiter.metrics {
+		metrics[endpoint] = *endpointMetrics
+	}
+	return metrics
+}
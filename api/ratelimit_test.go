@@ -0,0 +1,95 @@
+package api
+
+import(
+	"context"
+	"sync"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/registry"
+)
+
+//Returns a limiter on a fake clock which advances by the time slept
+func newTestLimiter(limits registry.RateLimits) (*RateLimiter, *time.Time) {
+	now := time.Unix(1515755900, 0)
+	limiter := NewRateLimiter(limits)
+	limiter.now = func() (time.Time) { return now }
+	limiter.sleep = func(ctx context.Context, wait time.Duration) (error) { return nil }
+	return limiter, &now
+}
+
+//Waits on a limiter without deadline
+func wait(limiter *RateLimiter, private bool, endpoint string) (time.Duration) {
+	waited, _ := limiter.Wait(context.Background(), private, endpoint)
+	return waited
+}
+
+func Test_RateLimit_Burst(t *testing.T) {
+	limiter, now := newTestLimiter(registry.RateLimits{Public: registry.RateBudget{Rate: 2, Burst: 3}})
+	for i := 0; i < 3; i++ {
+		assert.Equal(t, time.Duration(0), wait(limiter, false, "MarketDepth"))	//burst
+	}
+	assert.Equal(t, 500 * time.Millisecond, wait(limiter, false, "MarketDepth"))
+	assert.Equal(t, time.Second, wait(limiter, false, "MarketDepth"))			//queued behind the previous request
+	*now = now.Add(3 * time.Second)
+	assert.Equal(t, time.Duration(0), wait(limiter, false, "MarketDepth"))		//refilled
+	assert.Equal(t, time.Duration(0), wait(limiter, true, "Trade/Orders"))		//private budget is unlimited
+}
+
+func Test_RateLimit_Endpoint(t *testing.T) {
+	limiter, _ := newTestLimiter(registry.RateLimits{
+		Private: registry.RateBudget{Rate: 10, Burst: 10},
+		Endpoints: map[string]registry.RateBudget{"Trade/Orders": registry.RateBudget{Rate: 1, Burst: 1}},
+	})
+	assert.Equal(t, time.Duration(0), wait(limiter, true, "Trade/Orders"))
+	assert.Equal(t, time.Second, wait(limiter, true, "Trade/Orders"))		//endpoint budget is the slowest
+	assert.Equal(t, time.Duration(0), wait(limiter, true, "Balance/Balances"))
+
+	metrics := limiter.Metrics()
+	assert.Equal(t, WaitMetrics{Requests: 2, Delayed: 1, TotalWait: time.Second, MaxWait: time.Second}, metrics["Trade/Orders"])
+	assert.Equal(t, WaitMetrics{Requests: 1}, metrics["Balance/Balances"])
+}
+
+//Test concurrent requests are spaced out rather than sent together
+func Test_RateLimit_Concurrent(t *testing.T) {
+	limiter, _ := newTestLimiter(registry.RateLimits{Public: registry.RateBudget{Rate: 1, Burst: 1}})
+	var group sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		group.Add(1)
+		go func() {
+			defer group.Done()
+			wait(limiter, false, "LiveTickers")
+		}()
+	}
+	group.Wait()
+	metrics := limiter.Metrics()["LiveTickers"]
+	assert.Equal(t, int64(10), metrics.Requests)
+	assert.Equal(t, 9 * time.Second, metrics.MaxWait)		//last request waits for the nine before it
+	assert.Equal(t, 45 * time.Second, metrics.TotalWait)
+}
+
+//Test a client waits on its own limiter
+func Test_RateLimit_Client(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	gatecoin.SetRateLimits(registry.RateLimits{Public: registry.RateBudget{Rate: 20, Burst: 1}})
+	start := time.Now()
+	_, err := gatecoin.GetTickers(context.Background())
+	assert.Nil(t, err)
+	_, err = gatecoin.GetTickers(context.Background())
+	assert.Nil(t, err)
+	assert.True(t, time.Since(start) >= 50 * time.Millisecond)
+	assert.Equal(t, int64(2), gatecoin.RateLimitMetrics()["LiveTickers"].Requests)
+}
+
+//Test a wait ends with its context
+func Test_RateLimit_Cancel(t *testing.T) {
+	limiter := NewRateLimiter(registry.RateLimits{Private: registry.RateBudget{Rate: 0.1, Burst: 1}})
+	wait(limiter, true, "Trade/Orders")
+	ctx, cancel := context.WithTimeout(context.Background(), 20 * time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	waited, err := limiter.Wait(ctx, true, "Trade/Orders")
+	assert.Equal(t, context.DeadlineExceeded, err)
+	assert.True(t, waited > 9 * time.Second)
+	assert.True(t, time.Since(start) < time.Second)
+}
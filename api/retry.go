@@ -0,0 +1,74 @@
+package api
+
+import(
+	"context"
+	"math"
+	"math/rand"
+	"time"
+	"github.com/sirupsen/logrus"
+)
+
+//RetryPolicy repeats requests failing with retryable errors, backing off exponentially with jitter
+type RetryPolicy struct {
+	MaxAttempts 	int 			//Attempts including the first, 1 disables retries
+	BaseDelay 		time.Duration 	//Delay before the first retry, doubled for every further retry
+	MaxDelay 		time.Duration 	//Upper bound of the delay before jitter
+	Jitter 			float64 		//Fraction of the delay randomised, 0.5 waits between 50% and 100% of it
+}
+
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 3, BaseDelay: 500 * time.Millisecond, MaxDelay: 5 * time.Second, Jitter: 0.5}
+
+//Overridable for tests
+var retrySleep = sleepContext
+var retryRandom = rand.Float64
+
+//Returns the delay before retrying after the given failed attempt, counting from 1
+func (policy RetryPolicy) Backoff(attempt int) (time.Duration) {
+	delay := float64(policy.BaseDelay) * math.Pow(2, float64(attempt - 1))
+	if policy.MaxDelay > 0 && delay > float64(policy.MaxDelay) {
+		delay = float64(policy.MaxDelay)
+	}
+	jitter := math.Min(math.Max(policy.Jitter, 0), 1)
+	return time.Duration(delay * (1 - jitter * retryRandom()))
+}
+
+//Returns whether a failed request should be repeated. Requests which are not idempotent are only
+//repeated if the exchange cannot have acted on them.
+func (policy RetryPolicy) ShouldRetry(err error, idempotent bool) (bool) {
+	return ClassOf(err).Retryable() && (idempotent || !MaybeProcessed(err))
+}
+
+//Executes request until it succeeds, fails permanently, runs out of attempts or ctx is done
+func (policy RetryPolicy) Do(ctx context.Context, client string, endpoint string, idempotent bool, request func() (interface{}, error)) (interface{}, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := request()
+		if err == nil || attempt >= policy.MaxAttempts || !policy.ShouldRetry(err, idempotent) || ctx.Err() != nil {
+			return resp, err
+		}
+		delay := policy.Backoff(attempt)
+		log.WithFields(logrus.Fields{"client": client, "endpoint": endpoint, "attempt": attempt, "class": ClassOf(err), "delay": delay, "error": err.Error()}).Warn("Retrying failed request")
+		if retrySleep(ctx, delay) != nil {
+			return resp, err
+		}
+	}
+}
+
+//Bounds a call by timeout unless ctx already ends sooner
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+//Sleeps for delay or until ctx is done, returning the context's error in the latter case
+func sleepContext(ctx context.Context, delay time.Duration) (error) {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,301 @@
+package api
+
+import(
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+//SimulatedClient is an in-memory paper trading venue. Resting orders are filled
+//at their own price whenever the market price of their pair crosses them.
+//Balances and orders are kept in decimals, the market price follows a float random walk.
+type SimulatedClient struct {
+	Name		string 				//Name of client
+	mutex		sync.Mutex
+	balances	map[string]*Balance	//Balances keyed by currency
+	orders		map[string]*Order	//Open orders keyed by order id
+	prices		map[string]float64	//Market price keyed by token pair
+	volatility	float64				//Standard deviation of a random walk step as a fraction of price
+	random		*rand.Rand
+	nextId		int64
+	clock		func() (time.Time)	//Dates new orders, replaced in tests to skew the venue's clock
+}
+
+func NewSimulatedClient(name string, balances map[string]float64) (*SimulatedClient) {
+	sim := &SimulatedClient{
+		Name: strings.ToUpper(name),
+		balances: make(map[string]*Balance),
+		orders: make(map[string]*Order),
+		prices: make(map[string]float64),
+		random: rand.New(rand.NewSource(time.Now().UnixNano())),
+		clock: time.Now}
+	for currency, amount := range balances {
+		sim.balances[strings.ToUpper(currency)] = &Balance{Currency: strings.ToUpper(currency), Balance: decimal.NewFromFloat(amount), AvailableBalance: decimal.NewFromFloat(amount), IsDigital: true}
+	}
+	return sim
+}
+
+func (sim *SimulatedClient) GetName() (string) {
+	return sim.Name
+}
+
+//Simulated orders use registry token pair names
+func (sim *SimulatedClient) GetTokenPairName(pair string) (string) {
+	return pair
+}
+
+//Precision of simulated pairs Gatecoin does not list, e.g. the USD reference pairs
+var SimulatedDefaultPrecision = registry.Precision{BIDPRICEPRECISION: 10, ASKPRICEPRECISION: 10, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}
+
+//Simulated venue enforces the same precision as Gatecoin, or SimulatedDefaultPrecision for pairs Gatecoin does not list
+func (sim *SimulatedClient) GetTokenPairPrecision(pair string) (registry.Precision) {
+	if registry.LookupGatecoinTokenPairName(pair) == "" {
+		return SimulatedDefaultPrecision
+	}
+	return registry.LookupGatecoinTokenPairPrecision(pair)
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          MARKET SIMULATION                          //
+/////////////////////////////////////////////////////////////////////////
+
+//Sets the random walk volatility used by Tick and seeds its generator
+func (sim *SimulatedClient) SetVolatility(volatility float64, seed int64) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.volatility = volatility
+	sim.random = rand.New(rand.NewSource(seed))
+}
+
+//Returns the current market price of a token pair
+func (sim *SimulatedClient) GetMarketPrice(pair string) (float64) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	return sim.prices[pair]
+}
+
+//Moves the market price of a token pair and fills every order it crosses
+func (sim *SimulatedClient) SetMarketPrice(pair string, price float64) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	sim.prices[pair] = price
+	sim.match(pair)
+}
+
+//Replays a series of market prices for a token pair in order
+func (sim *SimulatedClient) Replay(pair string, prices []float64) {
+	for _, price := range prices {
+		sim.SetMarketPrice(pair, price)
+	}
+}
+
+//Advances every priced token pair by one random walk step
+func (sim *SimulatedClient) Tick() {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	for pair, price := range sim.prices {
+		sim.prices[pair] = price * math.Exp(sim.volatility * sim.random.NormFloat64())
+		log.WithFields(logrus.Fields{"client": sim.Name, "pair": pair, "price": sim.prices[pair]}).Debug("Simulated market price moved")
+		sim.match(pair)
+	}
+}
+
+//Fills all resting orders of a token pair crossed by its market price. Caller must hold mutex.
+func (sim *SimulatedClient) match(pair string) {
+	marketPrice, ok := sim.prices[pair]
+	if !ok {
+		return
+	}
+	price := decimal.NewFromFloat(marketPrice)
+	base, quote := registry.LookupTokenPair(pair)
+	for id, order := range sim.orders {
+		if order.Code != pair {
+			continue
+		}
+		if order.Side == 0 && price.LessThanOrEqual(order.Price) {
+			//bid filled - pay reserved quote token, receive base token
+			sim.balance(quote).Balance = sim.balance(quote).Balance.Sub(order.QuoteAmount())
+			sim.balance(quote).OpenOrder = sim.balance(quote).OpenOrder.Sub(order.QuoteAmount())
+			sim.balance(base).Balance = sim.balance(base).Balance.Add(order.BaseAmount())
+		} else if order.Side == 1 && price.GreaterThanOrEqual(order.Price) {
+			//ask filled - pay reserved base token, receive quote token
+			sim.balance(base).Balance = sim.balance(base).Balance.Sub(order.BaseAmount())
+			sim.balance(base).OpenOrder = sim.balance(base).OpenOrder.Sub(order.BaseAmount())
+			sim.balance(quote).Balance = sim.balance(quote).Balance.Add(order.QuoteAmount())
+		} else {
+			continue
+		}
+		log.WithFields(logrus.Fields{"client": sim.Name, "pair": pair, "orderId": id, "side": order.Side, "price": order.Price, "quantity": order.RemQuantity, "marketPrice": price}).Info("Simulated order filled")
+		delete(sim.orders, id)
+	}
+	sim.updateAvailable()
+}
+
+//Returns balance of currency, creating an empty one if needed. Caller must hold mutex.
+func (sim *SimulatedClient) balance(currency string) (*Balance) {
+	currency = strings.ToUpper(currency)
+	if _, ok := sim.balances[currency]; !ok {
+		sim.balances[currency] = &Balance{Currency: currency, IsDigital: true}
+	}
+	return sim.balances[currency]
+}
+
+//Recomputes available balances from reserved amounts. Caller must hold mutex.
+func (sim *SimulatedClient) updateAvailable() {
+	for _, balance := range sim.balances {
+		balance.AvailableBalance = balance.Balance.Sub(balance.OpenOrder)
+	}
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          PUBLIC API METHODS                         //
+/////////////////////////////////////////////////////////////////////////
+
+//Returns the resting orders of a token pair aggregated by price level
+func (sim *SimulatedClient) GetMarketDepth(ctx context.Context, pair string) (*MarketDepthResponse, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	//price levels keyed by their canonical string, equal decimals may differ in exponent
+	asks := make(map[string]*Offer)
+	bids := make(map[string]*Offer)
+	for _, order := range sim.orders {
+		if order.Code != pair {
+			continue
+		}
+		levels := asks
+		if order.Side == 0 {
+			levels = bids
+		}
+		level, ok := levels[order.Price.String()]
+		if !ok {
+			level = &Offer{Price: order.Price, Volume: decimal.Zero}
+			levels[order.Price.String()] = level
+		}
+		level.Volume = level.Volume.Add(order.RemQuantity)
+	}
+	depth := &MarketDepthResponse{Status: ResponseStatus{Message: "OK"}}
+	for _, level := range asks {
+		depth.Asks = append(depth.Asks, *level)
+	}
+	for _, level := range bids {
+		depth.Bids = append(depth.Bids, *level)
+	}
+	sort.Slice(depth.Asks, func(i, j int) bool { return depth.Asks[i].Price.LessThan(depth.Asks[j].Price) })
+	sort.Slice(depth.Bids, func(i, j int) bool { return depth.Bids[i].Price.GreaterThan(depth.Bids[j].Price) })
+	return depth, nil
+}
+
+/////////////////////////////////////////////////////////////////////////
+//                          PRIVATE API METHODS                        //
+/////////////////////////////////////////////////////////////////////////
+
+func (sim *SimulatedClient) GetBalances(ctx context.Context) (*BalancesResponse, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	resp := &BalancesResponse{Status: ResponseStatus{Message: "OK"}}
+	for _, balance := range sim.balances {
+		resp.Balances = append(resp.Balances, *balance)
+	}
+	sort.Slice(resp.Balances, func(i, j int) bool { return resp.Balances[i].Currency < resp.Balances[j].Currency })
+	return resp, nil
+}
+
+func (sim *SimulatedClient) GetBalance(ctx context.Context, currency string) (*BalanceResponse, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	return &BalanceResponse{Balance: *sim.balance(currency), Status: ResponseStatus{Message: "OK"}}, nil
+}
+
+func (sim *SimulatedClient) GetOrders(ctx context.Context) (*GetOrdersResponse, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	resp := &GetOrdersResponse{Status: ResponseStatus{Message: "OK"}}
+	for _, order := range sim.orders {
+		resp.Orders = append(resp.Orders, *order)
+	}
+	sort.Slice(resp.Orders, func(i, j int) bool { return resp.Orders[i].TxSeqNo < resp.Orders[j].TxSeqNo })
+	return resp, nil
+}
+
+func (sim *SimulatedClient) CreateOrder(ctx context.Context, pair string, way string, amount decimal.Decimal, price decimal.Decimal) (*CreateOrderResponse, error) {
+	//price denominated in quote / base
+	//amount denominated in base
+	if !amount.IsPositive() {
+		return nil, &Error{Code: GatecoinErrorInvalidAmount, Message: fmt.Sprintf("Invalid order amount %s", amount)}
+	}
+	if !price.IsPositive() {
+		return nil, &Error{Code: GatecoinErrorInvalidPrice, Message: fmt.Sprintf("Invalid order price %s", price)}
+	}
+	if _, ok := registry.TokenPairRegistry[pair]; !ok {
+		return nil, &Error{Code: GatecoinErrorInvalidOrder, Message: fmt.Sprintf("Unknown token pair %s", pair)}
+	}
+	var side int64
+	switch strings.ToLower(way) {
+	case "bid":
+		side = 0
+	case "ask":
+		side = 1
+	default:
+		return nil, &Error{Code: GatecoinErrorInvalidOrder, Message: fmt.Sprintf("Invalid order way %s", way)}
+	}
+
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	//reserve funds for order
+	base, quote := registry.LookupTokenPair(pair)
+	reserved, reserve := sim.balance(base), amount
+	if side == 0 {
+		reserved, reserve = sim.balance(quote), amount.Mul(price)
+	}
+	if reserve.GreaterThan(reserved.AvailableBalance) {
+		log.WithFields(logrus.Fields{"client": sim.Name, "pair": pair, "way": way, "amount": amount, "price": price, "available": reserved.AvailableBalance}).Error("Insufficient funds for simulated order")
+		return nil, &Error{Code: GatecoinErrorInsufficientFunds, Message: "Insufficient funds"}
+	}
+	reserved.OpenOrder = reserved.OpenOrder.Add(reserve)
+	sim.updateAvailable()
+
+	sim.nextId++
+	id := fmt.Sprintf("SIM%08d", sim.nextId)
+	sim.orders[id] = &Order{
+		Code: pair,
+		OrderId: id,
+		Side: side,
+		Price: price,
+		InitQuantity: amount,
+		RemQuantity: amount,
+		Status: 1,
+		StatusDesc: "New",
+		TxSeqNo: sim.nextId,
+		Date: strconv.FormatInt(sim.clock().Unix(), 10)}
+	//orders crossing the market price fill immediately
+	sim.match(pair)
+	return &CreateOrderResponse{OrderId: id, Status: ResponseStatus{Message: "OK"}}, nil
+}
+
+func (sim *SimulatedClient) DeleteOrder(ctx context.Context, id string) (*KillOrderResponse, error) {
+	sim.mutex.Lock()
+	defer sim.mutex.Unlock()
+	order, ok := sim.orders[id]
+	if !ok {
+		return nil, &Error{Code: GatecoinErrorNotFound, Message: fmt.Sprintf("Order %s not found", id)}
+	}
+	//release reserved funds
+	base, quote := registry.LookupTokenPair(order.Code)
+	if order.Side == 0 {
+		sim.balance(quote).OpenOrder = sim.balance(quote).OpenOrder.Sub(order.QuoteAmount())
+	} else {
+		sim.balance(base).OpenOrder = sim.balance(base).OpenOrder.Sub(order.BaseAmount())
+	}
+	delete(sim.orders, id)
+	sim.updateAvailable()
+	return &KillOrderResponse{Status: ResponseStatus{Message: "OK"}}, nil
+}
@@ -0,0 +1,90 @@
+package api
+
+import(
+	"context"
+	"testing"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Simulator_CreateOrderReservesFunds(t *testing.T) {
+	sim := NewSimulatedClient("SIMULATOR", map[string]float64{"ETH": 10.0, "DAI": 1000.0})
+	resp, err := sim.CreateOrder(context.Background(), "ETHDAI", "bid", dec("0.5"), dec("900"))
+	assert.Nil(t, err)
+	assert.NotEqual(t, "", resp.OrderId)
+	dai, _ := sim.GetBalance(context.Background(), "DAI")
+	assert.Equal(t, "1000", dai.Balance.Balance.String())
+	assert.Equal(t, "550", dai.Balance.AvailableBalance.String())	//450 DAI reserved by bid
+	assert.Equal(t, "450", dai.Balance.OpenOrder.String())
+	_, err = sim.CreateOrder(context.Background(), "ETHDAI", "bid", dec("1"), dec("900"))
+	assert.EqualError(t, err, "Insufficient funds")			//only 550 DAI left
+}
+
+func Test_Simulator_DeleteOrderReleasesFunds(t *testing.T) {
+	sim := NewSimulatedClient("SIMULATOR", map[string]float64{"ETH": 10.0})
+	resp, err := sim.CreateOrder(context.Background(), "ETHDAI", "ask", dec("4"), dec("1100"))
+	assert.Nil(t, err)
+	_, err = sim.DeleteOrder(context.Background(), resp.OrderId)
+	assert.Nil(t, err)
+	eth, _ := sim.GetBalance(context.Background(), "ETH")
+	assert.Equal(t, "10", eth.Balance.AvailableBalance.String())
+	orders, _ := sim.GetOrders(context.Background())
+	assert.Empty(t, orders.Orders)
+	_, err = sim.DeleteOrder(context.Background(), resp.OrderId)
+	assert.Error(t, err)									//order no longer exists
+}
+
+func Test_Simulator_MarketPriceFillsCrossedOrders(t *testing.T) {
+	sim := NewSimulatedClient("SIMULATOR", map[string]float64{"ETH": 10.0, "DAI": 1000.0})
+	sim.SetMarketPrice("ETHDAI", 1000.0)
+	bid, _ := sim.CreateOrder(context.Background(), "ETHDAI", "bid", dec("1"), dec("950"))
+	ask, _ := sim.CreateOrder(context.Background(), "ETHDAI", "ask", dec("2"), dec("1050"))
+	sim.Replay("ETHDAI", []float64{990.0, 960.0, 950.0})	//price falls through bid
+	orders, _ := sim.GetOrders(context.Background())
+	assert.Len(t, orders.Orders, 1)
+	assert.Equal(t, ask.OrderId, orders.Orders[0].OrderId)
+	assert.NotEqual(t, bid.OrderId, orders.Orders[0].OrderId)
+	eth, _ := sim.GetBalance(context.Background(), "ETH")
+	dai, _ := sim.GetBalance(context.Background(), "DAI")
+	assert.Equal(t, "11", eth.Balance.Balance.String())
+	assert.Equal(t, "9", eth.Balance.AvailableBalance.String())		//2 ETH still reserved by ask
+	assert.Equal(t, "50", dai.Balance.Balance.String())
+	sim.SetMarketPrice("ETHDAI", 1060.0)					//price rises through ask
+	orders, _ = sim.GetOrders(context.Background())
+	assert.Empty(t, orders.Orders)
+	eth, _ = sim.GetBalance(context.Background(), "ETH")
+	dai, _ = sim.GetBalance(context.Background(), "DAI")
+	assert.Equal(t, "9", eth.Balance.Balance.String())
+	assert.Equal(t, "2150", dai.Balance.Balance.String())
+}
+
+func Test_Simulator_GetMarketDepth(t *testing.T) {
+	sim := NewSimulatedClient("SIMULATOR", map[string]float64{"ETH": 10.0, "DAI": 10000.0})
+	sim.CreateOrder(context.Background(), "ETHDAI", "bid", dec("1"), dec("950"))
+	sim.CreateOrder(context.Background(), "ETHDAI", "bid", dec("2"), dec("950"))
+	sim.CreateOrder(context.Background(), "ETHDAI", "bid", dec("1"), dec("960"))
+	sim.CreateOrder(context.Background(), "ETHDAI", "ask", dec("1"), dec("1050"))
+	depth, err := sim.GetMarketDepth(context.Background(), "ETHDAI")
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"960:1", "950:3"}, offerStrings(depth.Bids))	//best bid first
+	assert.Equal(t, []string{"1050:1"}, offerStrings(depth.Asks))
+}
+
+//Formats offers as price:volume for comparison
+func offerStrings(offers []Offer) ([]string) {
+	formatted := []string{}
+	for _, offer := range offers {
+		formatted = append(formatted, offer.Price.String() + ":" + offer.Volume.String())
+	}
+	return formatted
+}
+
+//Test pairs Gatecoin does not list are simulated with a usable precision instead of whole numbers
+func Test_Simulator_DefaultPrecision(t *testing.T) {
+	sim := NewSimulatedClient("SIMULATOR", map[string]float64{"ETH": 10.0})
+	assert.Equal(t, registry.LookupGatecoinTokenPairPrecision("ETHDAI"), sim.GetTokenPairPrecision("ETHDAI"))
+	precision := sim.GetTokenPairPrecision("ETHUSD")
+	assert.Equal(t, SimulatedDefaultPrecision, precision)
+	assert.Equal(t, "0.1234567891", precision.RoundAskAmount(dec("0.12345678912")).String())
+	assert.Equal(t, "1000.5", precision.RoundAskPrice(dec("1000.5")).String())
+}
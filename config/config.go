@@ -2,49 +2,231 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 	"github.com/niklaskunkel/market-maker/logger"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
 
 //Globals
 var log = logger.InitLogger()
 
+//Environment variables overriding file locations
+const (
+	EnvRoot = "MARKET_MAKER_ROOT"
+	EnvConfigFile = "MARKET_MAKER_CONFIG"
+	EnvCredentialsFile = "MARKET_MAKER_CREDENTIALS"
+	EnvBandsFile = "MARKET_MAKER_BANDS"
+)
+
+//Directory relative file locations are resolved against, see GetRoot
+var Root = os.Getenv(EnvRoot)
+
+//Files loaded by LoadConfig, LoadCredentials and maker.LoadBands, relative to the root or absolute
+var ConfigFile = lookupEnv(EnvConfigFile, "config.json")
+var CredentialsFile = lookupEnv(EnvCredentialsFile, "credentials.json")
+var BandsFile = lookupEnv(EnvBandsFile, "bands.json")
+
+//Constants
+const (
+	DefaultExchange = "GATECOIN"
+	DefaultInterval = 20 * time.Second
+)
+
 type Auth struct {
-	Key		string	`json:"apiKey"`
-	Secret	string 	`json:"apiSecret"`
+	Key			string			`json:"apiKey"`
+	Secret		string 			`json:"apiSecret"`
+	Exchanges	map[string]Auth	`json:"exchanges,omitempty"`	//Credentials of other exchanges keyed by exchange name
 }
 
 type Config struct {
 	SetzerPath	string 		`json:"setzerPath"`
 	ActivePairs	[]string	`json:"ActivePairs"`
+	IntervalSec	int			`json:"intervalSec,omitempty"`	//Delay between market making cycles, defaults to 20
+	Exchanges	map[string]ExchangeConfig	`json:"exchanges,omitempty"`	//Exchange settings keyed by exchange name
+	Pairs		map[string]PairConfig		`json:"pairs,omitempty"`		//Token pair settings keyed by pair
+	Simulation	*Simulation	`json:"simulation,omitempty"`
+	Feeds		map[string]FeedConfig	`json:"feeds"`
+	PriceGuard	PriceGuard	`json:"priceGuard"`
+}
+
+//Settings of an exchange
+type ExchangeConfig struct {
+	ApiUrl		string					`json:"apiUrl,omitempty"`		//Overrides the exchange API base url
+	RateLimits	*registry.RateLimits	`json:"rateLimits,omitempty"`	//Overrides the exchange's default request budgets
+}
+
+//Settings of a token pair, every field is optional
+type PairConfig struct {
+	Exchange	string		`json:"exchange,omitempty"`	//Exchange the pair is quoted on, defaults to GATECOIN
+	Feed		string		`json:"feed,omitempty"`		//Pair whose price feed is the reference price, defaults to the pair itself
+	Risk		RiskLimits	`json:"risk"`
+}
+
+//Limits on the orders placed for a token pair, zero disables a limit
+type RiskLimits struct {
+	MaxOrderAmount	decimal.Decimal	`json:"maxOrderAmount,omitempty"`	//Largest single order denominated in base token
+	MaxOpenOrders	int				`json:"maxOpenOrders,omitempty"`	//Most resting orders per side
+}
+
+//Limits on the reference price beyond which the maker stops quoting a pair
+type PriceGuard struct {
+	MaxAgeSec		int		`json:"maxAgeSec"`		//Halt when the reference price is older than this many seconds, 0 disables
+	MaxJump			float64	`json:"maxJump"`		//Halt when the reference price moved more than this fraction since the last cycle, 0 disables
+	CancelOnHalt	bool	`json:"cancelOnHalt"`	//Cancel all orders of a halted pair instead of leaving them in place
+}
+
+//Reference price definition of a token pair
+type FeedConfig struct {
+	Sources			[]SourceConfig	`json:"sources"`
+	Aggregation		string			`json:"aggregation"`				//How source prices are combined: "median", "mean", "trimmed_mean" or "weighted_mean"
+	MinSources		int				`json:"minSources"`				//Quorum of sources which must return a price and not be rejected as outliers
+	MaxDeviation	float64			`json:"maxDeviation,omitempty"`	//Reject a source deviating more than this fraction from the median of the others, 0 disables
+	Trim			int				`json:"trim,omitempty"`			//Number of prices dropped from each end by trimmed_mean
+}
+
+//Price source of a feed, the fields used depend on the source type
+type SourceConfig struct {
+	Type		string		`json:"type"`					//Source type, e.g. "fixed", "setzer", "gemini", "gdax", "kraken" or "gatecoin"
+	Exchange	string		`json:"exchange,omitempty"`		//Exchange queried by a setzer source
+	Price		float64		`json:"price,omitempty"`		//Price returned by a fixed source
+	Symbol		string		`json:"symbol,omitempty"`		//Exchange specific market symbol, e.g. "ethusd" or "ETH-USD"
+	Url			string		`json:"url,omitempty"`			//Overrides the exchange API base url
+	TimeoutMs	int			`json:"timeoutMs,omitempty"`	//Request timeout in milliseconds
+	Weight		float64		`json:"weight,omitempty"`		//Weight of the source in weighted_mean, defaults to 1
+}
+
+//Paper trading parameters, when present the maker trades against an in-memory exchange
+type Simulation struct {
+	Balances	map[string]float64	`json:"balances"`		//Starting balance of each token
+	Prices		map[string]float64	`json:"prices"`		//Starting market price of each token pair
+	Volatility	float64				`json:"volatility"`	//Random walk step per cycle as a fraction of price
+}
+
+func LoadCredentials(credentials *Auth) (error) {
+	return LoadFile(credentials, CredentialsFile)
+}
+
+//Reads and validates the config file, see Load
+func LoadConfig(config *Config) (error) {
+	loaded, err := Load(false)
+	if loaded != nil {
+		*config = *loaded
+	}
+	return err
 }
 
-func LoadCredentials(credentials *Auth) {
-	LoadFile(credentials, "credentials.json")
-	return
+//Returns the credentials of an exchange, falling back to the top level key and secret
+func (auth *Auth) For(exchange string) (Auth) {
+	for name, exchangeAuth := range auth.Exchanges {
+		if strings.EqualFold(name, exchange) {
+			return exchangeAuth
+		}
+	}
+	return Auth{Key: auth.Key, Secret: auth.Secret}
 }
 
-func LoadConfig(config *Config) {
-	LoadFile(config, "config.json")
-	log.WithFields(logrus.Fields{"SetzerPath": config.SetzerPath, "ActivePairs": config.ActivePairs}).Info("Config Params")
-	return
+//Returns the settings of a token pair with defaults filled in
+func (config *Config) GetPairConfig(pair string) (PairConfig) {
+	pairConfig := config.Pairs[pair]
+	pairConfig.Exchange = strings.ToUpper(pairConfig.Exchange)
+	if pairConfig.Exchange == "" {
+		pairConfig.Exchange = DefaultExchange
+	}
+	pairConfig.Feed = strings.ToUpper(pairConfig.Feed)
+	if pairConfig.Feed == "" {
+		pairConfig.Feed = pair
+	}
+	return pairConfig
 }
 
-func LoadFile(filetype interface{}, filename string) {
-	goPath, ok := os.LookupEnv("GOPATH")
-	if ok != true {
-		log.WithFields(logrus.Fields{"function": "LoadFile"}).Fatal("$GOPATH Env Variable not set")
+//Returns the exchanges active pairs are quoted on, in the order first used
+func (config *Config) GetExchanges() (exchanges []string) {
+	seen := make(map[string]bool)
+	for _, pair := range config.ActivePairs {
+		exchange := config.GetPairConfig(pair).Exchange
+		if !seen[exchange] {
+			seen[exchange] = true
+			exchanges = append(exchanges, exchange)
+		}
 	}
-	filePath := goPath + "/src/github.com/niklaskunkel/market-maker/" + filename
+	return exchanges
+}
+
+//Returns the active pairs quoted on an exchange
+func (config *Config) GetActivePairs(exchange string) (pairs []string) {
+	for _, pair := range config.ActivePairs {
+		if config.GetPairConfig(pair).Exchange == strings.ToUpper(exchange) {
+			pairs = append(pairs, pair)
+		}
+	}
+	return pairs
+}
+
+//Returns the settings of an exchange, keys are matched case insensitively
+func (config *Config) GetExchangeConfig(exchange string) (ExchangeConfig) {
+	for name, exchangeConfig := range config.Exchanges {
+		if strings.EqualFold(name, exchange) {
+			return exchangeConfig
+		}
+	}
+	return ExchangeConfig{}
+}
+
+func (config *Config) GetInterval() (time.Duration) {
+	if config.IntervalSec > 0 {
+		return time.Duration(config.IntervalSec) * time.Second
+	}
+	return DefaultInterval
+}
+
+//Returns filename unchanged if absolute, otherwise its location under the root
+func ResolvePath(filename string) (string) {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(GetRoot(), filename)
+}
+
+//Returns Root if set, otherwise the repository checkout under $GOPATH if there is one, otherwise the working directory
+func GetRoot() (string) {
+	if Root != "" {
+		return Root
+	}
+	if goPath, ok := os.LookupEnv("GOPATH"); ok {
+		repoPath := filepath.Join(goPath, "src", "github.com", "niklaskunkel", "market-maker")
+		if _, err := os.Stat(repoPath); err == nil {
+			return repoPath
+		}
+	}
+	return "."
+}
+
+func lookupEnv(key string, fallback string) (string) {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+//Reads a JSON file, relative paths are resolved against the root
+func LoadFile(filetype interface{}, filename string) (error) {
+	filePath := ResolvePath(filename)
 	raw, err := ioutil.ReadFile(filePath)
 	if err != nil {
-		log.WithFields(logrus.Fields{"function": "LoadFile", "path": filePath, "error": err.Error()}).Fatal("Unable to read file")
+		log.WithFields(logrus.Fields{"function": "LoadFile", "path": filePath, "error": err.Error()}).Error("Unable to read file")
+		return fmt.Errorf("Unable to read %s: %s", filePath, err.Error())
 	}
 	err = json.Unmarshal(raw, filetype)
 	if err != nil {
-		log.WithFields(logrus.Fields{"function": "LoadFile", "file": filename, "json": raw, "error": err.Error()}).Fatal("Unable to parse JSON")
+		log.WithFields(logrus.Fields{"function": "LoadFile", "path": filePath, "error": err.Error()}).Error("Unable to parse JSON")
+		return fmt.Errorf("Unable to parse %s: %s", filePath, err.Error())
 	}
-	return
-}
\ No newline at end of file
+	return nil
+}
@@ -0,0 +1,118 @@
+package config
+
+import(
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+//Test relative paths resolve against the root and absolute paths are kept
+func Test_Config_ResolvePath(t *testing.T) {
+	root := Root
+	defer func() { Root = root }()
+	Root = "/etc/market-maker"
+	assert.Equal(t, "/etc/market-maker/bands.json", ResolvePath("bands.json"))
+	assert.Equal(t, "/etc/market-maker/conf/config.json", ResolvePath("conf/config.json"))
+	assert.Equal(t, "/srv/config.json", ResolvePath("/srv/config.json"))
+}
+
+//Test the working directory is used when neither root nor a $GOPATH checkout exist
+func Test_Config_GetRootDefault(t *testing.T) {
+	root := Root
+	defer func() { Root = root }()
+	Root = ""
+	t.Setenv("GOPATH", t.TempDir())
+	assert.Equal(t, ".", GetRoot())
+	assert.Equal(t, filepath.Join(".", "config.json"), ResolvePath("config.json"))
+}
+
+//Test every problem is reported at once
+func Test_Config_ValidateAllProblems(t *testing.T) {
+	config := &Config{
+		ActivePairs: []string{"ETHDAI", "FOOBAR", "DAIUSD"},
+		Pairs: map[string]PairConfig{
+			"DAIUSD": PairConfig{Exchange: "ethfinex"},
+			"ETHDAI": PairConfig{Exchange: "binance", Risk: RiskLimits{MaxOrderAmount: decimal.NewFromInt(-1)}},
+		},
+		PriceGuard: PriceGuard{MaxJump: -0.1},
+	}
+	problems := Validate(config)
+	assert.Equal(t, []string{
+		"Pair ETHDAI uses unknown exchange BINANCE",
+		"Active pair FOOBAR is not in the token pair registry",
+		"Pair DAIUSD is not traded on ETHFINEX",
+		"Risk limits of ETHDAI must not be negative",
+		"priceGuard limits must not be negative",
+	}, problems.Problems)
+	assert.Nil(t, Validate(&Config{ActivePairs: []string{"ETHDAI"}}).ErrorOrNil())
+}
+
+//Test Load checks bands and credentials alongside the config
+func Test_Config_Load(t *testing.T) {
+	root, configFile, bandsFile, credentialsFile := Root, ConfigFile, BandsFile, CredentialsFile
+	defer func() { Root, ConfigFile, BandsFile, CredentialsFile = root, configFile, bandsFile, credentialsFile }()
+	Root = t.TempDir()
+	ConfigFile, BandsFile, CredentialsFile = "config.json", "bands.json", "credentials.json"
+	os.WriteFile(filepath.Join(Root, "config.json"), []byte(`{"activePairs": ["ETHDAI", "MKRETH"], "intervalSec": 30}`), 0644)
+	os.WriteFile(filepath.Join(Root, "bands.json"), []byte(`{"ETHDAI": {}}`), 0644)
+	os.WriteFile(filepath.Join(Root, "credentials.json"), []byte(`{"apiKey": "key"}`), 0644)
+
+	config, err := Load(true)
+	assert.NotNil(t, config)
+	assert.Equal(t, 30 * time.Second, config.GetInterval())
+	validationError, ok := err.(*ValidationError)
+	assert.True(t, ok)
+	assert.Equal(t, []string{
+		"Active pair MKRETH has no bands in bands.json",
+		"Missing apiKey or apiSecret for GATECOIN in credentials.json",
+	}, validationError.Problems)
+
+	_, err = Load(false)
+	assert.EqualError(t, err, "Invalid config, 1 problems:\n  - Active pair MKRETH has no bands in bands.json")
+
+	os.WriteFile(filepath.Join(Root, "config.json"), []byte(`{"activePairs": [`), 0644)
+	config, err = Load(false)
+	assert.Nil(t, config)
+	assert.Contains(t, err.Error(), "Unable to parse")
+}
+
+func Test_Config_CredentialsFor(t *testing.T) {
+	auth := &Auth{Key: "gk", Secret: "gs", Exchanges: map[string]Auth{"ETHFINEX": Auth{Key: "ek", Secret: "es"}}}
+	assert.Equal(t, Auth{Key: "ek", Secret: "es"}, auth.For("ethfinex"))
+	assert.Equal(t, Auth{Key: "gk", Secret: "gs"}, auth.For("GATECOIN"))
+}
+
+//Test rate limit overrides are parsed and negative budgets rejected
+func Test_Config_RateLimits(t *testing.T) {
+	config := &Config{}
+	err := json.Unmarshal([]byte(`{"activePairs": ["ETHDAI"], "exchanges": {"gatecoin": {"rateLimits": {"private": {"rate": 2, "burst": 4}, "endpoints": {"Trade/Orders": {"rate": -1}}}}}}`), config)
+	assert.Nil(t, err)
+	limits := config.GetExchangeConfig("GATECOIN").RateLimits
+	assert.Equal(t, registry.RateBudget{Rate: 2, Burst: 4}, limits.Private)
+	assert.Equal(t, []string{"Rate limit of gatecoin endpoint Trade/Orders must not be negative"}, Validate(config).Problems)
+}
+
+//Test problems from map settings are reported in a stable order
+func Test_Config_ValidateSortedProblems(t *testing.T) {
+	config := &Config{
+		ActivePairs: []string{"ETHDAI"},
+		Exchanges: map[string]ExchangeConfig{"kraken": ExchangeConfig{}, "binance": ExchangeConfig{}, "coinbase": ExchangeConfig{}},
+		Simulation: &Simulation{Prices: map[string]float64{"MKRETH": 0, "ETHDAI": -1, "DAIUSD": -1}},
+	}
+	expected := []string{
+		"Settings for unknown exchange binance",
+		"Settings for unknown exchange coinbase",
+		"Settings for unknown exchange kraken",
+		"Simulated price of DAIUSD must be positive",
+		"Simulated price of ETHDAI must be positive",
+		"Simulated price of MKRETH must be positive",
+	}
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, expected, Validate(config).Problems)
+	}
+}
@@ -0,0 +1,164 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/sirupsen/logrus"
+)
+
+//ValidationError lists every problem found in the config files so they can be fixed in one pass
+type ValidationError struct {
+	Problems	[]string
+}
+
+func (validationError *ValidationError) Add(format string, args ...interface{}) {
+	validationError.Problems = append(validationError.Problems, fmt.Sprintf(format, args...))
+}
+
+func (validationError *ValidationError) Error() (string) {
+	return fmt.Sprintf("Invalid config, %d problems:\n  - %s", len(validationError.Problems), strings.Join(validationError.Problems, "\n  - "))
+}
+
+//Returns the error if any problem was found, otherwise nil
+func (validationError *ValidationError) ErrorOrNil() (error) {
+	if len(validationError.Problems) == 0 {
+		return nil
+	}
+	return validationError
+}
+
+//Checks run by Validate in addition to its own, registered by packages owning part of the config
+var validators = []func(config *Config, problems *ValidationError) {}
+
+//Adds a check to Validate, e.g. the feed package verifies every active pair has a usable price feed
+func RegisterValidator(validator func(config *Config, problems *ValidationError)) {
+	validators = append(validators, validator)
+}
+
+//Reads the config file and validates it together with the bands file and, when requireCredentials is set
+//and the maker is not simulating, the credentials file. The config is returned whenever it could be parsed,
+//the error is then a *ValidationError listing every problem found.
+func Load(requireCredentials bool) (*Config, error) {
+	config := new(Config)
+	if err := LoadFile(config, ConfigFile); err != nil {
+		return nil, err
+	}
+	log.WithFields(logrus.Fields{"SetzerPath": config.SetzerPath, "ActivePairs": config.ActivePairs, "Pairs": config.Pairs, "Feeds": config.Feeds, "PriceGuard": config.PriceGuard}).Info("Config Params")
+
+	problems := Validate(config)
+	//bands are parsed by the maker, only check every active pair has some
+	bands := make(map[string]json.RawMessage)
+	if err := LoadFile(&bands, BandsFile); err != nil {
+		problems.Add("%s", err.Error())
+	} else {
+		for _, pair := range config.ActivePairs {
+			if _, ok := bands[pair]; !ok {
+				problems.Add("Active pair %s has no bands in %s", pair, BandsFile)
+			}
+		}
+	}
+	if requireCredentials && config.Simulation == nil {
+		credentials := new(Auth)
+		if err := LoadCredentials(credentials); err != nil {
+			problems.Add("%s", err.Error())
+		} else {
+			for _, exchange := range config.GetExchanges() {
+				auth := credentials.For(exchange)
+				if auth.Key == "" || auth.Secret == "" {
+					problems.Add("Missing apiKey or apiSecret for %s in %s", exchange, CredentialsFile)
+				}
+			}
+		}
+	}
+	if err := problems.ErrorOrNil(); err != nil {
+		log.WithFields(logrus.Fields{"function": "Load", "problems": problems.Problems}).Error("Invalid config")
+		return config, err
+	}
+	return config, nil
+}
+
+//Checks the config on its own and returns every problem found
+func Validate(config *Config) (*ValidationError) {
+	problems := &ValidationError{}
+	if len(config.ActivePairs) == 0 {
+		problems.Add("No active pairs")
+	}
+	if config.IntervalSec < 0 {
+		problems.Add("intervalSec must not be negative")
+	}
+	seen := make(map[string]bool)
+	for _, pair := range config.ActivePairs {
+		if seen[pair] {
+			problems.Add("Active pair %s is listed twice", pair)
+		}
+		seen[pair] = true
+		if _, ok := registry.TokenPairRegistry[pair]; !ok {
+			problems.Add("Active pair %s is not in the token pair registry", pair)
+			continue
+		}
+		pairConfig := config.GetPairConfig(pair)
+		if _, ok := registry.ExchangeRateLimitRegistry[pairConfig.Exchange]; !ok {
+			problems.Add("Pair %s uses unknown exchange %s", pair, pairConfig.Exchange)
+		} else if config.Simulation == nil && registry.LookupExchangeTokenPairName(pairConfig.Exchange, pair) == "" {
+			problems.Add("Pair %s is not traded on %s", pair, pairConfig.Exchange)
+		}
+		if _, ok := registry.TokenPairRegistry[pairConfig.Feed]; !ok {
+			problems.Add("Pair %s uses feed of unknown pair %s", pair, pairConfig.Feed)
+		}
+	}
+	for _, pair := range sortedKeys(config.Pairs) {
+		if _, ok := registry.TokenPairRegistry[pair]; !ok {
+			problems.Add("Pair settings for %s which is not in the token pair registry", pair)
+		}
+		risk := config.Pairs[pair].Risk
+		if risk.MaxOrderAmount.IsNegative() || risk.MaxOpenOrders < 0 {
+			problems.Add("Risk limits of %s must not be negative", pair)
+		}
+	}
+	for _, exchange := range sortedKeys(config.Exchanges) {
+		exchangeConfig := config.Exchanges[exchange]
+		if _, ok := registry.ExchangeRateLimitRegistry[strings.ToUpper(exchange)]; !ok {
+			problems.Add("Settings for unknown exchange %s", exchange)
+		}
+		if limits := exchangeConfig.RateLimits; limits != nil {
+			if negativeBudget(limits.Public) || negativeBudget(limits.Private) {
+				problems.Add("Rate limits of %s must not be negative", exchange)
+			}
+			for _, endpoint := range sortedKeys(limits.Endpoints) {
+				if negativeBudget(limits.Endpoints[endpoint]) {
+					problems.Add("Rate limit of %s endpoint %s must not be negative", exchange, endpoint)
+				}
+			}
+		}
+	}
+	if config.PriceGuard.MaxAgeSec < 0 || config.PriceGuard.MaxJump < 0 {
+		problems.Add("priceGuard limits must not be negative")
+	}
+	if config.Simulation != nil {
+		for _, pair := range sortedKeys(config.Simulation.Prices) {
+			if config.Simulation.Prices[pair] <= 0 {
+				problems.Add("Simulated price of %s must be positive", pair)
+			}
+		}
+	}
+	for _, validator := range validators {
+		validator(config, problems)
+	}
+	return problems
+}
+
+//Problems are reported in key order so validation output is deterministic
+func sortedKeys[V any](values map[string]V) (keys []string) {
+	for key, _ := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func negativeBudget(budget registry.RateBudget) (bool) {
+	return budget.Rate < 0 || budget.Burst < 0
+}
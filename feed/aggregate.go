@@ -0,0 +1,137 @@
+package feed
+
+import(
+	"fmt"
+	"math"
+	"sort"
+	"time"
+	"github.com/sirupsen/logrus"
+)
+
+//Reading is a price returned by one source of a feed
+type Reading struct {
+	Source	string
+	Price	float64
+	Weight	float64
+	Time	time.Time	//When the price was set by the source
+}
+
+//Aggregation methods keyed by the name used in config.json
+var aggregators = map[string]func(feed *Feed, readings []Reading) float64 {
+	"median": func(feed *Feed, readings []Reading) float64 { return GetMedian(prices(readings)) },
+	"mean": func(feed *Feed, readings []Reading) float64 { return GetMean(prices(readings)) },
+	"trimmed_mean": func(feed *Feed, readings []Reading) float64 { return GetTrimmedMean(prices(readings), feed.Trim) },
+	"weighted_mean": func(feed *Feed, readings []Reading) float64 { return GetWeightedMean(readings) },
+}
+
+//Returns the middle price, or the average of the two middle prices for an even number of prices
+func GetMedian(prices []float64) (float64) {
+	length := len(prices)
+	if length == 0 {
+		return 0
+	}
+	sorted := append([]float64{}, prices...)
+	sort.Float64s(sorted)
+	if length % 2 == 1 {
+		return sorted[length / 2]
+	}
+	return (sorted[length / 2 - 1] + sorted[length / 2]) / 2
+}
+
+//Returns the average of prices
+func GetMean(prices []float64) (float64) {
+	if len(prices) == 0 {
+		return 0
+	}
+	sum := 0.0
+	for _, price := range prices {
+		sum += price
+	}
+	return sum / float64(len(prices))
+}
+
+//Returns the average of prices after dropping the trim lowest and trim highest prices.
+//Falls back to the median when trimming would leave no prices.
+func GetTrimmedMean(prices []float64, trim int) (float64) {
+	if trim < 0 || 2 * trim >= len(prices) {
+		return GetMedian(prices)
+	}
+	sorted := append([]float64{}, prices...)
+	sort.Float64s(sorted)
+	return GetMean(sorted[trim:len(sorted) - trim])
+}
+
+//Returns the average of prices weighted by the weight of their source
+func GetWeightedMean(readings []Reading) (float64) {
+	sum, totalWeight := 0.0, 0.0
+	for _, reading := range readings {
+		sum += reading.Price * reading.Weight
+		totalWeight += reading.Weight
+	}
+	if totalWeight <= 0 {
+		return 0
+	}
+	return sum / totalWeight
+}
+
+//Splits readings into those within maxDeviation of the median of the other readings and outliers.
+//Two readings which disagree are both rejected as there is no majority to tell which is wrong.
+//A maxDeviation of zero disables outlier rejection, a single reading has nothing to deviate from.
+func RejectOutliers(readings []Reading, maxDeviation float64) (accepted []Reading, rejected []Reading) {
+	if maxDeviation <= 0 || len(readings) < 2 {
+		return readings, nil
+	}
+	for i, reading := range readings {
+		others := append(prices(readings[:i]), prices(readings[i+1:])...)
+		reference := medianOfOthers(others, reading.Price)
+		if math.Abs(reading.Price - reference) > maxDeviation * reference {
+			rejected = append(rejected, reading)
+		} else {
+			accepted = append(accepted, reading)
+		}
+	}
+	return accepted, rejected
+}
+
+//Rejects outliers, enforces quorum and aggregates the remaining readings.
+//The time returned is that of the oldest reading used.
+func (feed *Feed) Aggregate(readings []Reading) (float64, time.Time, error) {
+	accepted, rejected := RejectOutliers(readings, feed.MaxDeviation)
+	for _, outlier := range rejected {
+		log.WithFields(logrus.Fields{"function": "Aggregate", "pair": feed.Pair, "source": outlier.Source, "price": outlier.Price, "maxDeviation": feed.MaxDeviation}).Warn("Rejected outlier price")
+	}
+	if len(accepted) < feed.MinSources {
+		log.WithFields(logrus.Fields{"function": "Aggregate", "pair": feed.Pair, "readings": len(readings), "accepted": len(accepted), "minSources": feed.MinSources}).Error("Price feed quorum not met")
+		return 0, time.Time{}, fmt.Errorf("Price feed quorum not met for %s: %d of %d required sources agree", feed.Pair, len(accepted), feed.MinSources)
+	}
+	oldest := accepted[0].Time
+	for _, reading := range accepted {
+		if reading.Time.Before(oldest) {
+			oldest = reading.Time
+		}
+	}
+	return aggregators[feed.Aggregation](feed, accepted), oldest, nil
+}
+
+//Returns the median of the other prices. Of two middle prices the one closer to price is used,
+//so a single outlier among the others can't drag the reference away from the agreeing prices.
+func medianOfOthers(others []float64, price float64) (float64) {
+	length := len(others)
+	sorted := append([]float64{}, others...)
+	sort.Float64s(sorted)
+	if length % 2 == 1 {
+		return sorted[length / 2]
+	}
+	low, high := sorted[length / 2 - 1], sorted[length / 2]
+	if math.Abs(price - low) <= math.Abs(price - high) {
+		return low
+	}
+	return high
+}
+
+func prices(readings []Reading) (values []float64) {
+	for _, reading := range readings {
+		values = append(values, reading.Price)
+	}
+	return values
+}
@@ -0,0 +1,77 @@
+package feed
+
+import(
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_Aggregate_Median(t *testing.T) {
+	assert.Equal(t, 200.0, GetMedian([]float64{500.0, 10.0, 200.0}))	//odd count
+	assert.Equal(t, 150.0, GetMedian([]float64{500.0, 10.0, 200.0, 100.0}))	//even count
+	assert.Equal(t, 7.0, GetMedian([]float64{7.0}))
+	assert.Equal(t, 0.0, GetMedian([]float64{}))
+	prices := []float64{3.0, 1.0, 2.0}
+	GetMedian(prices)
+	assert.Equal(t, []float64{3.0, 1.0, 2.0}, prices)	//input is not sorted in place
+}
+
+func Test_Aggregate_TrimmedMean(t *testing.T) {
+	assert.Equal(t, 1000.0, GetTrimmedMean([]float64{1.0, 990.0, 1000.0, 1010.0, 5000.0}, 1))
+	assert.Equal(t, 995.0, GetTrimmedMean([]float64{990.0, 1000.0}, 1))	//falls back to median
+}
+
+func Test_Aggregate_WeightedMean(t *testing.T) {
+	readings := []Reading{Reading{"a", 1000.0, 3.0, time.Time{}}, Reading{"b", 1040.0, 1.0, time.Time{}}}
+	assert.Equal(t, 1010.0, GetWeightedMean(readings))
+	assert.Equal(t, 0.0, GetWeightedMean(nil))
+}
+
+//Test a single bad source is rejected instead of skewing the price
+func Test_Aggregate_RejectOutliers(t *testing.T) {
+	readings := []Reading{Reading{"a", 1000.0, 1, time.Time{}}, Reading{"b", 1005.0, 1, time.Time{}}, Reading{"c", 1500.0, 1, time.Time{}}}
+	accepted, rejected := RejectOutliers(readings, 0.05)
+	assert.Equal(t, readings[:2], accepted)
+	assert.Equal(t, []Reading{readings[2]}, rejected)
+	accepted, rejected = RejectOutliers(readings, 0)
+	assert.Equal(t, readings, accepted)	//disabled
+	assert.Empty(t, rejected)
+}
+
+//Test two sources are compared with each other rather than with their own midpoint
+func Test_Aggregate_RejectOutliersTwoSources(t *testing.T) {
+	readings := []Reading{Reading{"a", 1000.0, 1, time.Time{}}, Reading{"b", 1040.0, 1, time.Time{}}}
+	accepted, rejected := RejectOutliers(readings, 0.05)
+	assert.Equal(t, readings, accepted)	//4% apart
+	assert.Empty(t, rejected)
+	readings[1].Price = 1070.0			//7% apart but within 5% of their 1035 midpoint
+	accepted, rejected = RejectOutliers(readings, 0.05)
+	assert.Empty(t, accepted)			//no majority, neither is trusted
+	assert.Equal(t, readings, rejected)
+	accepted, rejected = RejectOutliers(readings[:1], 0.05)
+	assert.Equal(t, readings[:1], accepted)	//nothing to compare with
+	assert.Empty(t, rejected)
+}
+
+//Test quorum counts only sources which agree
+func Test_Aggregate_Quorum(t *testing.T) {
+	feed := &Feed{Pair: "ETHDAI", Aggregation: "mean", MinSources: 2, MaxDeviation: 0.05}
+	price, _, err := feed.Aggregate([]Reading{Reading{"a", 1000.0, 1, time.Time{}}, Reading{"b", 1010.0, 1, time.Time{}}, Reading{"c", 2000.0, 1, time.Time{}}})
+	assert.Nil(t, err)
+	assert.Equal(t, 1005.0, price)
+	_, _, err = feed.Aggregate([]Reading{Reading{"a", 1000.0, 1, time.Time{}}, Reading{"b", 2000.0, 1, time.Time{}}})
+	assert.EqualError(t, err, "Price feed quorum not met for ETHDAI: 0 of 2 required sources agree")
+}
+
+//Test the aggregated price is as old as the oldest reading used
+func Test_Aggregate_Time(t *testing.T) {
+	now := time.Now()
+	feed := &Feed{Pair: "ETHDAI", Aggregation: "median", MinSources: 1, MaxDeviation: 0.05}
+	_, priceTime, err := feed.Aggregate([]Reading{
+		Reading{"a", 1000.0, 1, now.Add(-10 * time.Second)},
+		Reading{"b", 1010.0, 1, now},
+		Reading{"c", 2000.0, 1, now.Add(-time.Hour)},	//outlier is not used
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, now.Add(-10 * time.Second), priceTime)
+}
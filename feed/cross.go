@@ -0,0 +1,145 @@
+package feed
+
+import(
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/sirupsen/logrus"
+)
+
+//CrossLeg is one step of a cross rate, Invert is set when the leg is walked from quote to base
+type CrossLeg struct {
+	Feed	*Feed
+	Invert	bool
+}
+
+//CrossFeed derives the price of a pair without a direct feed by chaining the feeds of other pairs,
+//e.g. MKRBTC = MKRETH * ETHBTC or MKRETH = MKRUSD / ETHUSD
+type CrossFeed struct {
+	Pair	string
+	Legs	[]CrossLeg
+}
+
+//Pair fed directly and the direction it is walked in
+type crossStep struct {
+	pair	string
+	invert	bool
+}
+
+//Returns the direct feed of a pair if one is configured, otherwise a cross feed
+func NewPriceFeed(pair string, CONFIG *config.Config) (PriceFeed, error) {
+	pair = strings.ToUpper(pair)
+	if _, ok := CONFIG.Feeds[pair]; ok {
+		return NewFeed(pair, CONFIG)
+	}
+	return NewCrossFeed(pair, CONFIG)
+}
+
+//Finds the shortest chain of directly fed registry pairs from the base to the quote token of pair
+func NewCrossFeed(pair string, CONFIG *config.Config) (*CrossFeed, error) {
+	pair = strings.ToUpper(pair)
+	base, quote := registry.LookupTokenPair(pair)
+	if base == "" || quote == "" {
+		return nil, fmt.Errorf("Unknown token pair %s", pair)
+	}
+	//breadth first search over tokens, edges are pairs with a direct feed
+	fedPairs := []string{}
+	for fedPair, _ := range CONFIG.Feeds {
+		if _, ok := registry.TokenPairRegistry[fedPair]; ok {
+			fedPairs = append(fedPairs, fedPair)
+		}
+	}
+	sort.Strings(fedPairs)
+	type step struct {
+		token	string
+		legs	[]crossStep
+	}
+	visited := map[string]bool{base: true}
+	queue := []step{step{base, nil}}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		if current.token == quote {
+			return newCrossFeed(pair, current.legs, CONFIG)
+		}
+		for _, fedPair := range fedPairs {
+			legBase, legQuote := registry.LookupTokenPair(fedPair)
+			next, invert := "", false
+			if legBase == current.token {
+				next = legQuote
+			} else if legQuote == current.token {
+				next, invert = legBase, true
+			} else {
+				continue
+			}
+			if visited[next] {
+				continue
+			}
+			visited[next] = true
+			legs := append(append([]crossStep{}, current.legs...), crossStep{fedPair, invert})
+			queue = append(queue, step{next, legs})
+		}
+	}
+	log.WithFields(logrus.Fields{"function": "NewCrossFeed", "pair": pair}).Error("No price feed or cross rate path configured for pair")
+	return nil, fmt.Errorf("No price feed configured for %s", pair)
+}
+
+func newCrossFeed(pair string, steps []crossStep, CONFIG *config.Config) (*CrossFeed, error) {
+	crossFeed := &CrossFeed{Pair: pair}
+	path := []string{}
+	for _, step := range steps {
+		legFeed, err := NewFeed(step.pair, CONFIG)
+		if err != nil {
+			return nil, err
+		}
+		crossFeed.Legs = append(crossFeed.Legs, CrossLeg{legFeed, step.invert})
+		if step.invert {
+			path = append(path, "1/" + step.pair)
+		} else {
+			path = append(path, step.pair)
+		}
+	}
+	log.WithFields(logrus.Fields{"function": "NewCrossFeed", "pair": pair, "path": strings.Join(path, " * ")}).Debug("Derived cross rate path")
+	return crossFeed, nil
+}
+
+//Multiplies the prices of all legs, dividing by inverted legs
+func (crossFeed *CrossFeed) GetPrice(ctx context.Context) (float64, error) {
+	price, _, err := crossFeed.GetTimedPrice(ctx)
+	return price, err
+}
+
+//Multiplies the prices of all legs, dividing by inverted legs. The time returned is that of the oldest leg.
+func (crossFeed *CrossFeed) GetTimedPrice(ctx context.Context) (float64, time.Time, error) {
+	price, oldest := 1.0, time.Time{}
+	for _, leg := range crossFeed.Legs {
+		legPrice, legTime, err := leg.Feed.GetTimedPrice(ctx)
+		if err != nil {
+			log.WithFields(logrus.Fields{"function": "GetPrice", "pair": crossFeed.Pair, "leg": leg.Feed.Pair, "error": err.Error()}).Error("Cross rate leg failed to fetch price")
+			return 0, time.Time{}, err
+		}
+		if !validPrice(legPrice) {
+			log.WithFields(logrus.Fields{"function": "GetPrice", "pair": crossFeed.Pair, "leg": leg.Feed.Pair, "price": legPrice}).Error("Cross rate leg returned invalid price")
+			return 0, time.Time{}, fmt.Errorf("Cross rate leg %s of %s returned invalid price %f", leg.Feed.Pair, crossFeed.Pair, legPrice)
+		}
+		if leg.Invert {
+			price /= legPrice
+		} else {
+			price *= legPrice
+		}
+		if oldest.IsZero() || legTime.Before(oldest) {
+			oldest = legTime
+		}
+	}
+	//dividing by a tiny leg or multiplying huge ones can still overflow
+	if !validPrice(price) {
+		log.WithFields(logrus.Fields{"function": "GetPrice", "pair": crossFeed.Pair}).Error("Cross rate is not a finite positive number")
+		return 0, time.Time{}, fmt.Errorf("Cross rate of %s is not a finite positive number", crossFeed.Pair)
+	}
+	log.WithFields(logrus.Fields{"function": "GetPrice", "pair": crossFeed.Pair, "price": price, "time": oldest}).Debug("Derived cross rate price")
+	return price, oldest, nil
+}
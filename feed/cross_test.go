@@ -0,0 +1,84 @@
+package feed
+
+import(
+	"context"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+//Creates a config with a single stub source feed per pair
+func crossConfig(prices map[string]string) (*config.Config) {
+	CONFIG := &config.Config{Feeds: make(map[string]config.FeedConfig)}
+	for pair, price := range prices {
+		CONFIG.Feeds[pair] = config.FeedConfig{Sources: []config.SourceConfig{config.SourceConfig{Type: "stub", Exchange: price}}, Aggregation: "mean", MinSources: 1}
+	}
+	return CONFIG
+}
+
+//Test MKRBTC is derived as MKRETH * ETHBTC
+func Test_Cross_Multiply(t *testing.T) {
+	feed, err := NewPriceFeed("MKRBTC", crossConfig(map[string]string{"MKRETH": "0.5", "ETHBTC": "0.08"}))
+	assert.Nil(t, err)
+	price, err := feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.InDelta(t, 0.04, price, 1e-12)
+}
+
+//Test MKRETH is derived as MKRUSD / ETHUSD
+func Test_Cross_Divide(t *testing.T) {
+	feed, err := NewPriceFeed("MKRETH", crossConfig(map[string]string{"MKRUSD": "500", "ETHUSD": "1000"}))
+	assert.Nil(t, err)
+	crossFeed := feed.(*CrossFeed)
+	assert.Len(t, crossFeed.Legs, 2)
+	assert.False(t, crossFeed.Legs[0].Invert)	//MKR -> USD
+	assert.True(t, crossFeed.Legs[1].Invert)	//USD -> ETH
+	price, err := feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 0.5, price)
+}
+
+//Test direct feed is preferred over a cross rate
+func Test_Cross_DirectFeed(t *testing.T) {
+	feed, err := NewPriceFeed("MKRETH", crossConfig(map[string]string{"MKRETH": "0.6", "MKRUSD": "500", "ETHUSD": "1000"}))
+	assert.Nil(t, err)
+	_, ok := feed.(*Feed)
+	assert.True(t, ok)
+	price, _ := feed.GetPrice(context.Background())
+	assert.Equal(t, 0.6, price)
+}
+
+//Test shortest path is used when several exist
+func Test_Cross_ShortestPath(t *testing.T) {
+	feed, err := NewCrossFeed("MKRBTC", crossConfig(map[string]string{"MKRETH": "0.5", "ETHBTC": "0.08", "MKRUSD": "500", "ETHUSD": "1000", "DAIUSD": "1", "ETHDAI": "1000"}))
+	assert.Nil(t, err)
+	assert.Len(t, feed.Legs, 2)
+}
+
+//Test pairs with no path are rejected
+func Test_Cross_NoPath(t *testing.T) {
+	_, err := NewPriceFeed("MKRBTC", crossConfig(map[string]string{"ETHBTC": "0.08", "DAIUSD": "1"}))
+	assert.EqualError(t, err, "No price feed configured for MKRBTC")
+	_, err = NewPriceFeed("XYZABC", crossConfig(map[string]string{"ETHBTC": "0.08"}))
+	assert.EqualError(t, err, "Unknown token pair XYZABC")
+}
+
+//Test failing leg fails the cross rate
+func Test_Cross_LegFails(t *testing.T) {
+	feed, err := NewPriceFeed("MKRBTC", crossConfig(map[string]string{"MKRETH": "0.5", "ETHBTC": "fail"}))
+	assert.Nil(t, err)
+	_, err = feed.GetPrice(context.Background())
+	assert.Error(t, err)
+}
+
+//Test a zero leg or an overflowing rate is an error rather than +Inf
+func Test_Cross_InvalidLeg(t *testing.T) {
+	feed, err := NewPriceFeed("MKRETH", crossConfig(map[string]string{"MKRUSD": "500", "ETHUSD": "0"}))
+	assert.Nil(t, err)
+	_, err = feed.GetPrice(context.Background())
+	assert.Error(t, err)
+	feed, err = NewPriceFeed("MKRETH", crossConfig(map[string]string{"MKRUSD": "500", "ETHUSD": "1e-320"}))
+	assert.Nil(t, err)
+	_, err = feed.GetPrice(context.Background())
+	assert.EqualError(t, err, "Cross rate of MKRETH is not a finite positive number")
+}
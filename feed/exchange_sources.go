@@ -0,0 +1,224 @@
+package feed
+
+import(
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"github.com/niklaskunkel/market-maker/api"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/sirupsen/logrus"
+)
+
+//Constants
+const (
+	GeminiAPIHostUrl = "https://api.gemini.com"
+	GdaxAPIHostUrl = "https://api.gdax.com"
+	KrakenAPIHostUrl = "https://api.kraken.com"
+	DefaultSourceTimeout = 5000 * time.Millisecond
+)
+
+//ExchangeSource fetches the last traded price of a market from a public exchange API
+type ExchangeSource struct {
+	Name		string
+	Host		string
+	Symbol		string
+	client		*http.Client
+	parse		func(ctx context.Context, source *ExchangeSource) (float64, time.Time, error)
+}
+
+func newExchangeSource(name string, host string, pair string, source config.SourceConfig, parse func(context.Context, *ExchangeSource) (float64, time.Time, error)) (PriceSource, error) {
+	if source.Symbol == "" {
+		return nil, fmt.Errorf("%s price source for %s requires a symbol", name, pair)
+	}
+	if source.Url != "" {
+		host = source.Url
+	}
+	timeout := DefaultSourceTimeout
+	if source.TimeoutMs > 0 {
+		timeout = time.Duration(source.TimeoutMs) * time.Millisecond
+	}
+	return &ExchangeSource{name, strings.TrimRight(host, "/"), source.Symbol, &http.Client{Timeout: timeout}, parse}, nil
+}
+
+func NewGeminiSource(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	return newExchangeSource("gemini", GeminiAPIHostUrl, pair, source, parseGemini)
+}
+
+func NewGdaxSource(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	return newExchangeSource("gdax", GdaxAPIHostUrl, pair, source, parseGdax)
+}
+
+func NewKrakenSource(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	return newExchangeSource("kraken", KrakenAPIHostUrl, pair, source, parseKraken)
+}
+
+func (source *ExchangeSource) GetName() (string) {
+	return source.Name
+}
+
+func (source *ExchangeSource) GetPrice(ctx context.Context) (float64, error) {
+	price, _, err := source.GetTimedPrice(ctx)
+	return price, err
+}
+
+//Returns the last traded price and its time if the exchange reports one
+func (source *ExchangeSource) GetTimedPrice(ctx context.Context) (float64, time.Time, error) {
+	price, priceTime, err := source.parse(ctx, source)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	if price <= 0 {
+		return 0, time.Time{}, fmt.Errorf("%s returned invalid price %f for %s", source.Name, price, source.Symbol)
+	}
+	return price, priceTime, nil
+}
+
+//Queries path on the source host and decodes the JSON response into responseType.
+//The request is abandoned when ctx is done or the source timeout passes, whichever is first.
+func (source *ExchangeSource) getJSON(ctx context.Context, path string, responseType interface{}) (error) {
+	reqURL := source.Host + path
+	req, err := http.NewRequestWithContext(ctx, "GET", reqURL, nil)
+	if err != nil {
+		return err
+	}
+	//gdax rejects requests without a user agent
+	req.Header.Set("User-Agent", api.APIUserAgent)
+	req.Header.Add("Accept", "application/json")
+	resp, err := source.client.Do(req)
+	if err != nil {
+		log.WithFields(logrus.Fields{"function": "getJSON", "source": source.Name, "requestURL": reqURL, "error": err.Error()}).Error("Failed to execute price request")
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		log.WithFields(logrus.Fields{"function": "getJSON", "source": source.Name, "requestURL": reqURL, "statusCode": resp.StatusCode, "body": string(body)}).Error("Price request failed")
+		return fmt.Errorf("%s returned status %s", source.Name, resp.Status)
+	}
+	err = json.Unmarshal(body, responseType)
+	if err != nil {
+		log.WithFields(logrus.Fields{"function": "getJSON", "source": source.Name, "body": string(body), "error": err.Error()}).Error("Failed to convert JSON price response into struct")
+		return err
+	}
+	return nil
+}
+
+//GET /v1/pubticker/:symbol -> {"last": "1000.00", "volume": {"timestamp": 1515755942000, ...}, ...}
+func parseGemini(ctx context.Context, source *ExchangeSource) (float64, time.Time, error) {
+	ticker := struct {
+		Last	float64		`json:"last,string"`
+		Volume	struct {
+			Timestamp	int64	`json:"timestamp"`
+		}	`json:"volume"`
+	}{}
+	if err := source.getJSON(ctx, "/v1/pubticker/" + url.PathEscape(strings.ToLower(source.Symbol)), &ticker); err != nil {
+		return 0, time.Time{}, err
+	}
+	priceTime := time.Time{}
+	if ticker.Volume.Timestamp > 0 {
+		priceTime = time.Unix(0, ticker.Volume.Timestamp * int64(time.Millisecond))
+	}
+	return ticker.Last, priceTime, nil
+}
+
+//GET /products/:product/ticker -> {"price": "1000.00", "time": "2018-01-12T11:19:02.000Z", ...}
+func parseGdax(ctx context.Context, source *ExchangeSource) (float64, time.Time, error) {
+	ticker := struct {
+		Price	float64		`json:"price,string"`
+		Time	time.Time	`json:"time"`
+	}{}
+	if err := source.getJSON(ctx, "/products/" + url.PathEscape(strings.ToUpper(source.Symbol)) + "/ticker", &ticker); err != nil {
+		return 0, time.Time{}, err
+	}
+	return ticker.Price, ticker.Time, nil
+}
+
+//GET /0/public/Ticker?pair=:pair -> {"error": [], "result": {"XETHZUSD": {"c": ["1000.00", "0.1"], ...}}}
+//Kraken does not report the time of the last trade, so the price is stamped when fetched.
+func parseKraken(ctx context.Context, source *ExchangeSource) (float64, time.Time, error) {
+	ticker := struct {
+		Error	[]string	`json:"error"`
+		Result	map[string]struct {
+			Close	[]string	`json:"c"`
+		}	`json:"result"`
+	}{}
+	if err := source.getJSON(ctx, "/0/public/Ticker?pair=" + url.QueryEscape(strings.ToUpper(source.Symbol)), &ticker); err != nil {
+		return 0, time.Time{}, err
+	}
+	if len(ticker.Error) > 0 {
+		return 0, time.Time{}, fmt.Errorf("kraken returned error %s", strings.Join(ticker.Error, ", "))
+	}
+	//kraken answers with its own name for the pair, e.g. ETHUSD -> XETHZUSD
+	for _, market := range ticker.Result {
+		if len(market.Close) > 0 {
+			price, err := strconv.ParseFloat(market.Close[0], 64)
+			return price, time.Time{}, err
+		}
+	}
+	return 0, time.Time{}, fmt.Errorf("kraken returned no ticker for %s", source.Symbol)
+}
+
+///////////////////////////////////
+//         GATECOIN SOURCE
+///////////////////////////////////
+
+//GatecoinSource uses the last price of Gatecoin's LiveTickers
+type GatecoinSource struct {
+	Symbol		string
+	client		*api.GatecoinClient
+}
+
+func NewGatecoinSource(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	symbol := source.Symbol
+	if symbol == "" {
+		symbol = registry.LookupGatecoinTokenPairName(pair)
+	}
+	if symbol == "" {
+		return nil, fmt.Errorf("gatecoin price source for %s requires a symbol", pair)
+	}
+	//public endpoints do not need credentials
+	client := api.NewGatecoinClient("GATECOIN", "", "")
+	if source.Url != "" {
+		client.SetHost(source.Url)
+	}
+	return &GatecoinSource{symbol, client}, nil
+}
+
+func (source *GatecoinSource) GetName() (string) {
+	return "gatecoin"
+}
+
+func (source *GatecoinSource) GetPrice(ctx context.Context) (float64, error) {
+	price, _, err := source.GetTimedPrice(ctx)
+	return price, err
+}
+
+//Returns the last price and the ticker's createDateTime, a unix timestamp in seconds
+func (source *GatecoinSource) GetTimedPrice(ctx context.Context) (float64, time.Time, error) {
+	ctx, cancel := context.WithTimeout(ctx, DefaultSourceTimeout)
+	defer cancel()
+	resp, err := source.client.GetTickers(ctx)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	for _, ticker := range resp.Tickers {
+		if ticker.Pair == source.Symbol && ticker.Last > 0 {
+			priceTime := time.Time{}
+			if seconds, err := strconv.ParseInt(ticker.Time, 10, 64); err == nil && seconds > 0 {
+				priceTime = time.Unix(seconds, 0)
+			}
+			return ticker.Last, priceTime, nil
+		}
+	}
+	return 0, time.Time{}, fmt.Errorf("gatecoin returned no ticker for %s", source.Symbol)
+}
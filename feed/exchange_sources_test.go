@@ -0,0 +1,121 @@
+package feed
+
+import(
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+//Starts a stub server answering requests for path with body
+func SetupPriceStub(t *testing.T, path string, status int, body string) (*httptest.Server) {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, path, r.URL.RequestURI())
+		assert.NotEmpty(t, r.Header.Get("User-Agent"))
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}))
+}
+
+func Test_Sources_Gemini(t *testing.T) {
+	server := SetupPriceStub(t, "/v1/pubticker/ethusd", 200, `{"bid":"999.50","ask":"1000.50","last":"1000.25","volume":{"ETH":"100","USD":"100000","timestamp":1515755942000}}`)
+	defer server.Close()
+	source, err := NewGeminiSource("ETHDAI", config.SourceConfig{Type: "gemini", Symbol: "ethusd", Url: server.URL}, nil)
+	assert.Nil(t, err)
+	price, priceTime, err := source.(TimedPriceSource).GetTimedPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1000.25, price)
+	assert.Equal(t, int64(1515755942), priceTime.Unix())
+}
+
+func Test_Sources_Gdax(t *testing.T) {
+	server := SetupPriceStub(t, "/products/ETH-USD/ticker", 200, `{"trade_id":4729088,"price":"1001.10","size":"0.1","bid":"1001.09","ask":"1001.10","volume":"5000","time":"2018-01-12T11:19:02.000Z"}`)
+	defer server.Close()
+	source, err := NewGdaxSource("ETHDAI", config.SourceConfig{Type: "gdax", Symbol: "ETH-USD", Url: server.URL}, nil)
+	assert.Nil(t, err)
+	price, priceTime, err := source.(TimedPriceSource).GetTimedPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1001.10, price)
+	assert.Equal(t, int64(1515755942), priceTime.Unix())
+}
+
+func Test_Sources_Kraken(t *testing.T) {
+	server := SetupPriceStub(t, "/0/public/Ticker?pair=ETHUSD", 200, `{"error":[],"result":{"XETHZUSD":{"a":["1003.0","1","1.0"],"b":["1002.0","1","1.0"],"c":["1002.50","0.5"]}}}`)
+	defer server.Close()
+	source, err := NewKrakenSource("ETHDAI", config.SourceConfig{Type: "kraken", Symbol: "ETHUSD", Url: server.URL}, nil)
+	assert.Nil(t, err)
+	price, err := source.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1002.50, price)
+}
+
+//Test kraken error list is surfaced
+func Test_Sources_KrakenError(t *testing.T) {
+	server := SetupPriceStub(t, "/0/public/Ticker?pair=ETHUSD", 200, `{"error":["EQuery:Unknown asset pair"]}`)
+	defer server.Close()
+	source, _ := NewKrakenSource("ETHDAI", config.SourceConfig{Type: "kraken", Symbol: "ETHUSD", Url: server.URL}, nil)
+	_, err := source.GetPrice(context.Background())
+	assert.EqualError(t, err, "kraken returned error EQuery:Unknown asset pair")
+}
+
+func Test_Sources_Gatecoin(t *testing.T) {
+	server := SetupPriceStub(t, "/Public/LiveTickers", 200, `{"tickers":[{"currencyPair":"BTCUSD","last":10000.0},{"currencyPair":"ETHDAI","last":1004.0}],"responseStatus":{"message":"OK"}}`)
+	defer server.Close()
+	source, err := NewGatecoinSource("ETHDAI", config.SourceConfig{Type: "gatecoin", Url: server.URL}, nil)
+	assert.Nil(t, err)
+	price, err := source.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1004.0, price)		//symbol defaults to Gatecoin pair name
+}
+
+//Test non 200 responses and unparseable prices are errors
+func Test_Sources_BadResponse(t *testing.T) {
+	server := SetupPriceStub(t, "/v1/pubticker/ethusd", 503, `{"message":"maintenance"}`)
+	defer server.Close()
+	source, _ := NewGeminiSource("ETHDAI", config.SourceConfig{Type: "gemini", Symbol: "ethusd", Url: server.URL}, nil)
+	_, err := source.GetPrice(context.Background())
+	assert.Error(t, err)
+	server = SetupPriceStub(t, "/v1/pubticker/ethusd", 200, `{"last":"not a number"}`)
+	defer server.Close()
+	source, _ = NewGeminiSource("ETHDAI", config.SourceConfig{Type: "gemini", Symbol: "ethusd", Url: server.URL}, nil)
+	_, err = source.GetPrice(context.Background())
+	assert.Error(t, err)
+}
+
+//Test slow sources time out
+func Test_Sources_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.Write([]byte(`{"last":"1000.00"}`))
+	}))
+	defer server.Close()
+	source, _ := NewGeminiSource("ETHDAI", config.SourceConfig{Type: "gemini", Symbol: "ethusd", Url: server.URL, TimeoutMs: 50}, nil)
+	_, err := source.GetPrice(context.Background())
+	assert.Error(t, err)
+}
+
+//Test requests are abandoned when the cycle context is cancelled
+func Test_Sources_Cancelled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50 * time.Millisecond, cancel)
+	for _, newSource := range []func(string, config.SourceConfig, *config.Config) (PriceSource, error){NewGeminiSource, NewGatecoinSource} {
+		source, _ := newSource("ETHDAI", config.SourceConfig{Symbol: "ethusd", Url: server.URL}, nil)
+		start := time.Now()
+		_, err := source.GetPrice(ctx)
+		assert.Error(t, err)
+		assert.True(t, time.Since(start) < time.Second)	//well before the source timeout
+	}
+}
+
+//Test exchange sources require a symbol
+func Test_Sources_MissingSymbol(t *testing.T) {
+	_, err := NewGdaxSource("ETHDAI", config.SourceConfig{Type: "gdax"}, nil)
+	assert.EqualError(t, err, "gdax price source for ETHDAI requires a symbol")
+}
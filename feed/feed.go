@@ -0,0 +1,171 @@
+package feed
+
+import(
+	"context"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/logger"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/sirupsen/logrus"
+)
+
+//Globals
+var log = logger.InitLogger()
+
+//PriceSource fetches a single reference price for the pair it was created for.
+//Reference prices are floats through aggregation and outlier rejection, maker converts them to decimals
+//before any band or order arithmetic.
+type PriceSource interface {
+	GetName() string
+	GetPrice(ctx context.Context) (float64, error)
+}
+
+//TimedPriceSource is implemented by sources which know when their price was set,
+//e.g. the time of the last trade. Prices of other sources are stamped when fetched.
+type TimedPriceSource interface {
+	PriceSource
+	GetTimedPrice(ctx context.Context) (float64, time.Time, error)
+}
+
+//PriceFeed produces the reference price of a token pair
+type PriceFeed interface {
+	GetPrice(ctx context.Context) (float64, error)
+	//Returns the price and the time of the oldest reading it was derived from
+	GetTimedPrice(ctx context.Context) (float64, time.Time, error)
+}
+
+//Constructors of price sources keyed by the source type used in config.json
+var sourceTypes = map[string]func(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	"fixed": NewFixedSource,
+	"setzer": NewSetzerSource,
+	"gemini": NewGeminiSource,
+	"gdax": NewGdaxSource,
+	"kraken": NewKrakenSource,
+	"gatecoin": NewGatecoinSource,
+}
+
+func init() {
+	config.RegisterValidator(ValidateFeeds)
+}
+
+//Checks every active pair has a usable price feed
+func ValidateFeeds(CONFIG *config.Config, problems *config.ValidationError) {
+	for _, pair := range CONFIG.ActivePairs {
+		feedPair := CONFIG.GetPairConfig(pair).Feed
+		if _, ok := registry.TokenPairRegistry[feedPair]; !ok {
+			continue
+		}
+		if _, err := NewPriceFeed(feedPair, CONFIG); err != nil {
+			problems.Add("Price feed of %s: %s", pair, err.Error())
+		}
+	}
+}
+
+//Feed aggregates the prices of several sources into one reference price
+type Feed struct {
+	Pair			string
+	Sources			[]PriceSource
+	Weights			[]float64	//Weight of each source for weighted_mean
+	Aggregation		string
+	MinSources		int			//Quorum of sources which must respond and agree
+	MaxDeviation	float64		//Maximum deviation of a source from the others, as a fraction
+	Trim			int			//Prices dropped from each end for trimmed_mean
+}
+
+//Creates the price feed of a token pair from its definition in config
+func NewFeed(pair string, CONFIG *config.Config) (*Feed, error) {
+	pair = strings.ToUpper(pair)
+	feedConfig, ok := CONFIG.Feeds[pair]
+	if !ok {
+		log.WithFields(logrus.Fields{"function": "NewFeed", "pair": pair}).Error("No price feed configured for pair")
+		return nil, fmt.Errorf("No price feed configured for %s", pair)
+	}
+	if _, ok := aggregators[feedConfig.Aggregation]; !ok {
+		return nil, fmt.Errorf("Unknown aggregation method %q for %s", feedConfig.Aggregation, pair)
+	}
+	if feedConfig.MaxDeviation < 0 || feedConfig.Trim < 0 {
+		return nil, fmt.Errorf("Price feed for %s has negative maxDeviation or trim", pair)
+	}
+	feed := &Feed{Pair: pair, Aggregation: feedConfig.Aggregation, MinSources: feedConfig.MinSources, MaxDeviation: feedConfig.MaxDeviation, Trim: feedConfig.Trim}
+	if feed.MinSources < 1 {
+		feed.MinSources = 1
+	}
+	for _, sourceConfig := range feedConfig.Sources {
+		newSource, ok := sourceTypes[sourceConfig.Type]
+		if !ok {
+			return nil, fmt.Errorf("Unknown price source type %q for %s", sourceConfig.Type, pair)
+		}
+		source, err := newSource(pair, sourceConfig, CONFIG)
+		if err != nil {
+			return nil, err
+		}
+		weight := sourceConfig.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		if weight < 0 {
+			return nil, fmt.Errorf("Price source %s for %s has negative weight", source.GetName(), pair)
+		}
+		feed.Sources = append(feed.Sources, source)
+		feed.Weights = append(feed.Weights, weight)
+	}
+	if len(feed.Sources) < feed.MinSources {
+		return nil, fmt.Errorf("Price feed for %s has %d sources but requires %d", pair, len(feed.Sources), feed.MinSources)
+	}
+	return feed, nil
+}
+
+//Queries every source and aggregates the prices returned
+func (feed *Feed) GetPrice(ctx context.Context) (float64, error) {
+	price, _, err := feed.GetTimedPrice(ctx)
+	return price, err
+}
+
+//Queries every source and aggregates the prices returned, along with the time of the oldest price used
+func (feed *Feed) GetTimedPrice(ctx context.Context) (float64, time.Time, error) {
+	readings := []Reading{}
+	for i, source := range feed.Sources {
+		price, priceTime, err := getTimedPrice(ctx, source)
+		if err == nil && !validPrice(price) {
+			err = fmt.Errorf("%s returned invalid price %f", source.GetName(), price)
+		}
+		if err != nil {
+			log.WithFields(logrus.Fields{"function": "GetPrice", "pair": feed.Pair, "source": source.GetName(), "error": err.Error()}).Error("Price source failed to fetch price")
+			continue
+		}
+		log.WithFields(logrus.Fields{"function": "GetPrice", "pair": feed.Pair, "source": source.GetName(), "price": price, "time": priceTime}).Debug("Price source returned price")
+		readings = append(readings, Reading{source.GetName(), price, feed.Weights[i], priceTime})
+	}
+	if len(readings) == 0 {
+		log.WithFields(logrus.Fields{"function": "GetPrice", "pair": feed.Pair}).Error("No valid price sources")
+		return 0, time.Time{}, fmt.Errorf("No valid price sources")
+	}
+	price, priceTime, err := feed.Aggregate(readings)
+	if err != nil {
+		return 0, time.Time{}, err
+	}
+	log.WithFields(logrus.Fields{"function": "GetPrice", "pair": feed.Pair, "readings": readings, "aggregation": feed.Aggregation, "price": price, "time": priceTime}).Debug("Aggregated feed price")
+	return price, priceTime, nil
+}
+
+//Returns whether a price is finite and positive, sources returning anything else are skipped
+func validPrice(price float64) (bool) {
+	return price > 0 && !math.IsInf(price, 1)
+}
+
+//Fetches the price of a source, stamping it with the current time unless the source knows better
+func getTimedPrice(ctx context.Context, source PriceSource) (float64, time.Time, error) {
+	timedSource, ok := source.(TimedPriceSource)
+	if !ok {
+		price, err := source.GetPrice(ctx)
+		return price, time.Now(), err
+	}
+	price, priceTime, err := timedSource.GetTimedPrice(ctx)
+	if err == nil && priceTime.IsZero() {
+		priceTime = time.Now()
+	}
+	return price, priceTime, err
+}
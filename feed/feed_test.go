@@ -0,0 +1,114 @@
+package feed
+
+import(
+	"context"
+	"fmt"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+//stubSource returns the price given as its name, or an error if the name is not a number
+type stubSource struct {
+	name	string
+}
+
+func (source *stubSource) GetName() (string) {
+	return source.name
+}
+
+func (source *stubSource) GetPrice(ctx context.Context) (float64, error) {
+	var price float64
+	if _, err := fmt.Sscanf(source.name, "%g", &price); err != nil {
+		return 0, fmt.Errorf("stub source failed")
+	}
+	return price, nil
+}
+
+func init() {
+	sourceTypes["stub"] = func(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+		return &stubSource{source.Exchange}, nil
+	}
+}
+
+func stubConfig(aggregation string, minSources int, prices ...string) (*config.Config) {
+	feedConfig := config.FeedConfig{Aggregation: aggregation, MinSources: minSources}
+	for _, price := range prices {
+		feedConfig.Sources = append(feedConfig.Sources, config.SourceConfig{Type: "stub", Exchange: price})
+	}
+	return &config.Config{Feeds: map[string]config.FeedConfig{"ETHDAI": feedConfig}}
+}
+
+//Test fixed source returns its configured price
+func Test_Feed_FixedSource(t *testing.T) {
+	CONFIG := &config.Config{Feeds: map[string]config.FeedConfig{
+		"DAIUSD": config.FeedConfig{Sources: []config.SourceConfig{config.SourceConfig{Type: "fixed", Price: 1.0}}, Aggregation: "mean", MinSources: 1},
+	}}
+	feed, err := NewFeed("daiusd", CONFIG)
+	assert.Nil(t, err)
+	price, err := feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1.0, price)
+}
+
+//Test pair without a feed definition is rejected
+func Test_Feed_NoFeedConfigured(t *testing.T) {
+	_, err := NewFeed("MKRETH", stubConfig("median", 1, "1.0"))
+	assert.EqualError(t, err, "No price feed configured for MKRETH")
+}
+
+//Test invalid feed definitions are rejected
+func Test_Feed_InvalidConfig(t *testing.T) {
+	_, err := NewFeed("ETHDAI", stubConfig("mode", 1, "1.0"))
+	assert.Error(t, err)		//unknown aggregation
+	CONFIG := stubConfig("mean", 1, "1.0")
+	CONFIG.Feeds["ETHDAI"].Sources[0].Type = "oracle"
+	_, err = NewFeed("ETHDAI", CONFIG)
+	assert.Error(t, err)		//unknown source type
+	_, err = NewFeed("ETHDAI", stubConfig("mean", 3, "1.0", "2.0"))
+	assert.Error(t, err)		//fewer sources than minimum
+}
+
+//Test prices of all sources are aggregated
+func Test_Feed_Aggregation(t *testing.T) {
+	feed, err := NewFeed("ETHDAI", stubConfig("mean", 1, "990", "1000", "1040"))
+	assert.Nil(t, err)
+	price, err := feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1010.0, price)
+	feed, err = NewFeed("ETHDAI", stubConfig("median", 3, "990", "1000", "1040"))
+	assert.Nil(t, err)
+	price, err = feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1000.0, price)
+}
+
+//Test feed fails when too few sources respond
+func Test_Feed_MinSources(t *testing.T) {
+	feed, err := NewFeed("ETHDAI", stubConfig("mean", 2, "fail", "fail", "fail"))
+	assert.Nil(t, err)
+	_, err = feed.GetPrice(context.Background())
+	assert.EqualError(t, err, "No valid price sources")
+	feed, err = NewFeed("ETHDAI", stubConfig("mean", 2, "1000", "fail", "fail"))
+	assert.Nil(t, err)
+	_, err = feed.GetPrice(context.Background())
+	assert.EqualError(t, err, "Price feed quorum not met for ETHDAI: 1 of 2 required sources agree")
+	feed, err = NewFeed("ETHDAI", stubConfig("mean", 2, "1000", "fail", "1010"))
+	assert.Nil(t, err)
+	price, err := feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1005.0, price)	//failed source is skipped
+}
+
+//Test zero, negative, NaN and infinite prices of any source are skipped
+func Test_Feed_InvalidPrices(t *testing.T) {
+	feed, err := NewFeed("ETHDAI", stubConfig("mean", 1, "0", "-1", "NaN", "Inf", "1000"))
+	assert.Nil(t, err)
+	price, err := feed.GetPrice(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, 1000.0, price)
+	feed, err = NewFeed("ETHDAI", stubConfig("mean", 1, "0", "NaN", "Inf"))
+	assert.Nil(t, err)
+	_, err = feed.GetPrice(context.Background())
+	assert.EqualError(t, err, "No valid price sources")
+}
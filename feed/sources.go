@@ -0,0 +1,70 @@
+package feed
+
+import(
+	"context"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/sirupsen/logrus"
+)
+
+///////////////////////////////////
+//         FIXED SOURCE
+///////////////////////////////////
+
+//FixedSource always returns the configured price, e.g. DAIUSD = 1.00
+type FixedSource struct {
+	Price	float64
+}
+
+func NewFixedSource(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	if source.Price <= 0 {
+		return nil, fmt.Errorf("Fixed price source for %s requires a positive price", pair)
+	}
+	return &FixedSource{source.Price}, nil
+}
+
+func (source *FixedSource) GetName() (string) {
+	return "fixed"
+}
+
+func (source *FixedSource) GetPrice(ctx context.Context) (float64, error) {
+	return source.Price, nil
+}
+
+///////////////////////////////////
+//         SETZER SOURCE
+///////////////////////////////////
+
+//SetzerSource shells out to `setzer price <exchange>`
+type SetzerSource struct {
+	Path		string
+	Exchange	string
+}
+
+func NewSetzerSource(pair string, source config.SourceConfig, CONFIG *config.Config) (PriceSource, error) {
+	if source.Exchange == "" {
+		return nil, fmt.Errorf("Setzer price source for %s requires an exchange", pair)
+	}
+	return &SetzerSource{CONFIG.SetzerPath, source.Exchange}, nil
+}
+
+func (source *SetzerSource) GetName() (string) {
+	return "setzer-" + source.Exchange
+}
+
+func (source *SetzerSource) GetPrice(ctx context.Context) (float64, error) {
+	out, err := exec.CommandContext(ctx, source.Path, "price", source.Exchange).Output()
+	if err != nil {
+		log.WithFields(logrus.Fields{"function": "GetPrice", "exchange": source.Exchange, "error": err.Error(), "output": string(out)}).Error("Setzer failed to fetch price")
+		return 0, err
+	}
+	price, err := strconv.ParseFloat(strings.TrimSpace(string(out)), 64)
+	if err != nil {
+		log.WithFields(logrus.Fields{"function": "GetPrice", "exchange": source.Exchange, "price": string(out), "error": err.Error()}).Error("Failed to parse price from string to float")
+		return 0, err
+	}
+	return price, nil
+}
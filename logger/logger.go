@@ -1,6 +1,8 @@
 package logger
 
 import(
+	"os"
+	"path/filepath"
 	"github.com/rifflock/lfshook"
 	"github.com/sirupsen/logrus"
 )
@@ -8,7 +10,10 @@ import(
 //Create logger
 var Log *logrus.Logger
 
-//Initialize Logging
+//Environment variable naming the directory log files are written to
+const EnvLogDir = "MARKET_MAKER_LOG_DIR"
+
+//Initialize Logging. Logs go to stderr, and to files as well once a log directory is set.
 func InitLogger() (*logrus.Logger) {
 	if Log != nil {
 		return Log
@@ -17,12 +22,35 @@ func InitLogger() (*logrus.Logger) {
 	//initialize logger
 	Log = logrus.New()
 
+	//Write log files to directory from environment until told otherwise
+	if logDir, ok := os.LookupEnv(EnvLogDir); ok && logDir != "" {
+		SetLogDir(logDir)
+	}
+
+	//Set minimum threshold for logging to debug
+	Log.SetLevel(logrus.DebugLevel)
+
+	return Log
+}
+
+//Routes logs by severity to info.log, error.log and debug.log in dir, replacing any previous log directory.
+//An empty dir disables log files.
+func SetLogDir(dir string) (error) {
+	Log.Hooks = make(logrus.LevelHooks)
+	if dir == "" {
+		return nil
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		Log.WithFields(logrus.Fields{"function": "SetLogDir", "dir": dir, "error": err.Error()}).Error("Unable to create log directory")
+		return err
+	}
+
 	//Create log routing depending on severity
 	pathMap := lfshook.PathMap{
-		logrus.InfoLevel:  "/Users/nkunkel/Programming/Go/logs/info.log",
-		logrus.ErrorLevel: "/Users/nkunkel/Programming/Go/logs/error.log",
-		logrus.FatalLevel: "/Users/nkunkel/Programming/Go/logs/error.log",
-		logrus.DebugLevel: "/Users/nkunkel/Programming/Go/logs/debug.log",
+		logrus.InfoLevel:  filepath.Join(dir, "info.log"),
+		logrus.ErrorLevel: filepath.Join(dir, "error.log"),
+		logrus.FatalLevel: filepath.Join(dir, "error.log"),
+		logrus.DebugLevel: filepath.Join(dir, "debug.log"),
 	}
 
 	//Create formatter
@@ -36,11 +64,7 @@ func InitLogger() (*logrus.Logger) {
 
 	//Add hook
 	Log.AddHook(hook)
-
-	//Set minimum threshold for logging to debug
-	Log.SetLevel(logrus.DebugLevel)
-
-	return Log
+	return nil
 }
 
 func Debug(args ...interface{}) {
@@ -0,0 +1,162 @@
+package main
+
+import(
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/maker"
+	"github.com/niklaskunkel/market-maker/registry"
+)
+
+//Command is an operator subcommand, Args is the number of arguments it takes.
+//Commands which trade set NeedsExchange so credentials are validated and venues built before they run.
+type Command struct {
+	Usage			string
+	Description		string
+	Args			int
+	NeedsExchange	bool
+	Run				func(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error)
+}
+
+//Subcommands keyed by name, a command with several words is keyed by all of them, e.g. "bands validate"
+var commands = map[string]Command {
+	"run": Command{"run", "Make markets on interval until interrupted, then cancel all orders", 0, true, runCommand},
+	"cancel-all": Command{"cancel-all", "Cancel all open orders and verify none remain", 0, true, cancelAllCommand},
+	"cancel-pair": Command{"cancel-pair PAIR", "Cancel all open orders of a token pair", 1, true, cancelPairCommand},
+	"orders": Command{"orders", "Print open orders", 0, true, ordersCommand},
+	"balances": Command{"balances", "Print token balances", 0, true, balancesCommand},
+	"bands validate": Command{"bands validate", "Load and verify the bands file", 0, false, bandsValidateCommand},
+	"config validate": Command{"config validate", "Validate config, bands and credentials and list every problem", 0, true, configValidateCommand},
+	"price": Command{"price PAIR", "Print the reference price of a token pair", 1, false, priceCommand},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags] <command> [args]\n\nCommands:\n", os.Args[0])
+	names := []string{}
+	for name, _ := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", commands[name].Usage, commands[name].Description)
+	}
+	fmt.Fprintf(os.Stderr, "\nFlags:\n")
+	flag.PrintDefaults()
+}
+
+//Finds the command named by the leading arguments and runs it with the rest.
+//The command's context ends on SIGINT or SIGTERM, cancelling its requests in flight.
+func execute(args []string, interval time.Duration) (error) {
+	for words := len(args); words > 0; words-- {
+		command, ok := commands[strings.Join(args[:words], " ")]
+		if !ok {
+			continue
+		}
+		if len(args) - words != command.Args {
+			usage()
+			return fmt.Errorf("Usage: %s", command.Usage)
+		}
+		//Load and validate config
+		CONFIG, err := config.Load(command.NeedsExchange)
+		if err != nil {
+			return err
+		}
+		venues := []Venue{}
+		if command.NeedsExchange {
+			if venues, err = newVenues(CONFIG); err != nil {
+				return err
+			}
+		}
+		if interval == 0 {
+			interval = CONFIG.GetInterval()
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+		defer stop()
+		//Restore default signal handling once cancelled so a second interrupt kills a stuck shutdown
+		context.AfterFunc(ctx, stop)
+		return command.Run(ctx, CONFIG, venues, args[words:], interval)
+	}
+	usage()
+	return fmt.Errorf("Unknown command %q", strings.Join(args, " "))
+}
+
+func runCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	reloader, err := maker.NewReloader(true)
+	if err != nil {
+		return err
+	}
+	run(ctx, reloader, venues, interval)
+	return nil
+}
+
+func cancelAllCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	//Every venue is attempted so one failing exchange does not leave orders resting on the others
+	failures := []error{}
+	for _, venue := range venues {
+		if err := maker.CancelAllOrdersAndVerify(ctx, venue.Exchange, CancelAttempts, CancelRetryDelay); err != nil {
+			failures = append(failures, fmt.Errorf("%s: %w", venue.Exchange.GetName(), err))
+		}
+	}
+	return errors.Join(failures...)
+}
+
+func cancelPairCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	pair := strings.ToUpper(args[0])
+	if _, ok := registry.TokenPairRegistry[pair]; !ok {
+		return fmt.Errorf("Unknown token pair %s", pair)
+	}
+	for _, venue := range venues {
+		maker.CancelTokenPairOrders(ctx, venue.Exchange, pair)
+	}
+	return nil
+}
+
+func ordersCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	for _, venue := range venues {
+		if err := maker.PrintOrderBook(ctx, venue.Exchange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func balancesCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	for _, venue := range venues {
+		if err := maker.PrintBalances(ctx, venue.Exchange); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func configValidateCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	fmt.Println("Config OK")
+	return nil
+}
+
+func bandsValidateCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	allBands := make(maker.AllBands)
+	if !allBands.LoadBands() {
+		return fmt.Errorf("Invalid bands in %s", config.ResolvePath(config.BandsFile))
+	}
+	fmt.Println("Bands OK")
+	return nil
+}
+
+func priceCommand(ctx context.Context, CONFIG *config.Config, venues []Venue, args []string, interval time.Duration) (error) {
+	pair := strings.ToUpper(args[0])
+	price, err := maker.GetFeedPrice(ctx, pair, CONFIG)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("%s %f\n", pair, price)
+	return nil
+}
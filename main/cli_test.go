@@ -0,0 +1,92 @@
+package main
+
+import(
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/niklaskunkel/market-maker/api"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/logger"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+)
+
+//Load config and bands from the fixtures in testdata, the config trades against the simulated exchange
+func TestMain(m *testing.M) {
+	log = logger.InitLogger()
+	root, err := filepath.Abs("testdata")
+	if err != nil {
+		panic(err)
+	}
+	config.Root, config.ConfigFile, config.BandsFile = root, "config.json", "bands.json"
+	os.Exit(m.Run())
+}
+
+//brokenExchange is a simulated venue which cannot list its orders
+type brokenExchange struct {
+	*api.SimulatedClient
+}
+
+func (broken *brokenExchange) GetOrders(ctx context.Context) (*api.GetOrdersResponse, error) {
+	return nil, errors.New("Exchange unavailable")
+}
+
+//Test commands resolve, validate their arguments and report failures
+func Test_Cli_Execute(t *testing.T) {
+	tests := []struct {
+		name		string
+		args		[]string
+		bandsFile	string
+		err			string	//Expected error, empty if the command succeeds
+	}{
+		{"unknown command", []string{"frobnicate"}, "bands.json", `Unknown command "frobnicate"`},
+		{"unknown subcommand", []string{"bands", "frobnicate"}, "bands.json", `Unknown command "bands frobnicate"`},
+		{"cancel-pair without pair", []string{"cancel-pair"}, "bands.json", "Usage: cancel-pair PAIR"},
+		{"cancel-pair unknown pair", []string{"cancel-pair", "FOOBAR"}, "bands.json", "Unknown token pair FOOBAR"},
+		{"cancel-pair", []string{"cancel-pair", "ethdai"}, "bands.json", ""},
+		{"cancel-all", []string{"cancel-all"}, "bands.json", ""},
+		{"bands validate", []string{"bands", "validate"}, "bands.json", ""},
+		{"bands validate bad file", []string{"bands", "validate"}, "bad_bands.json", "Invalid bands in " + filepath.Join(config.GetRoot(), "bad_bands.json")},
+		{"config validate", []string{"config", "validate"}, "bands.json", ""},
+		{"price", []string{"price", "ETHDAI"}, "bands.json", ""},
+		{"price unknown pair", []string{"price", "FOOBAR"}, "bands.json", "Unknown token pair FOOBAR"},
+	}
+	defer func() { config.BandsFile = "bands.json" }()
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config.BandsFile = test.bandsFile
+			err := execute(test.args, time.Second)
+			if test.err == "" {
+				assert.Nil(t, err)
+			} else {
+				assert.EqualError(t, err, test.err)
+			}
+		})
+	}
+}
+
+//Test cancel-all still cancels on the other venues when one fails, and reports the failing one
+func Test_Cli_CancelAllJoinsFailures(t *testing.T) {
+	CONFIG, err := config.Load(true)
+	assert.Nil(t, err)
+	venues, err := newVenues(CONFIG)
+	assert.Nil(t, err)
+	sim := venues[0].Exchange.(*api.SimulatedClient)
+	broken := &brokenExchange{api.NewSimulatedClient("BROKEN", nil)}
+	venues = []Venue{Venue{broken, CONFIG}, venues[0]}
+
+	//leave an order resting on the healthy venue
+	_, err = sim.CreateOrder(context.Background(), "ETHDAI", "bid", decimal.NewFromFloat(1), decimal.NewFromFloat(900))
+	assert.Nil(t, err)
+
+	//the broken venue is not retried once cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err = cancelAllCommand(ctx, CONFIG, venues, nil, time.Second)
+	assert.EqualError(t, err, "BROKEN: Cancellation of all orders interrupted: context canceled")
+	orders, _ := sim.GetOrders(context.Background())
+	assert.Empty(t, orders.Orders)
+}
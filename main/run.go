@@ -1,7 +1,13 @@
 package main 
 
 import(
+	"context"
+	"flag"
 	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"syscall"
 	"time"
 	"github.com/niklaskunkel/market-maker/api"
 	"github.com/niklaskunkel/market-maker/config"
@@ -13,41 +19,230 @@ import(
 //Globals
 var log *logrus.Logger
 
-//Schedules process to execute on interval
-func scheduler(what func(), delay time.Duration) {
+//Constants
+const (
+	CancelAttempts = 5						//Attempts to cancel all orders on shutdown
+	CancelRetryDelay = 2 * time.Second		//Delay between cancellation attempts
+	ReloadPoll = 2 * time.Second			//How often config and bands files are checked for changes
+)
+
+//Schedules process to execute on interval until ctx is done, i.e. a shutdown signal is received.
+//Each run's context ends with the interval so a hung request cannot stall the schedule,
+//a signal arriving mid-run cancels the requests in flight.
+func scheduler(ctx context.Context, what func(ctx context.Context), delay time.Duration) {
 	fmt.Printf("Starting scheduled process on interval %d\n", delay)
 	ticker := time.NewTicker(delay)
-	quit := make(chan bool, 1)
-	go func() {
-		for {
-	       select {
-	        case <- ticker.C:
-	        	what()
-	        case <- quit:
-	            ticker.Stop()
-	            return
-	        }
-	    }
-	 }()
-	 <-quit
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			log.Info("Received shutdown signal")
+			return
+		case <-ticker.C:
+			//prefer a pending signal over starting another run
+			if ctx.Err() != nil {
+				log.Info("Received shutdown signal")
+				return
+			}
+			cycle, cancel := context.WithTimeout(ctx, delay)
+			what(cycle)
+			cancel()
+		}
+	}
 }
 
-func main() {
-	//Initialize Logging
-	log = logger.InitLogger()
+//Venue is an exchange together with the config restricted to the pairs quoted on it
+type Venue struct {
+	Exchange	api.Exchange
+	Config		*config.Config
+}
+
+//Cancels all resting orders on every venue before exiting so no quotes are left unattended.
+//Runs on a fresh context as the one of the market making cycles has been cancelled.
+func shutdown(venues []Venue) {
+	ctx := context.Background()
+	failed := false
+	for _, venue := range venues {
+		log.WithFields(logrus.Fields{"client": venue.Exchange.GetName()}).Info("Shutting down, cancelling all orders...")
+		err := maker.CancelAllOrdersAndVerify(ctx, venue.Exchange, CancelAttempts, CancelRetryDelay)
+		if err != nil {
+			log.WithFields(logrus.Fields{"client": venue.Exchange.GetName(), "error": err.Error()}).Error("Failed to cancel all orders on shutdown")
+			failed = true
+		}
+	}
+	if failed {
+		os.Exit(1)
+	}
+	log.Info("Shutdown complete")
+}
 
-	//Load Config
-	CONFIG := new(config.Config)
-	config.LoadConfig(CONFIG)
+//Builds the venues the maker trades on: the simulator when configured, otherwise one client per exchange used by the active pairs
+func newVenues(CONFIG *config.Config) ([]Venue, error) {
+	//Paper trade against simulated exchange
+	if CONFIG.Simulation != nil {
+		sim := api.NewSimulatedClient("SIMULATOR", CONFIG.Simulation.Balances)
+		sim.SetVolatility(CONFIG.Simulation.Volatility, time.Now().UnixNano())
+		for pair, price := range CONFIG.Simulation.Prices {
+			sim.SetMarketPrice(pair, price)
+		}
+		log.WithFields(logrus.Fields{"balances": CONFIG.Simulation.Balances, "prices": CONFIG.Simulation.Prices}).Info("Using simulated exchange")
+		return []Venue{Venue{sim, CONFIG}}, nil
+	}
 
 	//Load Credentials
 	CREDENTIALS := new(config.Auth)
-	config.LoadCredentials(CREDENTIALS)
+	if err := config.LoadCredentials(CREDENTIALS); err != nil {
+		return nil, err
+	}
+
+	venues := []Venue{}
+	for _, name := range CONFIG.GetExchanges() {
+		auth := CREDENTIALS.For(name)
+		apiUrl := CONFIG.GetExchangeConfig(name).ApiUrl
+		var exchange api.Exchange
+		switch name {
+		case "GATECOIN":
+			client := api.NewGatecoinClient(name, auth.Key, auth.Secret)
+			if apiUrl != "" {
+				client.SetHost(apiUrl)
+			}
+			if limits := CONFIG.GetExchangeConfig(name).RateLimits; limits != nil {
+				client.SetRateLimits(*limits)
+			}
+			exchange = client
+		case "ETHFINEX":
+			client := api.NewEthfinexClient(name, auth.Key, auth.Secret)
+			if apiUrl != "" {
+				client.SetHost(apiUrl)
+			}
+			if limits := CONFIG.GetExchangeConfig(name).RateLimits; limits != nil {
+				client.SetRateLimits(*limits)
+			}
+			exchange = client
+		default:
+			return nil, fmt.Errorf("Unsupported exchange %s", name)
+		}
+		venue := Venue{Exchange: exchange}
+		//quote only the pairs assigned to this exchange
+		venue.Config = venueConfig(CONFIG, venue)
+		venues = append(venues, venue)
+	}
+	return venues, nil
+}
+
+//Executes the market maker on interval until SIGINT/SIGTERM, then cancels all orders.
+//Config and bands are reloaded on file change or SIGHUP, each cycle uses the latest valid version.
+func run(ctx context.Context, reloader *maker.Reloader, venues []Venue, interval time.Duration) {
+	//Handle reload signals, shutdown signals end ctx
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	stop := make(chan struct{})
+	go reloader.Watch(ReloadPoll, hup, stop)
 
-	//Create Gatecoin API Client
-	client := api.NewGatecoinClient("GATECOIN", CREDENTIALS.Key, CREDENTIALS.Secret)
+	scheduler(ctx, func(ctx context.Context) {
+		snapshot := reloader.Current()
+		for _, venue := range venues {
+			//move the simulated market before quoting into it
+			if sim, ok := venue.Exchange.(*api.SimulatedClient); ok {
+				sim.Tick()
+			}
+			maker.MakeMarketsWithSnapshot(ctx, venue.Exchange, venueConfig(snapshot.Config, venue), snapshot)
+			logRateLimits(venue.Exchange)
+		}
+		logPairStatus()
+	}, interval)
+	close(stop)
+	shutdown(venues)
+}
+
+//Logs how long requests of a venue waited on its rate limiter per endpoint
+func logRateLimits(exchange api.Exchange) {
+	limited, ok := exchange.(api.RateLimited)
+	if !ok {
+		return
+	}
+	for endpoint, metrics := range limited.RateLimitMetrics() {
+		if metrics.Delayed == 0 {
+			continue
+		}
+		log.WithFields(logrus.Fields{
+			"exchange": exchange.GetName(),
+			"endpoint": endpoint,
+			"requests": metrics.Requests,
+			"delayed": metrics.Delayed,
+			"totalWait": metrics.TotalWait,
+			"maxWait": metrics.MaxWait,
+		}).Info("Requests delayed by rate limit")
+	}
+}
 
-	//Execute market maker on interval
-	scheduler(func() {maker.MarketMaker(client, CONFIG)}, 20 * time.Second)
-	return
-}
\ No newline at end of file
+//Logs whether each pair is quoting or why it halted, as of the cycle that just ended
+func logPairStatus() {
+	statuses := maker.GetStatus()
+	pairs := []string{}
+	for pair, _ := range statuses {
+		pairs = append(pairs, pair)
+	}
+	sort.Strings(pairs)
+	for _, pair := range pairs {
+		status := statuses[pair]
+		fields := logrus.Fields{"pair": pair, "quoting": status.Quoting, "price": status.Price, "priceTime": status.PriceTime, "updated": status.Updated, "version": status.Version}
+		if status.Quoting {
+			log.WithFields(fields).Info("Pair status")
+			continue
+		}
+		fields["halt"], fields["reason"] = status.Halt, status.Reason
+		log.WithFields(fields).Warn("Pair status")
+	}
+}
+
+//Restricts a config to the active pairs quoted on a venue. Pairs moved to an exchange without a venue need a restart.
+func venueConfig(CONFIG *config.Config, venue Venue) (*config.Config) {
+	if _, ok := venue.Exchange.(*api.SimulatedClient); ok {
+		return CONFIG
+	}
+	restricted := *CONFIG
+	restricted.ActivePairs = CONFIG.GetActivePairs(venue.Exchange.GetName())
+	return &restricted
+}
+
+//Returns the environment variable if set, even to empty, otherwise fallback
+func lookupEnv(key string, fallback string) (string) {
+	if value, ok := os.LookupEnv(key); ok {
+		return value
+	}
+	return fallback
+}
+
+func main() {
+	//Initialize Logging
+	log = logger.InitLogger()
+
+	//Parse global flags, the remaining arguments are the command
+	flag.StringVar(&config.Root, "root", config.Root, "directory relative paths are resolved against (env " + config.EnvRoot + ", default $GOPATH checkout or working directory)")
+	flag.StringVar(&config.ConfigFile, "config", config.ConfigFile, "path to config file (env " + config.EnvConfigFile + ")")
+	flag.StringVar(&config.CredentialsFile, "credentials", config.CredentialsFile, "path to credentials file (env " + config.EnvCredentialsFile + ")")
+	flag.StringVar(&config.BandsFile, "bands", config.BandsFile, "path to bands file (env " + config.EnvBandsFile + ")")
+	logDir := flag.String("log-dir", lookupEnv(logger.EnvLogDir, "logs"), "directory for log files, empty to log to stderr only (env " + logger.EnvLogDir + ")")
+	interval := flag.Duration("interval", 0, "delay between market making cycles (default intervalSec from config, or 20s)")
+	flag.Usage = usage
+	flag.Parse()
+
+	//Write log files under the root unless given an absolute directory
+	if *logDir != "" {
+		*logDir = config.ResolvePath(*logDir)
+	}
+	if err := logger.SetLogDir(*logDir); err != nil {
+		os.Exit(1)
+	}
+	log.WithFields(logrus.Fields{"root": config.GetRoot(), "config": config.ResolvePath(config.ConfigFile), "bands": config.ResolvePath(config.BandsFile), "logDir": *logDir}).Debug("Resolved file locations")
+
+	args := flag.Args()
+	if len(args) == 0 {
+		args = []string{"run"}
+	}
+	if err := execute(args, *interval); err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+}
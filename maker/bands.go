@@ -4,12 +4,16 @@ import(
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
-	"os"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
 
 //Globals
-var validCombos = [][]*Order{}
 //var allBands = make(AllBands)
 
 ///////////////////////////////////
@@ -17,49 +21,55 @@ var validCombos = [][]*Order{}
 ///////////////////////////////////
 type AllBands map[string]Bands
 type Bands struct {
-	BuyBands 	[]BuyBand 	`json:"buyBands"`
-	SellBands 	[]SellBand 	`json:"sellBands"`
+	BuyBands 		[]BuyBand 		`json:"buyBands"`
+	SellBands 		[]SellBand 		`json:"sellBands"`
+	InventorySkew	*InventorySkew	`json:"inventorySkew"`	//Optional, shifts bands to sell down excess inventory
+	AdaptiveMargins	*AdaptiveMargins	`json:"adaptiveMargins"`	//Optional, scales margins by realised volatility
 }
 
 //Load bands from bands.json file
 func (allBands AllBands) LoadBands() (bool) {
 	//clear existing bands
-	for _, bands := range allBands {
-		bands.BuyBands = nil
-		bands.SellBands = nil
+	for tokenPair, _ := range allBands {
+		delete(allBands, tokenPair)
 	}
-	//lookup $GOPATH
-	goPath, ok := os.LookupEnv("GOPATH")
-	if ok != true {
-		log.WithFields(logrus.Fields{"function": "LoadBands"}).Fatal("$GOPATH Env Variable not set")
+	loaded, err := ReadBands(config.ResolvePath(config.BandsFile))
+	if err != nil {
+		return false
+	}
+	for tokenPair, bands := range loaded {
+		allBands[tokenPair] = bands
 	}
-	//construct path to bands.json
-	bandPath := goPath + "/src/github.com/niklaskunkel/market-maker/bands.json"
+	return true
+}
+
+//Reads and verifies a bands file, returning an error instead of any bands if one of them is invalid
+func ReadBands(bandPath string) (AllBands, error) {
+	allBands := make(AllBands)
 	//read bands.json
 	raw, err := ioutil.ReadFile(bandPath)
 	if err != nil {
-		log.WithFields(logrus.Fields{"function": "LoadBands", "error": err.Error()}).Error("Unable to read bandsNew.json")
-		return false
+		log.WithFields(logrus.Fields{"function": "ReadBands", "path": bandPath, "error": err.Error()}).Error("Unable to read bands file")
+		return nil, err
 	}
 	//load json into memory
 	err = json.Unmarshal(raw, &allBands)
 	if err != nil {
-		log.WithFields(logrus.Fields{"function": "LoadBands", "error": err.Error()}).Error("Loading bandsNew failed during Unmarshal")
-		return false
+		log.WithFields(logrus.Fields{"function": "ReadBands", "path": bandPath, "error": err.Error()}).Error("Loading bands failed during Unmarshal")
+		return nil, fmt.Errorf("Unable to parse %s: %s", bandPath, err.Error())
 	}
 	//print bands
 	allBands.PrintAllBands()
 
 	//verify bands
-	for _, bands := range allBands {
+	for tokenPair, bands := range allBands {
 		if(!bands.VerifyBands()) {
-			return false
+			return nil, fmt.Errorf("Invalid bands for %s in %s", tokenPair, bandPath)
 		}
 	}
-	return true
+	return allBands, nil
 }
 
-
 func (allBands AllBands) PrintAllBands() {
 	for tokenPair, bands := range allBands {
 		log.Debug(tokenPair + "Bands:")
@@ -95,6 +105,38 @@ func (bands *Bands) VerifyBands() (bool) {
 			return false
 		}
 	}
+	if bands.InventorySkew != nil {
+		if err := bands.InventorySkew.Verify(); err != nil {
+			log.WithFields(logrus.Fields{"function": "VerifyBands", "inventorySkew": bands.InventorySkew, "error": err.Error()}).Error("Inventory skew verification failed")
+			return false
+		}
+	}
+	if bands.AdaptiveMargins != nil {
+		if err := bands.AdaptiveMargins.Verify(); err != nil {
+			log.WithFields(logrus.Fields{"function": "VerifyBands", "adaptiveMargins": bands.AdaptiveMargins, "error": err.Error()}).Error("Adaptive margins verification failed")
+			return false
+		}
+	}
+	//margins must stay below 1 however far they are scaled up
+	maxFactor := 1.0
+	if bands.AdaptiveMargins != nil {
+		maxFactor *= bands.AdaptiveMargins.MaxFactor
+	}
+	if bands.InventorySkew != nil {
+		maxFactor *= 1 + bands.InventorySkew.MaxMarginShift
+	}
+	for _, bBand := range bands.BuyBands {
+		if bBand.MaxMargin.Mul(decimal.NewFromFloat(maxFactor)).GreaterThanOrEqual(one) {
+			log.WithFields(logrus.Fields{"function": "VerifyBands", "band": bBand, "maxFactor": maxFactor}).Error("Buy band verification failed, scaled MaxMargin reaches 1")
+			return false
+		}
+	}
+	for _, sBand := range bands.SellBands {
+		if sBand.MaxMargin.Mul(decimal.NewFromFloat(maxFactor)).GreaterThanOrEqual(one) {
+			log.WithFields(logrus.Fields{"function": "VerifyBands", "band": sBand, "maxFactor": maxFactor}).Error("Sell band verification failed, scaled MaxMargin reaches 1")
+			return false
+		}
+	}
 	if(bands.BandsOverlap()) {
 		log.WithFields(logrus.Fields{"function": "VerifyBands"}).Error("Band verification failed due to overlapping bands")
 		return false
@@ -103,22 +145,22 @@ func (bands *Bands) VerifyBands() (bool) {
 }
 
 func (bands *Bands) BandsOverlap() (bool) {
-	for _, band1 := range bands.BuyBands {
-		for _, band2 := range bands.BuyBands {
-			if band1 == band2 {
+	for i, band1 := range bands.BuyBands {
+		for j, band2 := range bands.BuyBands {
+			if i == j {
 				continue
 			}
-			if (band1.MinMargin < band2.MaxMargin && band2.MinMargin < band1.MaxMargin) {
+			if (band1.MinMargin.LessThan(band2.MaxMargin) && band2.MinMargin.LessThan(band1.MaxMargin)) {
 				return true
 			}
 		}
 	}
-	for _, band1 := range bands.SellBands {
-		for _, band2 := range bands.SellBands {
-			if band1 == band2 {
+	for i, band1 := range bands.SellBands {
+		for j, band2 := range bands.SellBands {
+			if i == j {
 				continue
 			}
-			if (band1.MinMargin < band2.MaxMargin && band2.MinMargin < band1.MaxMargin) {
+			if (band1.MinMargin.LessThan(band2.MaxMargin) && band2.MinMargin.LessThan(band1.MaxMargin)) {
 				return true
 			}
 		}
@@ -127,7 +169,7 @@ func (bands *Bands) BandsOverlap() (bool) {
 }
 
 //Returns buy orders which need to be cancelled to bring total amount within all buy bands below maximum
-func (bands *Bands) ExcessiveBuyOrders(buyOrders []*Order, refPrice float64) (cancellableBuyOrders []*Order){
+func (bands *Bands) ExcessiveBuyOrders(buyOrders []*Order, refPrice decimal.Decimal) (cancellableBuyOrders []*Order){
 	for _, buyBand := range bands.BuyBands {
 		for _, order := range buyBand.ExcessiveOrders(buyOrders, refPrice) {
 			cancellableBuyOrders = append(cancellableBuyOrders, order)
@@ -137,7 +179,7 @@ func (bands *Bands) ExcessiveBuyOrders(buyOrders []*Order, refPrice float64) (ca
 }
 
 //Return sell orders which need to be cancelled to bring total amount within all sell bands below maximum
-func (bands *Bands) ExcessiveSellOrders(sellOrders []*Order, refPrice float64) (cancellableSellOrders []*Order) {
+func (bands *Bands) ExcessiveSellOrders(sellOrders []*Order, refPrice decimal.Decimal) (cancellableSellOrders []*Order) {
 	for _, sellBand := range bands.SellBands {
 		for _, order := range sellBand.ExcessiveOrders(sellOrders, refPrice) {
 			cancellableSellOrders = append(cancellableSellOrders, order)
@@ -147,7 +189,7 @@ func (bands *Bands) ExcessiveSellOrders(sellOrders []*Order, refPrice float64) (
 }
 
 //Returns orders which do not fall into any buy or sell band
-func (bands *Bands) OutsideOrders(buyOrders []*Order, sellOrders []*Order, refPrice float64) (outsideOrders []*Order) {
+func (bands *Bands) OutsideOrders(buyOrders []*Order, sellOrders []*Order, refPrice decimal.Decimal) (outsideOrders []*Order) {
 	for _, buyOrder := range buyOrders {
 		inBand := false
 		for _, band := range bands.BuyBands {
@@ -173,7 +215,7 @@ func (bands *Bands) OutsideOrders(buyOrders []*Order, sellOrders []*Order, refPr
 	return outsideOrders
 }
 
-func (bands Bands) CancellableOrders(buyOrders []*Order, sellOrders []*Order, refPrice float64) (ordersToCancel []*Order) {
+func (bands Bands) CancellableOrders(buyOrders []*Order, sellOrders []*Order, refPrice decimal.Decimal) (ordersToCancel []*Order) {
 	ordersToCancel = append(ordersToCancel, bands.ExcessiveBuyOrders(buyOrders, refPrice)...)
 	ordersToCancel = append(ordersToCancel, bands.ExcessiveSellOrders(sellOrders, refPrice)...)
 	ordersToCancel = append(ordersToCancel, bands.OutsideOrders(buyOrders, sellOrders, refPrice)...)
@@ -183,187 +225,403 @@ func (bands Bands) CancellableOrders(buyOrders []*Order, sellOrders []*Order, re
 ///////////////////////////////////
 //         BAND
 ///////////////////////////////////
+//Band margins and amounts are exact decimals, so prices on a band's edges are compared without float error
 type Band struct {
-	MinMargin 	float64 	`json:"minMargin"`
-	AvgMargin 	float64 	`json:"avgMargin"`
-	MaxMargin 	float64 	`json:"maxMargin"`
-	MinAmount 	float64 	`json:"minAmount"`
-	AvgAmount 	float64 	`json:"avgAmount"`
-	MaxAmount 	float64 	`json:"maxAmount"`
-	DustCutoff 	float64 	`json:"dustCutoff"`
+	MinMargin 	decimal.Decimal 	`json:"minMargin"`
+	AvgMargin 	decimal.Decimal 	`json:"avgMargin"`
+	MaxMargin 	decimal.Decimal 	`json:"maxMargin"`
+	MinAmount 	decimal.Decimal 	`json:"minAmount"`
+	AvgAmount 	decimal.Decimal 	`json:"avgAmount"`
+	MaxAmount 	decimal.Decimal 	`json:"maxAmount"`
+	DustCutoff 	decimal.Decimal 	`json:"dustCutoff"`
+	CancelPolicy	string			`json:"cancelPolicy"`	//How orders are picked when the band holds too much, see cancelPolicies
+	Ladder		*Ladder				`json:"ladder"`			//Splits top-ups into several orders, nil for a single order at avgMargin
+	Unit		string				`json:"unit"`			//Unit of the amounts and dustCutoff, see GetUnit of buy and sell bands for defaults
+}
+
+var one = decimal.NewFromInt(1)
+
+//Units of band amounts
+const (
+	UnitBase	= "base"	//Amounts in base token, e.g. ETH of ETHDAI
+	UnitQuote	= "quote"	//Amounts in quote token, e.g. DAI of ETHDAI
+)
+
+//Converts an amount in a unit to base token at a price
+func ToBase(amount decimal.Decimal, price decimal.Decimal, unit string) (decimal.Decimal) {
+	if unit == UnitQuote {
+		return amount.Div(price)
+	}
+	return amount
+}
+
+//Converts an amount in base token to a unit at a price
+func FromBase(baseAmount decimal.Decimal, price decimal.Decimal, unit string) (decimal.Decimal) {
+	if unit == UnitQuote {
+		return baseAmount.Mul(price)
+	}
+	return baseAmount
+}
+
+//Cancel policies
+const (
+	CancelFewest		= "fewest"			//Fewest cancellations, then furthest from avgMargin, then newest (default)
+	CancelQueuePriority	= "queuePriority"	//Newest first, then furthest from avgMargin, preserving time priority of resting orders
+)
+
+//Selects orders to cancel among the orders in a band so that the cancelled amount covers excess without exceeding limit.
+//Returns false if no selection satisfies both.
+type cancelPolicy func(orders []*Order, amounts map[*Order]decimal.Decimal, excess decimal.Decimal, limit decimal.Decimal, avgPrice decimal.Decimal) ([]*Order, bool)
+
+var cancelPolicies = map[string]cancelPolicy{
+	CancelFewest:			cancelFewest,
+	CancelQueuePriority:	cancelQueuePriority,
 }
 
 type BandType interface {
-	Includes(float64, float64) bool
-	AvgPrice(float64) float64
+	Includes(decimal.Decimal, decimal.Decimal) bool
+	AvgPrice(decimal.Decimal) decimal.Decimal
+	ApplyMargin(decimal.Decimal, decimal.Decimal) decimal.Decimal
 	GetType() string
+	GetUnit() string
+}
+
+///////////////////////////////////
+//         LADDER
+///////////////////////////////////
+//Ladder spreads the top-up of a band over several child orders between minMargin and maxMargin,
+//so the band's depth looks natural and a single fill does not empty it
+type Ladder struct {
+	Orders			int		`json:"orders"`			//Number of child orders
+	Placement		string	`json:"placement"`		//How child orders are spread, see ladderPlacements. Defaults to linear
+	MinOrderAmount	decimal.Decimal	`json:"minOrderAmount"`	//Smallest child order in band units, fewer orders are placed rather than smaller ones
+	MaxOrderAmount	decimal.Decimal	`json:"maxOrderAmount"`	//Largest child order in band units, 0 for no limit
+}
+
+//Rung is one child order of a band top-up, its price is not yet rounded to the exchange's precision
+type Rung struct {
+	Price	decimal.Decimal
+	Amount	decimal.Decimal		//Denominated like the band's amounts
+}
+
+//Ladder placements
+const (
+	PlaceLinear		= "linear"		//Evenly spaced margins
+	PlaceGeometric	= "geometric"	//Margins spaced by a constant ratio, denser close to the reference price
+	PlaceRandom		= "random"		//Uniformly random margins
+)
+
+//Returns n margins between minMargin and maxMargin, from closest to the reference price outward.
+//Margins are kept off the band edges so rounding the price to the exchange's precision cannot push an order out of the band.
+var ladderPlacements = map[string]func(n int, minMargin decimal.Decimal, maxMargin decimal.Decimal) ([]decimal.Decimal){
+	PlaceLinear:	linearMargins,
+	PlaceGeometric:	geometricMargins,
+	PlaceRandom:	randomMargins,
+}
+
+//Source of random placements, replaced in tests
+var ladderRandom = rand.Float64
+
+func linearMargins(n int, minMargin decimal.Decimal, maxMargin decimal.Decimal) ([]decimal.Decimal) {
+	margins := make([]decimal.Decimal, n)
+	for i := range margins {
+		//centre of the i-th of n equal slices
+		margins[i] = minMargin.Add(maxMargin.Sub(minMargin).Mul(decimal.NewFromInt(int64(2 * i + 1))).Div(decimal.NewFromInt(int64(2 * n))))
+	}
+	return margins
+}
+
+func geometricMargins(n int, minMargin decimal.Decimal, maxMargin decimal.Decimal) ([]decimal.Decimal) {
+	margins := make([]decimal.Decimal, n)
+	ratio := maxMargin.Div(minMargin).InexactFloat64()
+	for i := range margins {
+		margins[i] = minMargin.Mul(decimal.NewFromFloat(math.Pow(ratio, (float64(i) + 0.5) / float64(n))))
+	}
+	return margins
+}
+
+func randomMargins(n int, minMargin decimal.Decimal, maxMargin decimal.Decimal) ([]decimal.Decimal) {
+	margins := make([]decimal.Decimal, n)
+	for i := range margins {
+		//stay a hundredth of the band's width away from its edges
+		margins[i] = minMargin.Add(maxMargin.Sub(minMargin).Mul(decimal.NewFromFloat(0.01 + 0.98 * ladderRandom())))
+	}
+	sort.Slice(margins, func(i, j int) bool { return margins[i].LessThan(margins[j]) })
+	return margins
+}
+
+func (ladder *Ladder) GetPlacement() (string) {
+	if ladder.Placement == "" {
+		return PlaceLinear
+	}
+	return ladder.Placement
+}
+
+func (ladder *Ladder) Verify() (error) {
+	if ladder.Orders < 1 {
+		return fmt.Errorf("Error: Ladder verification failed, Orders(%d) must be at least 1.\n", ladder.Orders)
+	}
+	if _, ok := ladderPlacements[ladder.GetPlacement()]; !ok {
+		return fmt.Errorf("Error: Ladder verification failed, unknown placement %q.\n", ladder.Placement)
+	}
+	if ladder.MinOrderAmount.IsNegative() || ladder.MaxOrderAmount.IsNegative() {
+		return fmt.Errorf("Error: Ladder verification failed, MinOrderAmount(%s) and MaxOrderAmount(%s) must not be negative.\n", ladder.MinOrderAmount, ladder.MaxOrderAmount)
+	}
+	if ladder.MaxOrderAmount.IsPositive() && ladder.MinOrderAmount.GreaterThan(ladder.MaxOrderAmount) {
+		return fmt.Errorf("Error: Ladder verification failed, MinOrderAmount(%s) > MaxOrderAmount(%s).\n", ladder.MinOrderAmount, ladder.MaxOrderAmount)
+	}
+	return nil
+}
+
+//Splits a top-up amount into the orders to place in the band. Without a ladder this is a single order at the band's average price.
+//Amounts are split evenly, using fewer orders if they would be below MinOrderAmount and capping each at MaxOrderAmount,
+//in which case less than amount is placed and the band is topped up further next cycle.
+//Returns no rungs if amount is below MinOrderAmount, the shortfall waits until it is worth an order.
+func (band *Band) Rungs(amount decimal.Decimal, refPrice decimal.Decimal, bandType BandType) ([]Rung) {
+	if band.Ladder == nil {
+		return []Rung{Rung{bandType.AvgPrice(refPrice), amount}}
+	}
+	n := band.Ladder.Orders
+	if band.Ladder.MinOrderAmount.IsPositive() && amount.Div(decimal.NewFromInt(int64(n))).LessThan(band.Ladder.MinOrderAmount) {
+		n = int(amount.Div(band.Ladder.MinOrderAmount).IntPart())
+		if n < 1 {
+			return []Rung{}
+		}
+	}
+	orderAmount := amount.Div(decimal.NewFromInt(int64(n)))
+	if band.Ladder.MaxOrderAmount.IsPositive() {
+		orderAmount = decimal.Min(orderAmount, band.Ladder.MaxOrderAmount)
+	}
+	rungs := []Rung{}
+	for _, margin := range ladderPlacements[band.Ladder.GetPlacement()](n, band.MinMargin, band.MaxMargin) {
+		rungs = append(rungs, Rung{bandType.ApplyMargin(refPrice, margin), orderAmount})
+	}
+	return rungs
 }
 
 func (band *Band) VerifyBand() (error) {
-	if (band.MinMargin <= float64(0) || band.MinMargin >= float64(1) || band.MinMargin > band.AvgMargin) {
-		return fmt.Errorf("Error: Band verification failed, MinMargin(%f) > AvgMargin(%f) and must not equal zero.\n", band.MinMargin, band.AvgMargin)
+	if (!band.MinMargin.IsPositive() || band.MinMargin.GreaterThanOrEqual(one) || band.MinMargin.GreaterThan(band.AvgMargin)) {
+		return fmt.Errorf("Error: Band verification failed, MinMargin(%s) > AvgMargin(%s) and must not equal zero.\n", band.MinMargin, band.AvgMargin)
+	}
+	if (!band.AvgMargin.IsPositive() || band.AvgMargin.GreaterThanOrEqual(one) || band.AvgMargin.GreaterThan(band.MaxMargin)) {
+		return fmt.Errorf("Error: Band verification failed, AvgMargin(%s) > MaxMargin(%s) and must not equal zero.\n", band.AvgMargin, band.MaxMargin)
+	}
+	if (!band.MaxMargin.IsPositive() || band.MaxMargin.GreaterThanOrEqual(one) || band.MinMargin.GreaterThanOrEqual(band.MaxMargin)) {
+		return fmt.Errorf("Error: Band verification failed, MinMargin(%s) >= MaxMargin(%s) and must not equal zero.\n", band.MinMargin, band.MaxMargin)
+	}
+	if (!band.MinAmount.IsPositive() || band.MinAmount.GreaterThan(band.AvgAmount)) {
+		return fmt.Errorf("Error: Band verification failed, MinAmount(%s) > AvgAmount(%s) and must not equal zero.\n", band.MinAmount, band.AvgAmount)
 	}
-	if (band.AvgMargin <= float64(0) || band.AvgMargin >= float64(1) || band.AvgMargin > band.MaxMargin) {
-		return fmt.Errorf("Error: Band verification failed, AvgMargin(%f) > MaxMargin(%f) and must not equal zero.\n", band.AvgMargin, band.MaxMargin)
+	if (!band.AvgAmount.IsPositive() || band.AvgAmount.GreaterThan(band.MaxAmount)) {
+		return fmt.Errorf("Error: Band verification failed, AvgAmount(%s) > MaxAmount(%s) and must not equal zero.\n", band.AvgAmount, band.MaxAmount)
 	}
-	if (band.MaxMargin  <= float64(0) || band.MaxMargin >= float64(1) || band.MinMargin >= band.MaxMargin) {
-		return fmt.Errorf("Error: Band verification failed, MinMargin(%f) >= MaxMargin(%f) and must not equal zero.\n", band.MinMargin, band.MaxMargin)
+	if (!band.MaxAmount.IsPositive() || band.MinAmount.GreaterThan(band.MaxAmount)) {
+		return fmt.Errorf("Error: Band verification failed, MinAmount(%s) > MaxAmount(%s) and must not equal zero.\n", band.MinAmount, band.MaxAmount)
 	}
-	if (band.MinAmount <= float64(0) || band.MinAmount > band.AvgAmount) {
-		return fmt.Errorf("Error: Band verification failed, MinAmount(%f) > AvgAmount(%f) and must not equal zero.\n", band.MinAmount, band.AvgAmount)
+	if _, ok := cancelPolicies[band.GetCancelPolicy()]; !ok {
+		return fmt.Errorf("Error: Band verification failed, unknown cancelPolicy %q.\n", band.CancelPolicy)
 	}
-	if (band.AvgAmount <= float64(0) || band.AvgAmount > band.MaxAmount) {
-		return fmt.Errorf("Error: Band verification failed, AvgAmount(%f) > MaxAmount(%f) and must not equal zero.\n", band.AvgAmount, band.MaxAmount)
+	if band.Unit != "" && band.Unit != UnitBase && band.Unit != UnitQuote {
+		return fmt.Errorf("Error: Band verification failed, unknown unit %q.\n", band.Unit)
 	}
-	if (band.MaxAmount <= float64(0) || band.MinAmount > band.MaxAmount) {
-		return fmt.Errorf("Error: Band verification failed, MinAmount(%f) > MaxAmount(%f) and must not equal zero.\n", band.MinAmount, band.MaxAmount)
+	if band.Ladder != nil {
+		return band.Ladder.Verify()
 	}
 	return nil
 }
 
-//Returns orders which need to be cancelled to bring the total
-//order amount in the band below the maximum
-func (band *Band) ExcessiveOrders(orders []*Order, refPrice float64, bandType BandType) ([]*Order) {
+//Returns orders which need to be cancelled to bring the total order amount in the band down to the maximum,
+//picked by the band's cancel policy so that the band keeps at least its minimum and needn't be refilled.
+//Policies re-sort the remaining orders for every order they consider, which suits the handful of orders
+//a band holds. Keeps no state between calls.
+func (band *Band) ExcessiveOrders(orders []*Order, refPrice decimal.Decimal, bandType BandType) ([]*Order) {
 	ordersInBand := []*Order{}
 	for _, order := range orders {
-		included := false
-		if t, ok := bandType.(BandType); ok {
-			included = t.Includes(order.Price, refPrice)
-		} else {
-			included = band.Includes(order.Price, refPrice)
-		}
-		if (included) {
+		if bandType.Includes(order.Price, refPrice) {
 			ordersInBand = append(ordersInBand, order)
 		}
 	}
 	for _, orderInBand := range ordersInBand {
-		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "refPrice": refPrice, "bandType": bandType, "orderId": orderInBand.OrderId, "RemQuantity": orderInBand.RemQuantity,}).Debug("Order Included in Band")
-	}
-
-	//This block of logic is specific to Gatecoin because they denominated both bid and ask amounts in the base token.
-	//This causes us problems when trying to get the total amount of a token in certain band to try to check it against the band's MaxAmount
-	//When we integrate other exchanges we will want to move ExcessiveOrders (and possibly its derivatives relying on TotalAmount()) into the maker.go file and have switch statements for different exchanges
-	totalAmount := band.TotalAmount(ordersInBand)
-	if t, ok := bandType.(BandType); ok {
-		if t.GetType() == "BUY" {
-			//convert totalAmount denomination from base token to quote token by using average price of band
-			totalAmount = t.AvgPrice(refPrice) * totalAmount
-		} else if t.GetType() == "SELL" {
-			//no conversion necessary b/c sell band is already denominated in base token
-		} else {
-			log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "band": band}).Fatal("Using base band class rather than derived buy/sell band, this should never happen!")
+		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "refPrice": refPrice, "bandType": bandType.GetType(), "orderId": orderInBand.OrderId, "RemQuantity": orderInBand.RemQuantity,}).Debug("Order Included in Band")
+	}
+
+	//amounts in the band's unit at each order's own price
+	amounts := make(map[*Order]decimal.Decimal)
+	totalAmount := decimal.Zero
+	for _, order := range ordersInBand {
+		amounts[order] = order.Amount(bandType.GetUnit())
+		totalAmount = totalAmount.Add(amounts[order])
+	}
+	if totalAmount.LessThanOrEqual(band.MaxAmount) {
+		return []*Order{}
+	}
+	log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "refPrice": refPrice, "bandType": bandType.GetType(), "totalAmount": totalAmount, "maxAmount": band.MaxAmount}).Info("Total Order Amount Exceeded, finding orders to cancel...")
+
+	//amount which must be cancelled to bring the total down to the maximum
+	excess := totalAmount.Sub(band.MaxAmount)
+	//amount which can be cancelled without the total falling below the minimum
+	limit := decimal.Max(totalAmount.Sub(band.MinAmount), decimal.Zero)
+
+	policy := cancelPolicies[band.GetCancelPolicy()]
+	ordersToKill, ok := policy(ordersInBand, amounts, excess, limit, bandType.AvgPrice(refPrice))
+	if !ok {
+		//whole orders can't land the total between minimum and maximum, the maximum is a risk limit so it wins
+		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "bandType": bandType.GetType(), "totalAmount": totalAmount, "minAmount": band.MinAmount, "maxAmount": band.MaxAmount}).Warn("Cancelling below band minimum to enforce maximum")
+		ordersToKill, _ = policy(ordersInBand, amounts, excess, totalAmount, bandType.AvgPrice(refPrice))
+	}
+	for _, killOrder := range ordersToKill {
+		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "orderId": killOrder.OrderId, "price": killOrder.Price, "remQuantity": killOrder.RemQuantity}).Debug("Order flagged for cancellation")
+	}
+	return ordersToKill
+}
+
+//Minimises, in order of importance:
+//  1. the number of orders cancelled
+//  2. the distance from the band's avgMargin of the orders kept, i.e. the furthest orders are cancelled first
+//  3. the age of the orders kept, i.e. the newest orders are cancelled first
+func cancelFewest(orders []*Order, amounts map[*Order]decimal.Decimal, excess decimal.Decimal, limit decimal.Decimal, avgPrice decimal.Decimal) ([]*Order, bool) {
+	//fewest cancellations possible is reached by cancelling the largest orders
+	bySize := append([]*Order{}, orders...)
+	sort.SliceStable(bySize, func(i, j int) bool { return amounts[bySize[i]].GreaterThan(amounts[bySize[j]]) })
+	cancelCount, cancelled := 0, decimal.Zero
+	for cancelled.LessThan(excess) && cancelCount < len(bySize) {
+		cancelled = cancelled.Add(amounts[bySize[cancelCount]])
+		cancelCount++
+	}
+
+	//staying within limit may take more cancellations than that
+	candidates := append([]*Order{}, orders...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return furtherFrom(candidates[i], candidates[j], avgPrice)
+	})
+	for ; cancelCount <= len(candidates); cancelCount++ {
+		if ordersToKill, ok := pickFurthest(candidates, amounts, cancelCount, excess, limit); ok {
+			return ordersToKill, true
 		}
 	}
+	return nil, false
+}
 
-	//if (band.TotalAmount(ordersInBand) > band.MaxAmount) {
-	if totalAmount > band.MaxAmount {
-		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "refPrice": refPrice, "bandType": bandType, "totalAmount": totalAmount, "maxAmount": band.MaxAmount}).Info("Total Order Amount Exceeded, finding orders to cancel...")
-		//log.WithFields(logrus.Fields{}).Debug("All Combinations")
-		for size, _ := range ordersInBand {
-			band.GetAllCombinationsOfSizeN(ordersInBand, size + 1, bandType, refPrice)
+//Picks up to count orders, preferring those furthest from avgMargin and then the newest,
+//as long as the cancelled amount stays within limit and the orders left to consider can still cover the excess
+func pickFurthest(candidates []*Order, amounts map[*Order]decimal.Decimal, count int, excess decimal.Decimal, limit decimal.Decimal) ([]*Order, bool) {
+	ordersToKill := []*Order{}
+	cancelled := decimal.Zero
+	for i, order := range candidates {
+		remaining := count - len(ordersToKill)
+		if remaining == 0 {
+			break
 		}
-		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "refPrice": refPrice, "bandType": bandType}).Debug("Valid Combinations of Orders:")
-		for comboNum, combo := range validCombos {
-			for _, element := range combo {
-				log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "refPrice": refPrice, "bandType": bandType, "comboNumber": comboNum, "comboSize": len(combo),"orderId": element.OrderId, "price": element.Price, "remQuantity": element.RemQuantity}).Debug("order in valid combo")
-			}
+		total := cancelled.Add(amounts[order])
+		if total.LessThanOrEqual(limit) && total.Add(largestAmounts(candidates[i+1:], amounts, remaining - 1)).GreaterThanOrEqual(excess) {
+			ordersToKill = append(ordersToKill, order)
+			cancelled = total
 		}
-		//filter combos by largest length - this means we have to cancel less orders (saves gas for dex)
-		//if one or more combos share the largest length choose the one with the higher total amount
-		//NOTE: Due to Gatecoin base token denominations messing up TotalAmount() we now just uses a random longest combo.
-		maxLength := 0
-		maxIndex := -1
-		for i, combo := range validCombos {
-			comboLength := len(combo)
-			/*
-			if (comboLength == maxLength) {
-				if (band.TotalAmount(combo) > band.TotalAmount(validCombos[maxIndex])) {
-					maxIndex = i
-				}
-			} */
-			if (comboLength > maxLength) {
-				maxIndex = i
-				maxLength = comboLength
-			}
+	}
+	return ordersToKill, cancelled.GreaterThanOrEqual(excess)
+}
+
+//Cancels the newest orders, then those furthest from avgMargin, until the excess is covered,
+//skipping orders which would take the cancelled amount over limit.
+//Orders which have been resting longest keep their place in the exchange's queue even if that takes more cancellations.
+func cancelQueuePriority(orders []*Order, amounts map[*Order]decimal.Decimal, excess decimal.Decimal, limit decimal.Decimal, avgPrice decimal.Decimal) ([]*Order, bool) {
+	candidates := append([]*Order{}, orders...)
+	sort.SliceStable(candidates, func(i, j int) bool {
+		if age := compareAge(candidates[i], candidates[j]); age != 0 {
+			return age > 0
 		}
-		log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "numberOfOrdersInCombo": maxLength, "comboNumber": maxIndex}).Debug("Best combination of orders found")
-		//Kill all orders not in our best combination
-		ordersToKill := []*Order{}
-		for _, order := range ordersInBand {
-			keepOrder := false
-			if maxIndex != -1 {
-				for _, orderToKeep := range validCombos[maxIndex] {
-					if (order == orderToKeep) {
-						keepOrder = true
-					}
-				}
-			}
-			if (keepOrder == false) {
-				ordersToKill = append(ordersToKill, order)
-			}
+		return furtherFrom(candidates[i], candidates[j], avgPrice)
+	})
+	ordersToKill := []*Order{}
+	cancelled := decimal.Zero
+	for _, order := range candidates {
+		if cancelled.GreaterThanOrEqual(excess) {
+			break
 		}
-		//clear validCombos global
-		validCombos = nil
-		for _, killOrder := range ordersToKill {
-			log.WithFields(logrus.Fields{"function": "ExcessiveOrders", "orderId": killOrder.OrderId, "price": killOrder.Price, "remQuantity": killOrder.RemQuantity}).Debug("Order flagged for cancellation")
+		if cancelled.Add(amounts[order]).GreaterThan(limit) {
+			continue
 		}
-		return ordersToKill
-	} else {
-		return []*Order{}
+		ordersToKill = append(ordersToKill, order)
+		cancelled = cancelled.Add(amounts[order])
 	}
+	return ordersToKill, cancelled.GreaterThanOrEqual(excess)
 }
 
-func (band *Band) GetAllCombinationsOfSizeN(input []*Order, comboSize int, bandType BandType, refPrice float64) {
-	length := len(input)
-	output := make([]*Order, comboSize)
-	band.CombinationUtil(input, output, 0, length - 1, 0, comboSize, bandType, refPrice)
+//Returns whether order a is further from the average price than order b, or the newer of the two if equally far
+func furtherFrom(a *Order, b *Order, avgPrice decimal.Decimal) (bool) {
+	distanceA, distanceB := a.Price.Sub(avgPrice).Abs(), b.Price.Sub(avgPrice).Abs()
+	if !distanceA.Equal(distanceB) {
+		return distanceA.GreaterThan(distanceB)
+	}
+	if age := compareAge(a, b); age != 0 {
+		return age > 0
+	}
+	return a.OrderId > b.OrderId
 }
 
-func (band *Band) CombinationUtil(input []*Order, output []*Order, start int, end int, index int, comboSize int, bandType BandType, refPrice float64) {
-	//Print Combo
-	if (index == comboSize) {
-		totalAmount := band.TotalAmount(output)
-		//if buy band convert denomination from base token to quote token by using average price of band
-		if t, ok := bandType.(BandType); ok {
-			if t.GetType() == "BUY" {
-				log.WithFields(logrus.Fields{"function": "CombinationUtil"}).Debug("Converting totalAmount denomination from quote token to base token due to Buy Band")
-				totalAmount = t.AvgPrice(refPrice) * totalAmount
-			}
-		}
-		if (totalAmount >= band.MinAmount && totalAmount < band.MaxAmount) {
-			temp := make([]*Order, comboSize)
-			copy(temp, output)
-			validCombos = append(validCombos, temp)
-		}
-		/*for i, element := range output {
-			log.WithFields(logrus.Fields{"function": "CombinationUtil", "comboSize": comboSize, "comboNumber": i + 1, "orderId": element.OrderId, "price": element.Price, "remQuantity": element.RemQuantity}).Debug("Order in possible combo")
-		}*/
-		return
+//Returns 1 if order a was placed after order b, -1 if before and 0 if they can't be told apart.
+//Orders are compared by Date and then by TxSeqNo for orders placed within the same second.
+func compareAge(a *Order, b *Order) (int) {
+	dateA, _ := strconv.ParseInt(a.Date, 10, 64)
+	dateB, _ := strconv.ParseInt(b.Date, 10, 64)
+	switch {
+	case dateA > dateB:
+		return 1
+	case dateA < dateB:
+		return -1
+	case a.TxSeqNo > b.TxSeqNo:
+		return 1
+	case a.TxSeqNo < b.TxSeqNo:
+		return -1
+	}
+	return 0
+}
+
+//Returns the sum of the count largest amounts of orders
+func largestAmounts(orders []*Order, amounts map[*Order]decimal.Decimal, count int) (sum decimal.Decimal) {
+	sizes := []decimal.Decimal{}
+	for _, order := range orders {
+		sizes = append(sizes, amounts[order])
 	}
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i].GreaterThan(sizes[j]) })
+	for i := 0; i < count && i < len(sizes); i++ {
+		sum = sum.Add(sizes[i])
+	}
+	return sum
+}
 
-	//Replace output[index] with all possible elements of input
-    for i := start; i <= end && ((end - i + 1) >= (comboSize - index)); i++ {
-    	output[index] = input[i]
-    	band.CombinationUtil(input, output, i + 1, end, index + 1, comboSize, bandType, refPrice)
-    }
+//Returns the cancel policy of the band, CancelFewest if not set
+func (band *Band) GetCancelPolicy() (string) {
+	if band.CancelPolicy == "" {
+		return CancelFewest
+	}
+	return band.CancelPolicy
 }
 
-func (band *Band) Includes(orderPrice float64, refPrice float64) (bool) {
+func (band *Band) Includes(orderPrice decimal.Decimal, refPrice decimal.Decimal) (bool) {
 	//raise virtual method exception
 	log.WithFields(logrus.Fields{"function": "Includes", "band": band}).Fatal("Using base class Includes(), this should never happen!")
 	return true
 }
 
 //Returns the total amount of all the orders
-func (band *Band) TotalAmount(orders []*Order) (total float64) {
+func (band *Band) TotalAmount(orders []*Order) (total decimal.Decimal) {
+	for _, order := range orders {
+		total = total.Add(order.RemQuantity)
+	}
+	return total
+}
+
+//Returns the total amount of all the orders in a unit, each order converted at its own price
+func (band *Band) TotalAmountIn(orders []*Order, unit string) (total decimal.Decimal) {
 	for _, order := range orders {
-		total += order.RemQuantity
+		total = total.Add(order.Amount(unit))
 	}
 	return total
 }
 
 func (band *Band) PrintBand(i int) {
-	log.WithFields(logrus.Fields{"BandNum": i + 1, "minMargin": band.MinMargin, "avgMargin": band.AvgMargin, "maxMargin": band.MaxMargin, "minAmount": band.MinMargin, "avgAmount": band.AvgAmount, "maxAmount": band.MaxAmount, "dustCutoff": band.DustCutoff}).Debug()
+	log.WithFields(logrus.Fields{"BandNum": i + 1, "minMargin": band.MinMargin, "avgMargin": band.AvgMargin, "maxMargin": band.MaxMargin, "minAmount": band.MinMargin, "avgAmount": band.AvgAmount, "maxAmount": band.MaxAmount, "dustCutoff": band.DustCutoff, "cancelPolicy": band.GetCancelPolicy(), "ladder": band.Ladder}).Debug()
 }
 
 ///////////////////////////////////
@@ -373,31 +631,43 @@ type BuyBand struct {
 	Band
 }
 
-func (band *BuyBand) Includes(orderPrice float64, refPrice float64) (bool) {
+func (band *BuyBand) Includes(orderPrice decimal.Decimal, refPrice decimal.Decimal) (bool) {
 	log.WithFields(logrus.Fields{"function": "Includes", "band": band}).Debug("Using Includes() from buy band")
 	minPrice := band.ApplyMargin(refPrice, band.MinMargin)
 	maxPrice := band.ApplyMargin(refPrice, band.MaxMargin)
-	isIncluded := (orderPrice >= maxPrice) && (orderPrice <= minPrice)
+	isIncluded := orderPrice.GreaterThanOrEqual(maxPrice) && orderPrice.LessThanOrEqual(minPrice)
 	log.WithFields(logrus.Fields{"function": "Includes", "bandType": "buy band", "minMargin": band.MinMargin, "maxMargin": band.MaxMargin, "minPrice": minPrice, "maxPrice": maxPrice, "orderPrice": orderPrice, "included": isIncluded}).Debug("Checking if order is in buy band...")
 	return isIncluded
 }
 
-func (band *BuyBand) AvgPrice(refPrice float64) (float64) {
+func (band *BuyBand) AvgPrice(refPrice decimal.Decimal) (decimal.Decimal) {
 	return band.ApplyMargin(refPrice, band.AvgMargin)
 }
 
-func (band *BuyBand) ApplyMargin(price float64, margin float64) (float64) {
-	return price * (1 - margin)
+func (band *BuyBand) ApplyMargin(price decimal.Decimal, margin decimal.Decimal) (decimal.Decimal) {
+	return price.Mul(one.Sub(margin))
 }
 
-func (band *BuyBand) ExcessiveOrders(orders []*Order, refPrice float64) ([]*Order) {
+func (band *BuyBand) ExcessiveOrders(orders []*Order, refPrice decimal.Decimal) ([]*Order) {
 	return band.Band.ExcessiveOrders(orders, refPrice, band)
 }
 
+func (band *BuyBand) Rungs(amount decimal.Decimal, refPrice decimal.Decimal) ([]Rung) {
+	return band.Band.Rungs(amount, refPrice, band)
+}
+
 func (band *BuyBand) GetType()	(string) {
 	return string("BUY")
 }
 
+//Returns the unit of the band's amounts, quote token unless set
+func (band *BuyBand) GetUnit() (string) {
+	if band.Unit == "" {
+		return UnitQuote
+	}
+	return band.Unit
+}
+
 ///////////////////////////////////
 //         SELL BAND
 ///////////////////////////////////
@@ -405,27 +675,39 @@ type SellBand struct {
 	Band
 }
 
-func (band *SellBand) Includes(orderPrice float64, refPrice float64) (bool) {
+func (band *SellBand) Includes(orderPrice decimal.Decimal, refPrice decimal.Decimal) (bool) {
 	log.WithFields(logrus.Fields{"function": "Includes", "band": band}).Debug("Using Includes() from sell band")
 	minPrice := band.ApplyMargin(refPrice, band.MinMargin)
 	maxPrice := band.ApplyMargin(refPrice, band.MaxMargin)
-	isIncluded := (orderPrice >= minPrice) && (orderPrice <= maxPrice)
+	isIncluded := orderPrice.GreaterThanOrEqual(minPrice) && orderPrice.LessThanOrEqual(maxPrice)
 	log.WithFields(logrus.Fields{"function": "Includes", "bandType": "sell band", "minMargin": band.MinMargin, "maxMargin": band.MaxMargin, "minPrice": minPrice, "maxPrice": maxPrice, "orderPrice": orderPrice, "included": isIncluded}).Debug("Checking if order is in sell band...")
 	return isIncluded
 }
 
-func (band *SellBand) AvgPrice(refPrice float64) (float64) {
+func (band *SellBand) AvgPrice(refPrice decimal.Decimal) (decimal.Decimal) {
 	return band.ApplyMargin(refPrice, band.AvgMargin)
 }
 
-func (band *SellBand) ApplyMargin(price float64, margin float64) (float64) {
-	return price * (1 + margin)
+func (band *SellBand) ApplyMargin(price decimal.Decimal, margin decimal.Decimal) (decimal.Decimal) {
+	return price.Mul(one.Add(margin))
 }
 
-func (band *SellBand) ExcessiveOrders(orders []*Order, refPrice float64) ([]*Order) {
+func (band *SellBand) ExcessiveOrders(orders []*Order, refPrice decimal.Decimal) ([]*Order) {
 	return band.Band.ExcessiveOrders(orders, refPrice, band)
 }
 
+func (band *SellBand) Rungs(amount decimal.Decimal, refPrice decimal.Decimal) ([]Rung) {
+	return band.Band.Rungs(amount, refPrice, band)
+}
+
 func (band *SellBand) GetType()	(string) {
 	return string("SELL")
+}
+
+//Returns the unit of the band's amounts, base token unless set
+func (band *SellBand) GetUnit() (string) {
+	if band.Unit == "" {
+		return UnitBase
+	}
+	return band.Unit
 }
\ No newline at end of file
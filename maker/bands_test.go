@@ -1,15 +1,30 @@
 package maker
 
 import	(
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
 	"testing"
+	"github.com/niklaskunkel/market-maker/config"
 	"github.com/stretchr/testify/assert"
 )
 
+//Load config and bands from the fixtures in testdata so tests do not depend on GOPATH, the working directory or the environment
+func TestMain(m *testing.M) {
+	root, err := filepath.Abs("testdata")
+	if err != nil {
+		panic(err)
+	}
+	config.Root, config.ConfigFile, config.BandsFile = root, "config.json", "bands.json"
+	os.Exit(m.Run())
+}
+
 //BANDS
 //Test if bands can be loaded from JSON file
 func Test_Bands_LoadBands(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	assert.True(t, allBands.LoadBands())//load bands from bands.json
+	assert.True(t, allBands.LoadBands())//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//grab all bands for token pair ETHDAI
 	assert.NotEmpty(t, bands.BuyBands)	//check buy bands exist
 	assert.NotEmpty(t, bands.SellBands)	//check sell bands exist
@@ -18,7 +33,7 @@ func Test_Bands_LoadBands(t *testing.T) {
 //Test band parameter verification
 func Test_Bands_VerifyBands(t *testing.T) {
 	allBands := make(AllBands)				//create bands instance
-	allBands.LoadBands()					//load bands from bands.json
+	allBands.LoadBands()					//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]				//get bands for "ETHDAI" pair
 	assert.True(t, (&bands).VerifyBands())	//verify if bands have correct paramters
 }
@@ -26,7 +41,7 @@ func Test_Bands_VerifyBands(t *testing.T) {
 //Test if different bands overlap
 func Test_Bands_BandsOverlap1(t *testing.T) {
 	allBands := make(AllBands)					//create bands instance
-	allBands.LoadBands()						//load bands from bands.json
+	allBands.LoadBands()						//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]					//get bands for "ETHDAI" pair
 	assert.False(t, (&bands).BandsOverlap())	//check if bands overlap - they should not
 }
@@ -34,10 +49,10 @@ func Test_Bands_BandsOverlap1(t *testing.T) {
 //Test if two of the same bands overlap
 func Test_Bands_BandsOverlap2(t* testing.T) {
 	allBands := make(AllBands)					//create bands instance
-	allBands.LoadBands()						//load bands from bands.json
+	allBands.LoadBands()						//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]				//get bands for "ETHDAI"
 	bands.BuyBands = append(bands.BuyBands, bands.BuyBands[0])	//clone buy band
-	(&bands).BuyBands[1].MinMargin = .005		//modify band to fall within range of band[0]
+	(&bands).BuyBands[1].MinMargin = dec(.005)		//modify band to fall within range of band[0]
 	assert.True(t, bands.BandsOverlap())		//check if bands overlap - they should
 }
 
@@ -52,30 +67,30 @@ func Test_Bands_ExcessiveSellOrders(t *testing.T) {
 //Test if bid on boundary of minMargin is in-band
 func Test_Bands_OutsideOrders1(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.BuyBands = []BuyBand{BuyBand{Band{0.002344, 0.004689, 0.009378, 10.0, 40.0, 80.0, 0.0}}}
-	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, 0.997656, 50.0, 20.0, 1, "New", 0, 0, "1515755942"}}	//create in-band bid order
+	bands.BuyBands = []BuyBand{BuyBand{Band{MinMargin: dec(0.002344), AvgMargin: dec(0.004689), MaxMargin: dec(0.009378), MinAmount: dec(10.0), AvgAmount: dec(40.0), MaxAmount: dec(80.0), DustCutoff: dec(0.0)}}}
+	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, dec(0.997656), dec(50.0), dec(20.0), 1, "New", 0, 0, "1515755942"}}	//create in-band bid order
 	sellOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.Empty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))	//assert order is within boundary
 }
 
 //Test if bid on boundary of maxMargin is in-band
 func Test_Bands_OutsideOrders2(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.BuyBands = []BuyBand{BuyBand{Band{0.002344, 0.004689, 0.009378, 10.0, 40.0, 80.0, 0.0}}}
-	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, 0.990622, 50.0, 20.0, 1, "New", 0, 0, "1515755942"}}	//create in-band bid order
+	bands.BuyBands = []BuyBand{BuyBand{Band{MinMargin: dec(0.002344), AvgMargin: dec(0.004689), MaxMargin: dec(0.009378), MinAmount: dec(10.0), AvgAmount: dec(40.0), MaxAmount: dec(80.0), DustCutoff: dec(0.0)}}}
+	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, dec(0.990622), dec(50.0), dec(20.0), 1, "New", 0, 0, "1515755942"}}	//create in-band bid order
 	sellOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.Empty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))	//assert order is within boundary
 }
 
 //Test if bid on minMargin++ is in-band
 func Test_Bands_OutsideOrders3(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.BuyBands = []BuyBand{BuyBand{Band{0.002344, 0.004689, 0.009378, 10.0, 40.0, 80.0, 0.0}}}
-	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, 0.997657, 50.0, 20.0, 1, "New", 0, 0, "1515755942"}}	//create outside-band bid order
+	bands.BuyBands = []BuyBand{BuyBand{Band{MinMargin: dec(0.002344), AvgMargin: dec(0.004689), MaxMargin: dec(0.009378), MinAmount: dec(10.0), AvgAmount: dec(40.0), MaxAmount: dec(80.0), DustCutoff: dec(0.0)}}}
+	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, dec(0.997657), dec(50.0), dec(20.0), 1, "New", 0, 0, "1515755942"}}	//create outside-band bid order
 	sellOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.NotEmpty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))				//assert order is out of bondary
 	assert.Contains(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice), buyOrders[0])	//assert order did not get corrupted
 }
@@ -83,10 +98,10 @@ func Test_Bands_OutsideOrders3(t *testing.T) {
 //Test if bid on maxMargin-- is in-band
 func Test_Bands_OutsideOrders4(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.BuyBands = []BuyBand{BuyBand{Band{0.002344, 0.004689, 0.009378, 10.0, 40.0, 80.0, 0.0}}}
-	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, 0.990621, 50.0, 20.0, 1, "New", 0, 0, "1515755942"}}	//create outside-band bid order
+	bands.BuyBands = []BuyBand{BuyBand{Band{MinMargin: dec(0.002344), AvgMargin: dec(0.004689), MaxMargin: dec(0.009378), MinAmount: dec(10.0), AvgAmount: dec(40.0), MaxAmount: dec(80.0), DustCutoff: dec(0.0)}}}
+	buyOrders := []*Order{&Order{"DAIUSD", "BK01", 0, dec(0.990621), dec(50.0), dec(20.0), 1, "New", 0, 0, "1515755942"}}	//create outside-band bid order
 	sellOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.NotEmpty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))				//assert order is out of boundary
 	assert.Contains(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice), buyOrders[0])	//assert order did not get corrupted
 }
@@ -95,30 +110,30 @@ func Test_Bands_OutsideOrders4(t *testing.T) {
 //Test if ask on boundary of minMargin is in-band
 func Test_Bands_OutsideOrders5(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.SellBands = []SellBand{SellBand{Band{0.000428, 0.000856, 0.001711, 0.01, 0.1, 0.15, 0.0}}}
-	sellOrders := []*Order{&Order{"DAIUSD", "BK01", 1, 1.000428, 50.0, 20.0, 1, "New", 0, 0, "1515755942"}}	//create ask order
+	bands.SellBands = []SellBand{SellBand{Band{MinMargin: dec(0.000428), AvgMargin: dec(0.000856), MaxMargin: dec(0.001711), MinAmount: dec(0.01), AvgAmount: dec(0.1), MaxAmount: dec(0.15), DustCutoff: dec(0.0)}}}
+	sellOrders := []*Order{&Order{"DAIUSD", "BK01", 1, dec(1.000428), dec(50.0), dec(20.0), 1, "New", 0, 0, "1515755942"}}	//create ask order
 	buyOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.Empty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))		//assert order is within boundary
 }
 
 //Test if ask on boundary of maxMargin is in-band
 func Test_Bands_OutsideOrders6(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.SellBands = []SellBand{SellBand{Band{0.000428, 0.000856, 0.001711, 0.01, 0.1, 0.15, 0.0}}}
-	sellOrders := []*Order{&Order{"DAIUSD", "BK02", 1, 1.001711, 10.0, 10.0, 1, "New", 0, 0, "1515755945"}}	//create ask order
+	bands.SellBands = []SellBand{SellBand{Band{MinMargin: dec(0.000428), AvgMargin: dec(0.000856), MaxMargin: dec(0.001711), MinAmount: dec(0.01), AvgAmount: dec(0.1), MaxAmount: dec(0.15), DustCutoff: dec(0.0)}}}
+	sellOrders := []*Order{&Order{"DAIUSD", "BK02", 1, dec(1.001711), dec(10.0), dec(10.0), 1, "New", 0, 0, "1515755945"}}	//create ask order
 	buyOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.Empty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))		//assert order is within boundary
 }
 
 //Test if ask on minMargin-- is in-band
 func Test_Bands_OutsideOrders7(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.SellBands = []SellBand{SellBand{Band{0.000428, 0.000856, 0.001711, 0.01, 0.1, 0.15, 0.0}}}
-	sellOrders := []*Order{&Order{"DAIUSD", "BK02", 1, 1.000427, 10.0, 10.0, 1, "New", 0, 0, "1515755945"}}	//create ask order
+	bands.SellBands = []SellBand{SellBand{Band{MinMargin: dec(0.000428), AvgMargin: dec(0.000856), MaxMargin: dec(0.001711), MinAmount: dec(0.01), AvgAmount: dec(0.1), MaxAmount: dec(0.15), DustCutoff: dec(0.0)}}}
+	sellOrders := []*Order{&Order{"DAIUSD", "BK02", 1, dec(1.000427), dec(10.0), dec(10.0), 1, "New", 0, 0, "1515755945"}}	//create ask order
 	buyOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.NotEmpty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))				//assert order is out of boundary
 	assert.Contains(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice), sellOrders[0])	//assert order did not get corrupted
 }
@@ -126,10 +141,10 @@ func Test_Bands_OutsideOrders7(t *testing.T) {
 //Test if ask on maxMargin++ is in-band
 func Test_Bands_OutsideOrders8(t *testing.T) {
 	bands := new(Bands)					//create bands instance
-	bands.SellBands = []SellBand{SellBand{Band{0.000428, 0.000856, 0.001711, 0.01, 0.1, 0.15, 0.0}}}
-	sellOrders := []*Order{&Order{"DAIUSD", "BK02", 1, 1.001712, 10.0, 10.0, 1, "New", 0, 0, "1515755945"}}	//create ask order
+	bands.SellBands = []SellBand{SellBand{Band{MinMargin: dec(0.000428), AvgMargin: dec(0.000856), MaxMargin: dec(0.001711), MinAmount: dec(0.01), AvgAmount: dec(0.1), MaxAmount: dec(0.15), DustCutoff: dec(0.0)}}}
+	sellOrders := []*Order{&Order{"DAIUSD", "BK02", 1, dec(1.001712), dec(10.0), dec(10.0), 1, "New", 0, 0, "1515755945"}}	//create ask order
 	buyOrders := []*Order{}
-	refPrice := 1.00					//set ref price of asset to 1
+	refPrice := dec(1.00)					//set ref price of asset to 1
 	assert.NotEmpty(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice))				//assert order is out of boundary
 	assert.Contains(t, bands.OutsideOrders(buyOrders, sellOrders, refPrice), sellOrders[0])	//assert order did not get corrupted
 }
@@ -138,7 +153,7 @@ func Test_Bands_OutsideOrders8(t *testing.T) {
 //Test if band has improper parameters
 func Test_Band_VerifyBand1(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]						//get all bands from token pair ETHDAI
 	assert.Nil(t, bands.BuyBands[0].VerifyBand())	//assert band is valid
 }
@@ -146,27 +161,27 @@ func Test_Band_VerifyBand1(t *testing.T) {
 //Test if band has improper parameters
 func Test_Band_VerifyBand2(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]						//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]						//get buy band
-	band.MinMargin = band.AvgMargin + 0.000001		//set MinMargin to be AvgMargin++
+	band.MinMargin = band.AvgMargin.Add(dec(0.000001))		//set MinMargin to be AvgMargin++
 	assert.Error(t, band.VerifyBand())				//assert band is invalid
 }
 
 //Test if band has improper parameters
 func Test_Band_VerifyBand3(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands :=allBands["ETHDAI"]						//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]						//get buy band
-	band.AvgMargin = band.MaxMargin + 0.000001		//set AvgMargin to be MaxMargin++
+	band.AvgMargin = band.MaxMargin.Add(dec(0.000001))		//set AvgMargin to be MaxMargin++
 	assert.Error(t, band.VerifyBand())				//assert band is invalid
 }
 
 //Test if band has improper parameters
 func Test_Band_VerifyBand4(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]						//get all bands for token pair ETHDAI
 	band := &bands.BuyBands[0]						//get buy band
 	band.MinMargin = band.MaxMargin					//set MinMargin to be MaxMargin
@@ -176,59 +191,59 @@ func Test_Band_VerifyBand4(t *testing.T) {
 //Test if band has improper parameters
 func Test_Band_VerifyBand5(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]						//get all bands for token pair ETHDAI
 	band := &bands.BuyBands[0]						//get buy band
-	band.MinAmount = band.AvgAmount + 0.00001		//set MinAmount to be AvgAmount++
+	band.MinAmount = band.AvgAmount.Add(dec(0.00001))		//set MinAmount to be AvgAmount++
 	assert.Error(t, band.VerifyBand())				//assert band is invalid
 }
 
 //Test if band has improper parameters
 func Test_Band_VerifyBand6(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]						//get all bands for token pair ETHDAI
 	band := &bands.BuyBands[0]						//get buy band
-	band.AvgAmount = band.MaxAmount + 0.00001		//set AvgAmount to be MaxAmount++
+	band.AvgAmount = band.MaxAmount.Add(dec(0.00001))		//set AvgAmount to be MaxAmount++
 	assert.Error(t, band.VerifyBand())				//assert band is invalid
 }
 
 //Test if band has improper parameters
 func Test_Band_VerifyBand7(t *testing.T) {
 	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands()							//load bands from bands.json
+	allBands.LoadBands()							//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]						//get all bands for token pair ETHDAI
 	band := &bands.BuyBands[0]						//get buy band
-	band.MinAmount = band.MaxAmount + 0.00001		//set MinAmount to be MaxAmount++
+	band.MinAmount = band.MaxAmount.Add(dec(0.00001))		//set MinAmount to be MaxAmount++
 	assert.Error(t, band.VerifyBand())				//assert band is invalid
 }
 
 func Test_Band_ExecessiveOrders1(t *testing.T) {
-	sBand := SellBand{Band{0.1, 0.15, 0.2, 4.0, 6.0, 8.0, 0.01}}	//create buy band
-	//MinMargin - 0.1, MaxMargin = 0.2, MinAmount = 4, MaxAmount < 8
-	targetPrice := 1.0												//set ref price to 8.5
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(4.0), AvgAmount: dec(6.0), MaxAmount: dec(8.0), DustCutoff: dec(0.01)}}	//create buy band
+	//MinMargin - 0.1, MaxMargin = 0.2, MinAmount = 4, MaxAmount = 8
+	targetPrice := dec(1.0)												//set ref price to 8.5
 	//With RefPrice of 1.0 -> MinPrice = 1.1 & MaxPrice = 1.2
 	askOrders := []*Order{					 						//create orders
-		&Order{"DAIUSD", "BK01", 1, 1.1, 14.13, 1, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK02", 1, 1.12, 10.17, 2, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK03", 1, 1.16, 11.84, 3, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK04", 1, 1.20, 12.96, 4, 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK01", 1, dec(1.1), dec(14.13), dec(1), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK02", 1, dec(1.12), dec(10.17), dec(2), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK03", 1, dec(1.16), dec(11.84), dec(3), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK04", 1, dec(1.20), dec(12.96), dec(4), 1, "New", 0, 0, "1515755945"},	//Order in-band
 	}
 	ordersToKill := sBand.ExcessiveOrders(askOrders, targetPrice)	//find which orders need to be cancelled to stay under band.MaxAmount
-	assert.Contains(t, ordersToKill, askOrders[2])					//check that order BK03 was selected to be cancelled
+	assert.Contains(t, ordersToKill, askOrders[3])					//check that order BK04 furthest from avgMargin was selected to be cancelled
 	assert.Equal(t, len(ordersToKill), 1)							//check that no other orders were specified to be cancelled
 }
 
 func Test_Band_ExecessiveOrders2(t *testing.T) {
-	bBand := BuyBand{Band{0.1, 0.11, 0.2, 4.0, 6.0, 7.0, 0.01}}	//create buy band
-	//MinMargin - 0.1, MaxMargin = 0.2, MinAmount = 4, MaxAmount < 7
-	targetPrice := 1.0													//set ref price to 1.0
+	bBand := BuyBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.11), MaxMargin: dec(0.2), MinAmount: dec(4.0), AvgAmount: dec(6.0), MaxAmount: dec(7.0), DustCutoff: dec(0.01)}}	//create buy band
+	//MinMargin - 0.1, MaxMargin = 0.2, MinAmount = 4, MaxAmount = 7
+	targetPrice := dec(1.0)													//set ref price to 1.0
 	//With RefPrice of 1.0 -> MinPrice = 0.9 & MaxPrice = 0.8
 	bidOrders := []*Order{												//create orders
-		&Order{"DAIUSD", "BK01", 0, 0.8907, 14.13, 1, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK02", 0, 0.8714, 10.17, 2, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK03", 0, 0.8465, 11.84, 3, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK04", 0, 0.8277, 12.96, 4, 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK01", 0, dec(0.8907), dec(14.13), dec(1), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK02", 0, dec(0.8714), dec(10.17), dec(2), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK03", 0, dec(0.8465), dec(11.84), dec(3), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK04", 0, dec(0.8277), dec(12.96), dec(4), 1, "New", 0, 0, "1515755945"},	//Order in-band
 	}
 	ordersToKill := bBand.ExcessiveOrders(bidOrders, targetPrice)		//find which orders need to be cancelled to stay under band.MaxAmount
 	assert.Contains(t, ordersToKill, bidOrders[3])						//check that order BK03 was selected to be cancelled
@@ -236,312 +251,501 @@ func Test_Band_ExecessiveOrders2(t *testing.T) {
 }
 
 func Test_Band_ExecessiveOrders3(t *testing.T) {
-	bBand := BuyBand{Band{0.01, 0.013, 0.02, 4.0, 6.0, 8.0, 0.01}}	//create buy band
-	//MinMargin - 0.01, MaxMargin = 0.02, MinAmount = 4, MaxAmount < 8
-	targetPrice := 1.0													//set ref price to 1.0
+	bBand := BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.013), MaxMargin: dec(0.02), MinAmount: dec(4.0), AvgAmount: dec(6.0), MaxAmount: dec(8.0), DustCutoff: dec(0.01)}}	//create buy band
+	//MinMargin - 0.01, MaxMargin = 0.02, MinAmount = 4, MaxAmount = 8
+	targetPrice := dec(1.0)													//set ref price to 1.0
 	//With RefPrice of 1.0 -> MinPrice = 0.9 & MaxPrice = 0.8
 	bidOrders := []*Order{												//create orders
-		&Order{"DAIUSD", "BK00", 0, 0.9952, 7.21, 1, 1, "New", 0, 0, "1515755945"},		//Order out-of-band
-		&Order{"DAIUSD", "BK01", 0, 0.9899, 14.13, 1, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK02", 0, 0.9877, 10.17, 2, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK03", 0, 0.9832, 11.84, 3, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK04", 0, 0.9801, 12.96, 4, 1, "New", 0, 0, "1515755945"},	//Order in-band
-		&Order{"DAIUSD", "BK05", 0, 0.9762, 8.32, 1, 1, "New", 0, 0, "1515755945"},		//Order out-of-band
+		&Order{"DAIUSD", "BK00", 0, dec(0.9952), dec(7.21), dec(1), 1, "New", 0, 0, "1515755945"},		//Order out-of-band
+		&Order{"DAIUSD", "BK01", 0, dec(0.9899), dec(14.13), dec(1), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK02", 0, dec(0.9877), dec(10.17), dec(2), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK03", 0, dec(0.9832), dec(11.84), dec(3), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK04", 0, dec(0.9801), dec(12.96), dec(4), 1, "New", 0, 0, "1515755945"},	//Order in-band
+		&Order{"DAIUSD", "BK05", 0, dec(0.9762), dec(8.32), dec(1), 1, "New", 0, 0, "1515755945"},		//Order out-of-band
 	}
 	ordersToKill := bBand.ExcessiveOrders(bidOrders, targetPrice)		//find which orders need to be cancelled to stay under band.MaxAmount
-	assert.Contains(t, ordersToKill, bidOrders[3])						//check that order BK03 was selected to be cancelled
+	assert.Contains(t, ordersToKill, bidOrders[4])						//check that order BK04 furthest from avgMargin was selected to be cancelled
 	assert.Equal(t, len(ordersToKill), 1)								//check that no other orders were specified to be cancelled
 }
 
-//No return value, can't test individually
-//This is tested as a dependancy in excessiveOrders
-func Test_Band_GetAllCombinationsOfSizeN(t *testing.T) {
-	allBands := make(AllBands)						//create bands instance
-	allBands.LoadBands() 							//load banks from bands.json
-	bands := allBands["ETHDAI"]						//get all bands for token pair ETHDAI
-	band := &bands.BuyBands[0]						//get buy band
-	askOrders := []*Order{							//create orders
-		&Order{"DAIUSD", "BK01", 1, 1.000428, 14.13, 1, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK02", 1, 1.000502, 10.17, 2, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK03", 1, 1.000675, 11.84, 3, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK04", 1, 1.000788, 12.96, 4, 1, "New", 0, 0, "1515755945"},
+//Test fewer cancellations win over keeping orders close to avgMargin
+func Test_Band_ExecessiveOrdersFewestCancellations(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(6.0), MaxAmount: dec(7.0), DustCutoff: dec(0.01)}}	//MaxAmount 7
+	askOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 1, dec(1.15), dec(6.0), dec(6.0), 1, "New", 0, 0, "1515755945"},	//at avgMargin but large
+		&Order{"DAIUSD", "BK02", 1, dec(1.19), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK03", 1, dec(1.20), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
 	}
-	for i := 1; i <= len(askOrders); i++ {
-		band.GetAllCombinationsOfSizeN(askOrders, i, band, 100.0)
+	ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+	assert.Equal(t, []*Order{askOrders[0]}, ordersToKill)	//one cancellation instead of two
+}
+
+//Test the newest order is cancelled when orders are equally far from avgMargin
+func Test_Band_ExecessiveOrdersNewestFirst(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01)}}	//MaxAmount = 5
+	askOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 1, dec(1.18), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755900"},
+		&Order{"DAIUSD", "BK02", 1, dec(1.18), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755990"},	//newest
+		&Order{"DAIUSD", "BK03", 1, dec(1.18), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
 	}
+	ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+	assert.Equal(t, []*Order{askOrders[1]}, ordersToKill)
 }
 
-//No return value, can't test individually
-//This is tested as a dependancy in excessiveOrders
-func Test_Band_CombinationUtil(t *testing.T) {
+//Test selection stays fast and within the band for many orders
+func Test_Band_ExecessiveOrdersManyOrders(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(10.0), AvgAmount: dec(50.0), MaxAmount: dec(100.0), DustCutoff: dec(0.01)}}
+	askOrders := []*Order{}
+	for i := 0; i < 200; i++ {
+		askOrders = append(askOrders, &Order{"DAIUSD", fmt.Sprintf("BK%03d", i), 1, dec(1.1 + 0.0005 * float64(i)), dec(1.0), dec(1.0), 1, "New", int64(i), 0, "1515755945"})
+	}
+	ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+	assert.Len(t, ordersToKill, 100)	//keep 100 orders, the MaxAmount
+	for _, order := range ordersToKill {
+		assert.True(t, order.Price.Sub(dec(1.15)).Abs().GreaterThan(dec(0.0245)))	//the orders closest to avgMargin are kept
+	}
+}
 
+//Test queue priority policy cancels the newest orders even if that takes more cancellations
+func Test_Band_ExecessiveOrdersQueuePriority(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(6.0), MaxAmount: dec(7.0), DustCutoff: dec(0.01), CancelPolicy: CancelQueuePriority}}	//MaxAmount 7
+	askOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 1, dec(1.15), dec(6.0), dec(6.0), 1, "New", 0, 0, "1515755900"},	//oldest and largest
+		&Order{"DAIUSD", "BK02", 1, dec(1.19), dec(2.0), dec(2.0), 1, "New", 1, 0, "1515755945"},
+		&Order{"DAIUSD", "BK03", 1, dec(1.12), dec(2.0), dec(2.0), 1, "New", 2, 0, "1515755945"},	//newest by TxSeqNo
+	}
+	ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+	assert.Equal(t, []*Order{askOrders[2], askOrders[1]}, ordersToKill)	//fewest policy would cancel BK01 only
+}
+
+//Test queue priority policy cancels the order furthest from avgMargin among orders of the same age
+func Test_Band_ExecessiveOrdersQueuePrioritySameAge(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), CancelPolicy: CancelQueuePriority}}	//MaxAmount = 5
+	askOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 1, dec(1.14), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK02", 1, dec(1.19), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},	//furthest
+		&Order{"DAIUSD", "BK03", 1, dec(1.16), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
+	}
+	ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+	assert.Equal(t, []*Order{askOrders[1]}, ordersToKill)
+}
+
+//Test nothing is cancelled when the band holds exactly its maximum
+func Test_Band_ExecessiveOrdersAtMaxAmount(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(4.0), DustCutoff: dec(0.01)}}	//MaxAmount 4
+	askOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 1, dec(1.14), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK02", 1, dec(1.19), dec(2.0), dec(2.0), 1, "New", 0, 0, "1515755945"},
+	}
+	assert.Empty(t, sBand.ExcessiveOrders(askOrders, dec(1.0)))
+}
+
+//Test both policies skip orders whose cancellation would leave the band below MinAmount
+func Test_Band_ExecessiveOrdersKeepMinAmount(t *testing.T) {
+	for _, policy := range []string{CancelFewest, CancelQueuePriority} {
+		sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(4.0), AvgAmount: dec(4.5), MaxAmount: dec(5.0), DustCutoff: dec(0.01), CancelPolicy: policy}}	//MinAmount 4, MaxAmount 5
+		askOrders := []*Order{
+			&Order{"DAIUSD", "BK01", 1, dec(1.19), dec(3.0), dec(3.0), 1, "New", 0, 0, "1515755990"},	//furthest and newest, too large to cancel
+			&Order{"DAIUSD", "BK02", 1, dec(1.18), dec(1.0), dec(1.0), 1, "New", 0, 0, "1515755945"},
+			&Order{"DAIUSD", "BK03", 1, dec(1.15), dec(1.0), dec(1.0), 1, "New", 0, 0, "1515755900"},
+			&Order{"DAIUSD", "BK04", 1, dec(1.16), dec(1.0), dec(1.0), 1, "New", 0, 0, "1515755900"},
+		}
+		ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+		assert.Equal(t, []*Order{askOrders[1]}, ordersToKill, policy)	//5 left instead of 3
+	}
+}
+
+//Test MaxAmount is enforced even when no choice of orders keeps MinAmount
+func Test_Band_ExecessiveOrdersMaxAmountWins(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(4.0), AvgAmount: dec(4.5), MaxAmount: dec(5.0), DustCutoff: dec(0.01)}}	//MinAmount 4, MaxAmount 5
+	askOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 1, dec(1.14), dec(3.0), dec(3.0), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK02", 1, dec(1.19), dec(3.0), dec(3.0), 1, "New", 0, 0, "1515755945"},	//furthest
+	}
+	ordersToKill := sBand.ExcessiveOrders(askOrders, dec(1.0))
+	assert.Equal(t, []*Order{askOrders[1]}, ordersToKill)
+}
+
+//Test cancel policy is read from bands.json and unknown policies are rejected
+func Test_Band_CancelPolicy(t *testing.T) {
+	allBands := make(AllBands)
+	err := json.Unmarshal([]byte(`{"ETHDAI": {"sellBands": [{"minMargin": 0.1, "avgMargin": 0.15, "maxMargin": 0.2, "minAmount": 1, "avgAmount": 2, "maxAmount": 3, "cancelPolicy": "queuePriority"}]}}`), &allBands)
+	assert.NoError(t, err)
+	band := allBands["ETHDAI"].SellBands[0]
+	assert.Equal(t, CancelQueuePriority, band.GetCancelPolicy())
+	assert.NoError(t, band.VerifyBand())
+	band.CancelPolicy = ""
+	assert.Equal(t, CancelFewest, band.GetCancelPolicy())	//default
+	band.CancelPolicy = "oldest"
+	assert.Error(t, band.VerifyBand())
+}
+
+//Test a band without ladder tops up with a single order at avgMargin
+func Test_Band_RungsNoLadder(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01)}}
+	assert.Equal(t, []Rung{Rung{sBand.AvgPrice(dec(1.0)), dec(3.0)}}, sBand.Rungs(dec(3.0), dec(1.0)))
+}
+
+//Test linear ladder spreads orders evenly inside the band
+func Test_Band_RungsLinear(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), Ladder: &Ladder{Orders: 4}}}
+	rungs := sBand.Rungs(dec(4.0), dec(100.0))
+	assert.Len(t, rungs, 4)
+	for i, price := range []float64{111.25, 113.75, 116.25, 118.75} {
+		assert.InDelta(t, price, rungs[i].Price.InexactFloat64(), 1e-9)
+		assert.Equal(t, "1", rungs[i].Amount.String())
+		assert.True(t, sBand.Includes(rungs[i].Price, dec(100.0)))
+	}
+	bBand := BuyBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), Ladder: &Ladder{Orders: 2}}}
+	rungs = bBand.Rungs(dec(4.0), dec(100.0))
+	assert.InDelta(t, 87.5, rungs[0].Price.InexactFloat64(), 1e-9)	//closest to the reference price first
+	assert.InDelta(t, 82.5, rungs[1].Price.InexactFloat64(), 1e-9)
+}
+
+//Test geometric ladder places orders denser close to the reference price
+func Test_Band_RungsGeometric(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.04), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), Ladder: &Ladder{Orders: 2, Placement: PlaceGeometric}}}
+	rungs := sBand.Rungs(dec(2.0), dec(100.0))
+	assert.InDelta(t, 101.41421356, rungs[0].Price.InexactFloat64(), 1e-6)	//1% * 4^(1/4)
+	assert.InDelta(t, 102.82842712, rungs[1].Price.InexactFloat64(), 1e-6)	//1% * 4^(3/4)
+}
+
+//Test random ladder keeps orders inside the band, closest first
+func Test_Band_RungsRandom(t *testing.T) {
+	defer func(random func() (float64)) { ladderRandom = random }(ladderRandom)
+	draws := []float64{1.0, 0.0, 0.5}
+	ladderRandom = func() (float64) {
+		draw := draws[0]
+		draws = draws[1:]
+		return draw
+	}
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), Ladder: &Ladder{Orders: 3, Placement: PlaceRandom}}}
+	rungs := sBand.Rungs(dec(3.0), dec(100.0))
+	assert.InDelta(t, 110.1, rungs[0].Price.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 115.0, rungs[1].Price.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 119.9, rungs[2].Price.InexactFloat64(), 1e-9)
+}
+
+//Test per order size limits of a ladder
+func Test_Band_RungsOrderSizes(t *testing.T) {
+	sBand := SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), Ladder: &Ladder{Orders: 5, MinOrderAmount: dec(0.5)}}}
+	rungs := sBand.Rungs(dec(1.2), dec(100.0))
+	assert.Len(t, rungs, 2)						//fewer orders rather than orders below 0.5
+	assert.InDelta(t, 0.6, rungs[0].Amount.InexactFloat64(), 1e-9)
+	assert.Len(t, sBand.Rungs(dec(0.5), dec(100.0)), 1)
+	assert.Empty(t, sBand.Rungs(dec(0.4), dec(100.0)))	//below MinOrderAmount, nothing placed
+	sBand.Ladder.MaxOrderAmount = dec(0.2)
+	sBand.Ladder.MinOrderAmount = dec(0.0)
+	rungs = sBand.Rungs(dec(2.0), dec(100.0))
+	assert.Len(t, rungs, 5)
+	assert.Equal(t, "0.2", rungs[0].Amount.String())		//capped, the rest is placed next cycle
+	assert.Len(t, sBand.Rungs(dec(0.1), dec(100.0)), 5)	//dust is left to the band's dustCutoff
+}
+
+//Test ladder verification
+func Test_Band_VerifyLadder(t *testing.T) {
+	band := Band{MinMargin: dec(0.1), AvgMargin: dec(0.15), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(3.0), MaxAmount: dec(5.0), DustCutoff: dec(0.01), Ladder: &Ladder{Orders: 3}}
+	assert.NoError(t, band.VerifyBand())
+	band.Ladder = &Ladder{Orders: 0}
+	assert.Error(t, band.VerifyBand())
+	band.Ladder = &Ladder{Orders: 3, Placement: "spiral"}
+	assert.Error(t, band.VerifyBand())
+	band.Ladder = &Ladder{Orders: 3, MinOrderAmount: dec(2.0), MaxOrderAmount: dec(1.0)}
+	assert.Error(t, band.VerifyBand())
+}
+
+//Test bid amounts are converted at each order's own price, or not at all for base denominated buy bands
+func Test_Band_ExecessiveOrdersUnits(t *testing.T) {
+	bidOrders := []*Order{
+		&Order{"DAIUSD", "BK01", 0, dec(0.85), dec(4.0), dec(4.0), 1, "New", 0, 0, "1515755945"},	//3.4 USD
+		&Order{"DAIUSD", "BK02", 0, dec(0.89), dec(4.0), dec(4.0), 1, "New", 0, 0, "1515755945"},	//3.56 USD
+	}
+	bBand := BuyBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.11), MaxMargin: dec(0.2), MinAmount: dec(1.0), AvgAmount: dec(6.0), MaxAmount: dec(7.0), DustCutoff: dec(0.01)}}	//MaxAmount = 7 USD
+	assert.Equal(t, UnitQuote, bBand.GetUnit())
+	assert.Empty(t, bBand.ExcessiveOrders(bidOrders, dec(1.0)))	//6.96 USD, 7.12 USD at the band's 0.89 average price
+	bBand.Unit = UnitBase	//MaxAmount = 7 DAI
+	assert.Equal(t, []*Order{bidOrders[0]}, bBand.ExcessiveOrders(bidOrders, dec(1.0)))	//8 DAI
+	bBand.Unit = "ETH"
+	assert.Error(t, bBand.VerifyBand())
+}
+
+//Test totals in either unit
+func Test_Band_TotalAmountIn(t *testing.T) {
+	orders := []*Order{
+		&Order{"ETHDAI", "BK01", 1, dec(1000.0), dec(2.0), dec(1.5), 1, "New", 0, 0, "1515755945"},
+		&Order{"ETHDAI", "BK02", 1, dec(1100.0), dec(1.0), dec(1.0), 1, "New", 0, 0, "1515755945"},
+	}
+	band := SellBand{}
+	assert.Equal(t, UnitBase, band.GetUnit())
+	assert.Equal(t, "2.5", band.TotalAmountIn(orders, UnitBase).String())
+	assert.Equal(t, "2600", band.TotalAmountIn(orders, UnitQuote).String())
 }
 
 //Test if band includes bid order with price at MinMargin
 func Test_Band_Includes1(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 - band.MinMargin) * targetPrice	//calculate bid price to be on exactly MinMargin boundary
-	assert.True(t, band.Includes(orderPrice, 1.00))		//assert bid order is in boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Sub(band.MinMargin).Mul(targetPrice)	//calculate bid price to be on exactly MinMargin boundary
+	assert.True(t, band.Includes(orderPrice, dec(1.00)))		//assert bid order is in boundary
 }
 
 //Test if band includes bid order with price at MinMargin++
 func Test_Band_Includes2(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 - band.MinMargin) * targetPrice + 0.000001	//calculate bid price to be just out of MinMargin boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Sub(band.MinMargin).Mul(targetPrice).Add(dec(0.000001))	//calculate bid price to be just out of MinMargin boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert bid order is out of boundary
 }
 
 //Test if band includes bid order with price at MaxMargin
 func Test_Band_Includes3(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]		//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 - band.MaxMargin) * targetPrice		//calculate bid price to be exactly MaxMargin boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Sub(band.MaxMargin).Mul(targetPrice)		//calculate bid price to be exactly MaxMargin boundary
 	assert.True(t, band.Includes(orderPrice, targetPrice))	//assert bid order is in boundary
 }
 
 //Test if band includes bid order with price at MaxMargin--
 func Test_Band_Includes4(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 - band.MaxMargin) * targetPrice - 0.000001	//calculate bid price to be just out of MaxMargin boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Sub(band.MaxMargin).Mul(targetPrice).Sub(dec(0.000001))	//calculate bid price to be just out of MaxMargin boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert bid order is out of boundary
 }
 
 //Test if band includes ask order with price at MinMargin
 func Test_Band_Includes5(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 + band.MinMargin) * targetPrice	//calculate ask price to be exactly MinMargin boundary
-	assert.True(t, band.Includes(orderPrice, 1.00))		//assert ask order is in boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Add(band.MinMargin).Mul(targetPrice)	//calculate ask price to be exactly MinMargin boundary
+	assert.True(t, band.Includes(orderPrice, dec(1.00)))		//assert ask order is in boundary
 }
 
 //Test if band includes ask order with price at MinMargin--
 func Test_Band_Includes6(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 + band.MinMargin) * targetPrice - 0.000001	//calculate ask price to be just out of MaxMargin boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Add(band.MinMargin).Mul(targetPrice).Sub(dec(0.000001))	//calculate ask price to be just out of MaxMargin boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert ask order is out of boundary
 }
 
 //Test if band includes ask order with price at MaxMargin
 func Test_Band_Includes7(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 + band.MaxMargin) * targetPrice		//calculate ask price to be exactly MaxMargin boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Add(band.MaxMargin).Mul(targetPrice)		//calculate ask price to be exactly MaxMargin boundary
 	assert.True(t, band.Includes(orderPrice, targetPrice))	//assert ask order is in boundary
 }
 
 //Test if band includes ask order with price at MaxMargin++
 func Test_Band_Includes8(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.00					//set ref price of asset to 1
-	orderPrice := (1 + band.MaxMargin) * targetPrice + 0.000001	//calculate ask price to be just out of MaxMargin boundary
+	targetPrice := dec(1.00)					//set ref price of asset to 1
+	orderPrice := one.Add(band.MaxMargin).Mul(targetPrice).Add(dec(0.000001))	//calculate ask price to be just out of MaxMargin boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert ask order is out of boundary
 }
 
 func Test_Band_TotalAmount1(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	askOrders := []*Order{
-		&Order{"DAIUSD", "BK01", 1, 1.000428, 14.13, 10.13, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK02", 1, 1.000502, 10.17, 10.17, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK03", 1, 1.000675, 11.84, 10.84, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK04", 1, 1.000788, 12.96, 10.96, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK05", 1, 1.000972, 19.92, 10.92, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK06", 1, 1.001002, 22.64, 10.64, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK07", 1, 1.001183, 11.81, 10.81, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK08", 1, 1.001599, 17.77, 10.77, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK09", 1, 1.001711, 15.08, 10.08, 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK01", 1, dec(1.000428), dec(14.13), dec(10.13), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK02", 1, dec(1.000502), dec(10.17), dec(10.17), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK03", 1, dec(1.000675), dec(11.84), dec(10.84), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK04", 1, dec(1.000788), dec(12.96), dec(10.96), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK05", 1, dec(1.000972), dec(19.92), dec(10.92), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK06", 1, dec(1.001002), dec(22.64), dec(10.64), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK07", 1, dec(1.001183), dec(11.81), dec(10.81), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK08", 1, dec(1.001599), dec(17.77), dec(10.77), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK09", 1, dec(1.001711), dec(15.08), dec(10.08), 1, "New", 0, 0, "1515755945"},
 	}
 	band := bands.BuyBands[0]			//get buy band
-	assert.Equal(t, band.TotalAmount(askOrders), 95.32)	//assert total of all orders
+	assert.Equal(t, band.TotalAmount(askOrders).String(), "95.32")	//assert total of all orders
 }
 
 func Test_Band_TotalAmount2(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	askOrders := []*Order{
-		&Order{"DAIUSD", "BK01", 1, 1.000428, 14.13, 10.13, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK02", 1, 1.000502, 10.17, 10.17, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK03", 1, 1.000675, 11.84, 10.84, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK04", 1, 1.000788, 12.96, 10.96, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK05", 1, 1.000972, 19.92, 10.92, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK06", 1, 1.001002, 22.64, 10.64, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK07", 1, 1.001183, 11.81, 10.81, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK08", 1, 1.001599, 17.77, 10.77, 1, "New", 0, 0, "1515755945"},
-		&Order{"DAIUSD", "BK09", 1, 1.001711, 15.08, 10.08, 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK01", 1, dec(1.000428), dec(14.13), dec(10.13), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK02", 1, dec(1.000502), dec(10.17), dec(10.17), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK03", 1, dec(1.000675), dec(11.84), dec(10.84), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK04", 1, dec(1.000788), dec(12.96), dec(10.96), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK05", 1, dec(1.000972), dec(19.92), dec(10.92), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK06", 1, dec(1.001002), dec(22.64), dec(10.64), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK07", 1, dec(1.001183), dec(11.81), dec(10.81), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK08", 1, dec(1.001599), dec(17.77), dec(10.77), 1, "New", 0, 0, "1515755945"},
+		&Order{"DAIUSD", "BK09", 1, dec(1.001711), dec(15.08), dec(10.08), 1, "New", 0, 0, "1515755945"},
 	}
 	band := bands.SellBands[0]			//get sell band
-	assert.Equal(t, band.TotalAmount(askOrders), 95.32)	//assert total of all orders
+	assert.Equal(t, band.TotalAmount(askOrders).String(), "95.32")	//assert total of all orders
 }
 
 //BUY BAND
 func Test_BuyBand_Includes1(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 - band.MinMargin) * targetPrice			//calculate bid price to be on exactly MinMargin boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Sub(band.MinMargin).Mul(targetPrice)			//calculate bid price to be on exactly MinMargin boundary
 	assert.True(t, band.Includes(orderPrice, targetPrice))		//assert bid order is in boundary
 }
 
 func Test_BuyBand_Includes2(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 - band.MinMargin) * targetPrice + 0.00001	//calculate bid price to be just out of  MinMargin++ boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Sub(band.MinMargin).Mul(targetPrice).Add(dec(0.00001))	//calculate bid price to be just out of  MinMargin++ boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert bid order is in boundary
 }
 
 func Test_BuyBand_Includes3(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 - band.MaxMargin) * targetPrice			//calculate bid price to be on exactly MaxMargin boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Sub(band.MaxMargin).Mul(targetPrice)			//calculate bid price to be on exactly MaxMargin boundary
 	assert.True(t, band.Includes(orderPrice, targetPrice))		//assert bid order is in boundary
 }
 
 func Test_BuyBand_Includes4(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 - band.MaxMargin) * targetPrice - 0.00001	//calculate bid price to be just out of  MaxMargin-- boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Sub(band.MaxMargin).Mul(targetPrice).Sub(dec(0.00001))	//calculate bid price to be just out of  MaxMargin-- boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert bid order is in boundary
 }
 
 
 func Test_BuyBand_ApplyMargin1(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0					//set ref price to 1.0
+	targetPrice := dec(1.0)					//set ref price to 1.0
 	margin := band.MinMargin 			//set margin to MinMargin
-	assert.Equal(t, band.ApplyMargin(targetPrice, margin), (1 - margin) * targetPrice)	//assert ApplyMargin equals adjusted margin
+	assert.Equal(t, band.ApplyMargin(targetPrice, margin).String(), one.Sub(margin).Mul(targetPrice).String())	//assert ApplyMargin equals adjusted margin
 }
 
 func Test_BuyBand_ApplyMargin2(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0					//set ref price to 1.0
+	targetPrice := dec(1.0)					//set ref price to 1.0
 	margin := band.MaxMargin 			//set margin to MaxMargin
-	assert.Equal(t, band.ApplyMargin(targetPrice, margin), (1 - margin) * targetPrice)	//assert ApplyMargin equals adjusted margin
+	assert.Equal(t, band.ApplyMargin(targetPrice, margin).String(), one.Sub(margin).Mul(targetPrice).String())	//assert ApplyMargin equals adjusted margin
 }
 
 func Test_BuyBand_AvgPrice(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.BuyBands[0]			//get buy band
-	targetPrice := 1.0					//set ref price to 1.0
+	targetPrice := dec(1.0)					//set ref price to 1.0
 	margin := band.AvgMargin 			//set margin to AvgMargin
-	assert.Equal(t, band.ApplyMargin(targetPrice, margin), (1 - margin) * targetPrice)	//assert ApplyMargin equals adjusted margin
+	assert.Equal(t, band.ApplyMargin(targetPrice, margin).String(), one.Sub(margin).Mul(targetPrice).String())	//assert ApplyMargin equals adjusted margin
 }
 
 //SELL BAND
 func Test_SellBand_Includes1(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 + band.MinMargin) * targetPrice			//calculate bid price to be on exactly MinMargin boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Add(band.MinMargin).Mul(targetPrice)			//calculate bid price to be on exactly MinMargin boundary
 	assert.True(t, band.Includes(orderPrice, targetPrice))		//assert ask order is in boundary
 }
 
 func Test_SellBand_Includes2(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 + band.MinMargin) * targetPrice - 0.00001	//calculate bid price to be just out of  MinMargin-- boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Add(band.MinMargin).Mul(targetPrice).Sub(dec(0.00001))	//calculate bid price to be just out of  MinMargin-- boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert ask order is in boundary
 }
 
 func Test_SellBand_Includes3(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 + band.MaxMargin) * targetPrice			//calculate bid price to be on exactly MaxMargin boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Add(band.MaxMargin).Mul(targetPrice)			//calculate bid price to be on exactly MaxMargin boundary
 	assert.True(t, band.Includes(orderPrice, targetPrice))		//assert ask order is in boundary
 }
 
 func Test_SellBand_Includes4(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0 					//set ref price to 1.0
-	orderPrice := (1 + band.MaxMargin) * targetPrice + 0.00001	//calculate bid price to be just out of MinMargin boundary
+	targetPrice := dec(1.0) 					//set ref price to 1.0
+	orderPrice := one.Add(band.MaxMargin).Mul(targetPrice).Add(dec(0.00001))	//calculate bid price to be just out of MinMargin boundary
 	assert.False(t, band.Includes(orderPrice, targetPrice))		//assert ask order is in boundary
 }
 
 func Test_SellBand_ApplyMargin1(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0					//set ref price to 1.0
+	targetPrice := dec(1.0)					//set ref price to 1.0
 	margin := band.MinMargin 			//set margin to MinMargin
-	assert.Equal(t, band.ApplyMargin(targetPrice, margin), (1 + margin) * targetPrice)	//assert ApplyMargin equals adjusted margin
+	assert.Equal(t, band.ApplyMargin(targetPrice, margin).String(), one.Add(margin).Mul(targetPrice).String())	//assert ApplyMargin equals adjusted margin
 }
 
 func Test_SellBand_ApplyMargin2(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0					//set ref prie to 1.0
+	targetPrice := dec(1.0)					//set ref prie to 1.0
 	margin := band.MaxMargin 			//set margin to MaxMargin
-	assert.Equal(t, band.ApplyMargin(targetPrice, margin), (1 + margin) * targetPrice)	//assert ApplyMargin equals adjusted margin
+	assert.Equal(t, band.ApplyMargin(targetPrice, margin).String(), one.Add(margin).Mul(targetPrice).String())	//assert ApplyMargin equals adjusted margin
 }
 
 func Test_SellBand_AvgPrice(t *testing.T) {
 	allBands := make(AllBands)			//create bands instance
-	allBands.LoadBands() 				//load banks from bands.json
+	allBands.LoadBands() 				//load bands from testdata/bands.json
 	bands := allBands["ETHDAI"]			//get all bands for token pair ETHDAI
 	band := bands.SellBands[0]			//get sell band
-	targetPrice := 1.0					//set ref price to 1.0
+	targetPrice := dec(1.0)					//set ref price to 1.0
 	margin := band.AvgMargin 			//set margin to AvgMargin
-	assert.Equal(t, band.ApplyMargin(targetPrice, margin), (1 + margin) * targetPrice)	//assert ApplyMargin equals adjusted margin
+	assert.Equal(t, band.ApplyMargin(targetPrice, margin).String(), one.Add(margin).Mul(targetPrice).String())	//assert ApplyMargin equals adjusted margin
 }
\ No newline at end of file
@@ -0,0 +1,89 @@
+package maker
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+//Reasons a pair is not being quoted
+const (
+	HaltNoPrice			= "no_price"		//Feed failed to produce a price
+	HaltInvalidPrice	= "invalid_price"	//Reference price is not a finite positive number
+	HaltStalePrice		= "stale_price"		//Reference price older than maxAgeSec
+	HaltPriceJump		= "price_jump"		//Reference price moved more than maxJump since the last cycle
+)
+
+//PairStatus is the outcome of the last market making cycle of a token pair
+type PairStatus struct {
+	Pair		string
+	Quoting		bool		//False when the price guard halted the pair
+	Halt		string		//One of the Halt constants when not quoting
+	Reason		string		//Human readable explanation of the halt
+	Price		float64		//Last reference price fetched
+	PriceTime	time.Time	//Time of the oldest reading behind Price
+	Updated		time.Time	//End of the cycle which set this status
+	Version		int			//Version of the config and bands snapshot the cycle used, see Reloader
+}
+
+//Globals
+var statusMutex sync.Mutex
+var pairStatus = make(map[string]PairStatus)
+var lastPrices = make(map[string]float64)
+
+//Checks a reference price against the price guard. Returns the halt and its reason, or empty strings if the price is safe to quote from.
+//The price is remembered as the last price of the pair either way, so a genuine move is only halted for one cycle.
+//Invalid prices are always halted, even on a pair's first cycle, and are not remembered.
+func CheckPrice(pair string, price float64, priceTime time.Time, guard config.PriceGuard, now time.Time) (halt string, reason string) {
+	if !IsValidPrice(price) {
+		return HaltInvalidPrice, fmt.Sprintf("Reference price %f is not a finite positive number", price)
+	}
+	statusMutex.Lock()
+	lastPrice, seen := lastPrices[pair]
+	lastPrices[pair] = price
+	statusMutex.Unlock()
+
+	age := now.Sub(priceTime)
+	if guard.MaxAgeSec > 0 && age > time.Duration(guard.MaxAgeSec) * time.Second {
+		return HaltStalePrice, fmt.Sprintf("Reference price is %s old, limit is %ds", age.Truncate(time.Second), guard.MaxAgeSec)
+	}
+	if guard.MaxJump > 0 && seen && lastPrice > 0 {
+		jump := math.Abs(price - lastPrice) / lastPrice
+		if jump > guard.MaxJump {
+			return HaltPriceJump, fmt.Sprintf("Reference price moved %.2f%% from %f to %f since the last cycle, limit is %.2f%%", jump * 100, lastPrice, price, guard.MaxJump * 100)
+		}
+	}
+	return "", ""
+}
+
+//Returns whether a price is finite and positive, i.e. can be quoted from and converted to a decimal
+func IsValidPrice(price float64) (bool) {
+	return price > 0 && !math.IsInf(price, 1)
+}
+
+func setPairStatus(status PairStatus) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	pairStatus[status.Pair] = status
+}
+
+//Returns the status of a token pair and whether it has been through a cycle yet
+func GetPairStatus(pair string) (PairStatus, bool) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	status, ok := pairStatus[pair]
+	return status, ok
+}
+
+//Returns the status of every token pair which has been through a cycle
+func GetStatus() (map[string]PairStatus) {
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	statuses := make(map[string]PairStatus)
+	for pair, status := range pairStatus {
+		statuses[pair] = status
+	}
+	return statuses
+}
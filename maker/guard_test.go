@@ -0,0 +1,88 @@
+package maker
+
+import(
+	"context"
+	"math"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/api"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+func Test_Guard_StalePrice(t *testing.T) {
+	now := time.Now()
+	guard := config.PriceGuard{MaxAgeSec: 60}
+	halt, _ := CheckPrice("GUARDSTALE", 1000.0, now.Add(-30 * time.Second), guard, now)
+	assert.Empty(t, halt)
+	halt, reason := CheckPrice("GUARDSTALE", 1000.0, now.Add(-90 * time.Second), guard, now)
+	assert.Equal(t, HaltStalePrice, halt)
+	assert.Equal(t, "Reference price is 1m30s old, limit is 60s", reason)
+}
+
+func Test_Guard_PriceJump(t *testing.T) {
+	now := time.Now()
+	guard := config.PriceGuard{MaxJump: 0.05}
+	halt, _ := CheckPrice("GUARDJUMP", 1000.0, now, guard, now)
+	assert.Empty(t, halt)		//first price has nothing to compare to
+	halt, _ = CheckPrice("GUARDJUMP", 1040.0, now, guard, now)
+	assert.Empty(t, halt)
+	halt, _ = CheckPrice("GUARDJUMP", 1200.0, now, guard, now)
+	assert.Equal(t, HaltPriceJump, halt)
+	halt, _ = CheckPrice("GUARDJUMP", 1210.0, now, guard, now)
+	assert.Empty(t, halt)		//new level is accepted once it holds
+}
+
+//Test zero, negative, NaN and infinite prices are halted even on the first cycle and not remembered
+func Test_Guard_InvalidPrice(t *testing.T) {
+	now := time.Now()
+	for _, price := range []float64{0, -1.0, math.NaN(), math.Inf(1), math.Inf(-1)} {
+		halt, _ := CheckPrice("GUARDINVALID", price, now, config.PriceGuard{}, now)
+		assert.Equal(t, HaltInvalidPrice, halt, "%f", price)
+	}
+	halt, _ := CheckPrice("GUARDINVALID", 1000.0, now, config.PriceGuard{MaxJump: 0.05}, now)
+	assert.Empty(t, halt)
+	halt, _ = CheckPrice("GUARDINVALID", math.Inf(1), now, config.PriceGuard{MaxJump: 0.05}, now)
+	assert.Equal(t, HaltInvalidPrice, halt)
+	halt, _ = CheckPrice("GUARDINVALID", 1010.0, now, config.PriceGuard{MaxJump: 0.05}, now)
+	assert.Empty(t, halt)		//compared with 1000, not with the invalid price
+}
+
+func Test_Guard_Disabled(t *testing.T) {
+	now := time.Now()
+	CheckPrice("GUARDOFF", 1.0, now, config.PriceGuard{}, now)
+	halt, _ := CheckPrice("GUARDOFF", 100.0, now.Add(-time.Hour), config.PriceGuard{}, now)
+	assert.Empty(t, halt)
+}
+
+//Test a pair is halted, its orders cancelled and its status exposed when the price jumps
+func Test_Guard_MakeMarketsHalt(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"MKR": 10.0, "ETH": 10.0}}
+	configuration := &config.Config{
+		ActivePairs: []string{"MKRETH"},
+		PriceGuard: config.PriceGuard{MaxJump: 0.1, CancelOnHalt: true},
+		Feeds: map[string]config.FeedConfig{
+			"MKRETH": config.FeedConfig{Sources: []config.SourceConfig{config.SourceConfig{Type: "fixed", Price: 1.0}}, Aggregation: "mean", MinSources: 1},
+		},
+	}
+	allBands := AllBands{"MKRETH": Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+	}}
+	MakeMarkets(context.Background(), fake, configuration, allBands)
+	assert.Len(t, fake.created, 2)
+	status, ok := GetPairStatus("MKRETH")
+	assert.True(t, ok)
+	assert.True(t, status.Quoting)
+	assert.Equal(t, 1.0, status.Price)
+
+	fake.orders = []api.Order{api.Order{Code: "MKRETH", OrderId: "BK01", Side: 0, Price: dec(0.98), InitQuantity: dec(2.0), RemQuantity: dec(2.0)}}
+	configuration.Feeds["MKRETH"].Sources[0].Price = 1.5
+	MakeMarkets(context.Background(), fake, configuration, allBands)
+	assert.Len(t, fake.created, 2)					//no new orders
+	assert.Equal(t, []string{"BK01"}, fake.deleted)	//resting order cancelled
+	status, _ = GetPairStatus("MKRETH")
+	assert.False(t, status.Quoting)
+	assert.Equal(t, HaltPriceJump, status.Halt)
+	assert.Contains(t, GetStatus(), "MKRETH")
+}
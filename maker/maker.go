@@ -1,18 +1,18 @@
 package maker
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"math"
 	"os"
-	"os/exec"
-	"sort"
-	"strconv"
-	"strings"
+	"time"
 	"github.com/niklaskunkel/market-maker/api"
 	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/feed"
 	"github.com/niklaskunkel/market-maker/logger"
 	"github.com/niklaskunkel/market-maker/registry"
 	"github.com/olekukonko/tablewriter"
+	"github.com/shopspring/decimal"
 	"github.com/sirupsen/logrus"
 )
 
@@ -24,9 +24,9 @@ type Order struct {
 	Code 			string
 	OrderId 		string
 	Side 			int64
-	Price 			float64
-	InitQuantity 	float64
-	RemQuantity 	float64
+	Price 			decimal.Decimal
+	InitQuantity 	decimal.Decimal
+	RemQuantity 	decimal.Decimal
 	Status 			int64
 	StatusDesc 		string
 	TxSeqNo 		int64
@@ -34,6 +34,11 @@ type Order struct {
 	Date 			string
 }
 
+//Returns the remaining amount of the order in a unit, see UnitBase and UnitQuote. Quote amounts use the order's own price.
+func (order *Order) Amount(unit string) (decimal.Decimal) {
+	return FromBase(order.RemQuantity, order.Price, unit)
+}
+
 type Orders struct {
 	Asks	map[string]Order
 	Bids 	map[string]Order
@@ -42,34 +47,80 @@ type Orders struct {
 type OrderBook map[string]map[string]*Orders
 
 
-func MarketMaker(gatecoin *api.GatecoinClient, CONFIG *config.Config) {
+func MarketMaker(ctx context.Context, exchange api.Exchange, CONFIG *config.Config) {
 	//load up Bands
 	allBands := make(AllBands)
 	if(!allBands.LoadBands()) {
 		return
 	}
+	MakeMarkets(ctx, exchange, CONFIG, allBands)
+}
+
+//Runs one market making cycle over all active pairs with the given bands, requests end with ctx
+func MakeMarkets(ctx context.Context, exchange api.Exchange, CONFIG *config.Config, allBands AllBands) {
 	//synchronize order book
-	err := SynchronizeOrders(gatecoin)
+	err := SynchronizeOrders(ctx, exchange)
 	if err != nil {
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "error": err.Error()}).Error("Failed to synchronize Orders")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "error": err.Error()}).Error("Failed to synchronize Orders")
 		return
 	}
 	//iterate through active trading pairs
 	for _, tokenPair := range CONFIG.ActivePairs {
+		//stop when the cycle is cancelled or overruns, the remaining pairs keep their orders until the next cycle
+		if ctx.Err() != nil {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "error": ctx.Err().Error()}).Warn("Market making cycle ended early")
+			return
+		}
 		//get reference price
-		refPrice, err := GetFeedPrice(tokenPair, CONFIG)
+		refPrice, priceTime, err := GetTimedFeedPrice(ctx, tokenPair, CONFIG)
+		if err != nil && ctx.Err() != nil {
+			//the feed was cut off with the cycle, not a reason to halt the pair
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "error": ctx.Err().Error()}).Warn("Market making cycle ended early")
+			return
+		}
 		if err != nil {
-			log.WithFields(logrus.Fields{"client": "Gatecoin", "pair": tokenPair, "error": err.Error()}).Error("Failed to get feed price")
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "error": err.Error()}).Error("Failed to get feed price")
+			HaltPair(ctx, exchange, tokenPair, CONFIG, PairStatus{Pair: tokenPair, Halt: HaltNoPrice, Reason: err.Error()})
+			continue
+		}
+		//refuse to quote off an invalid, stale or suspicious price
+		halt, reason := CheckPrice(tokenPair, refPrice, priceTime, CONFIG.PriceGuard, time.Now())
+		if halt == HaltInvalidPrice {
+			//kept out of the price history and the status, which is logged
+			HaltPair(ctx, exchange, tokenPair, CONFIG, PairStatus{Pair: tokenPair, Halt: halt, Reason: reason, PriceTime: priceTime})
+			continue
+		}
+		RecordPrice(tokenPair, refPrice, priceTime)
+		if halt != "" {
+			HaltPair(ctx, exchange, tokenPair, CONFIG, PairStatus{Pair: tokenPair, Halt: halt, Reason: reason, Price: refPrice, PriceTime: priceTime})
 			continue
 		}
-		CancelExcessOrders(gatecoin, allBands[tokenPair].CancellableOrders(GetBuyOrders(tokenPair), GetSellOrders(tokenPair), refPrice))
-		TopUpBands(gatecoin, tokenPair, allBands[tokenPair], refPrice)
-		PrintOrderBook(gatecoin)
+		//the reference price is a float estimate from the feed and price guard, it becomes a decimal once here.
+		//Band edges, order prices and amounts are exact relative to it, the volatility and skew factors
+		//applied to the bands are float estimates too and converted the same way in Band.scaled.
+		bandPrice := decimal.NewFromFloat(refPrice)
+		//adapt bands to volatility and inventory, the same adapted bands decide both cancellations and new orders
+		bands := AdaptMargins(ctx, exchange, tokenPair, allBands[tokenPair], time.Now())
+		bands = SkewBands(ctx, exchange, tokenPair, bands, bandPrice)
+		CancelExcessOrders(ctx, exchange, bands.CancellableOrders(GetBuyOrders(tokenPair), GetSellOrders(tokenPair), bandPrice))
+		TopUpBands(ctx, exchange, tokenPair, bands, bandPrice, CONFIG.GetPairConfig(tokenPair).Risk)
+		setPairStatus(PairStatus{Pair: tokenPair, Quoting: true, Price: refPrice, PriceTime: priceTime, Updated: time.Now()})
+		PrintOrderBook(ctx, exchange)
 	}
 }
 
+//Stops quoting a pair for this cycle, cancelling its orders if the price guard asks for it
+func HaltPair(ctx context.Context, exchange api.Exchange, tokenPair string, CONFIG *config.Config, status PairStatus) {
+	log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "halt": status.Halt, "reason": status.Reason, "price": status.Price, "priceTime": status.PriceTime, "cancelOnHalt": CONFIG.PriceGuard.CancelOnHalt}).Warn("Halted quoting of pair")
+	if CONFIG.PriceGuard.CancelOnHalt {
+		CancelTokenPairOrders(ctx, exchange, tokenPair)
+	}
+	status.Updated = time.Now()
+	setPairStatus(status)
+}
+
 //Updates the in-memory orderbook.
-func SynchronizeOrders(gatecoin *api.GatecoinClient) (error) {
+func SynchronizeOrders(ctx context.Context, exchange api.Exchange) (error) {
 	//reset orderbook
 	for _, quoteMap := range orderBook {
 		for _, orderTypes := range quoteMap {
@@ -80,13 +131,13 @@ func SynchronizeOrders(gatecoin *api.GatecoinClient) (error) {
 		}
 	}
 
-	log.WithFields(logrus.Fields{"client": "Gatecoin"}).Info("Synchronizing orderbook...")
-	resp, err := gatecoin.GetOrders()
+	log.WithFields(logrus.Fields{"client": exchange.GetName()}).Info("Synchronizing orderbook...")
+	resp, err := exchange.GetOrders(ctx)
 	if err != nil {
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "SynchronizeOrders", "error": err.Error()}).Error("Failed to synchronize orders")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "SynchronizeOrders", "error": err.Error()}).Error("Failed to synchronize orders")
 		return err
 	} else if (resp.Status.Message != "OK") {
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "SynchronizeOrders", "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to synchronize orders")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "SynchronizeOrders", "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to synchronize orders")
 		return fmt.Errorf("Failed to synchronize orders due to invalid status message")
 	}
 
@@ -116,117 +167,159 @@ func SynchronizeOrders(gatecoin *api.GatecoinClient) (error) {
 	return nil
 }
 
-func CancelExcessOrders(gatecoin *api.GatecoinClient, ordersToCancel []*Order) {
+func CancelExcessOrders(ctx context.Context, exchange api.Exchange, ordersToCancel []*Order) {
 	for _, order := range ordersToCancel {
-		resp, err := gatecoin.DeleteOrder(order.OrderId)
-		if err != nil {
-			log.WithFields(logrus.Fields{"function": "CancelExcessOrders", "orderId": order.OrderId, "error": err.Error()}).Error("Cancelling order failed")
+		resp, err := exchange.DeleteOrder(ctx, order.OrderId)
+		if errors.Is(err, api.ErrOrderNotFound) {
+			//filled or cancelled since the orderbook was synchronized
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelExcessOrders", "orderId": order.OrderId}).Info("Order already gone")
+			removeOrder(order)
+			continue
+		} else if err != nil {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelExcessOrders", "orderId": order.OrderId, "error": err.Error()}).Error("Cancelling order failed")
+			continue
 		}
 		if resp.Status.ErrorCode != "" || resp.Status.Message != "OK" {
 			log.WithFields(logrus.Fields{"function": "CancelExcessOrders", "orderId": order.OrderId, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Cancelling order failed")
 		} else {
 			log.WithFields(logrus.Fields{"orderId": order.OrderId, "type": order.Side, "price": order.Price, "initialQuantity": order.InitQuantity, "remainingQuantity": order.RemQuantity}).Info("Cancelled Order")
-			//remove order from internal orderbook
-			base, quote := registry.LookupTokenPair(order.Code)
-			if (order.Side == 0) {
-				delete(orderBook[base][quote].Bids, order.OrderId)
-			} else if (order.Side == 1) {
-				delete(orderBook[base][quote].Bids, order.OrderId) 
-			}
+			removeOrder(order)
 		}
 	}
 }
 
-func TopUpBands(gatecoin *api.GatecoinClient, tokenPair string, bands Bands, refPrice float64) {
+//Removes an order from the internal orderbook
+func removeOrder(order *Order) {
+	base, quote := registry.LookupTokenPair(order.Code)
+	if orderBook[base] == nil || orderBook[base][quote] == nil {
+		return
+	}
+	if (order.Side == 0) {
+		delete(orderBook[base][quote].Bids, order.OrderId)
+	} else if (order.Side == 1) {
+		delete(orderBook[base][quote].Asks, order.OrderId)
+	}
+}
+
+func TopUpBands(ctx context.Context, exchange api.Exchange, tokenPair string, bands Bands, refPrice decimal.Decimal, risk config.RiskLimits) {
 	//create new buy and sell orders in all buy/sell bands
-	TopUpBuyBands(gatecoin, tokenPair, GetBuyOrders(tokenPair), bands.BuyBands, refPrice)
-	TopUpSellBands(gatecoin, tokenPair, GetSellOrders(tokenPair), bands.SellBands, refPrice)
+	TopUpBuyBands(ctx, exchange, tokenPair, GetBuyOrders(tokenPair), bands.BuyBands, refPrice, risk)
+	TopUpSellBands(ctx, exchange, tokenPair, GetSellOrders(tokenPair), bands.SellBands, refPrice, risk)
 }
 
-func TopUpBuyBands(client *api.GatecoinClient, tokenPair string, orders []*Order, buyBands []BuyBand, refPrice float64) {
+func TopUpBuyBands(ctx context.Context, exchange api.Exchange, tokenPair string, orders []*Order, buyBands []BuyBand, refPrice decimal.Decimal, risk config.RiskLimits) {
 	//lookup token pair components
 	_, quote := registry.LookupTokenPair(tokenPair)
 	//get balance of quote token
- 	availableBalance, err := client.GetBalance(quote)
- 	if err != nil {
- 		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "TopUpBuyBands", "token": quote, "error": err.Error()}).Error("Failed to get balances")
- 		return
+	availableBalance, err := exchange.GetBalance(ctx, quote)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "TopUpBuyBands", "token": quote, "error": err.Error()}).Error("Failed to get balances")
+		return
 	}
 	availableQuoteBalance := availableBalance.Balance.AvailableBalance
-	precision := registry.LookupGatecoinTokenPairPrecision(tokenPair)
+	precision := exchange.GetTokenPairPrecision(tokenPair)
+	openOrders := len(orders)
 
- 	inBandBuyOrders := []*Order{}
- 	//iterate through buy bands 
- 	for _, buyBand := range buyBands {
- 		//iterate through all buy orders for tokenPair
- 		for _, order := range orders {
- 			//check if buy order is included in band
- 			if buyBand.Includes(order.Price, refPrice) {
- 				//add to in-band buy order list
- 				inBandBuyOrders = append(inBandBuyOrders, order)
- 			}
- 		}
- 		//get total amount of all buy orders in band denominated in quote token except for gatecoin which uses base token
- 		totalAmount := buyBand.TotalAmount(inBandBuyOrders)
- 		//special case code for Gatecoin b/c Gatecoin uses base token for amounts of bids and asks
- 		if (client.Name == "GATECOIN") {
- 			//price denominated in quote / base
-	 		price := buyBand.AvgPrice(refPrice)
-	 		//convert total amount to be denominated in quote token using average price of band (approximation b/c gatecoin sucks)
-	 		totalAmount = price * totalAmount
-	 		//if total order amount is below minimum band threshold
-	 		if (totalAmount < buyBand.MinAmount) {
-	 			//get order parameters
-	 			//amount to pay denominated in quote token
-	 			payAmount := math.Min(buyBand.AvgAmount - totalAmount, availableQuoteBalance)
-	 			//amount to buy denominated in base token
-	 			buyAmount := payAmount / price
-	 			//verify order parameters
-	 			if ((payAmount >= buyBand.DustCutoff) && (payAmount > float64(0)) && (buyAmount > float64(0))) {
-	 				//lookup Gatecoin token pair syntax
-	 				gatecoinTokenPair := registry.LookupGatecoinTokenPairName(tokenPair)
-	 				//adjust amount and price with precision limits for each exchange
-	 				adjustedAmount := strconv.FormatFloat(buyAmount, 'f', precision.BIDAMOUNTPRECISION, 64)
-	 				adjustedPrice := strconv.FormatFloat(price, 'f', precision.BIDPRICEPRECISION, 64)
-	 				potentialRemainingQuoteBalance := availableQuoteBalance - payAmount
-	 				//log attempted order creation
-	 				log.WithFields(logrus.Fields{"client": "Gatecoin", "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "potentialRemainingQuoteBalance": potentialRemainingQuoteBalance}).Info("Creating buy order...")
-	 				//create order - amount denominated in base token
-	 				resp, err := client.CreateOrder(gatecoinTokenPair, "bid", adjustedAmount, adjustedPrice)
-	 				//check if order creation failed
-	 				if err != nil {
-	 					log.WithFields(logrus.Fields{"client": "Gatecoin", "error": err.Error(), "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "potentialRemainingQuoteBalance": potentialRemainingQuoteBalance}).Error("Creating buy order failed")
-	 					continue
-	 				} else if resp.Status.Message != "OK" || resp.OrderId == "" {
-	 					log.WithFields(logrus.Fields{"client": "Gatecoin", "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode, "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "potentialRemainingBalance": potentialRemainingQuoteBalance}).Error("Creating buy order failed")
-	 					continue
-	 				}
-	 				availableQuoteBalance = potentialRemainingQuoteBalance
-	 				//log successful order creation
-	 				log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": resp.OrderId, "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "remainingQuoteBalance": availableQuoteBalance}).Info("Created buy order")
-	 			}
-	 		}
-	 		inBandBuyOrders = nil
-	 	}
- 	}
- 	return
+	inBandBuyOrders := []*Order{}
+	//iterate through buy bands
+	for _, buyBand := range buyBands {
+		//stop once the pair has as many resting bids as allowed
+		if risk.MaxOpenOrders > 0 && openOrders >= risk.MaxOpenOrders {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "openOrders": openOrders, "maxOpenOrders": risk.MaxOpenOrders}).Warn("Maximum open buy orders reached")
+			break
+		}
+		//iterate through all buy orders for tokenPair
+		for _, order := range orders {
+			//check if buy order is included in band
+			if buyBand.Includes(order.Price, refPrice) {
+				//add to in-band buy order list
+				inBandBuyOrders = append(inBandBuyOrders, order)
+			}
+		}
+		//get total amount of all buy orders in band denominated in the band's unit
+		unit := buyBand.GetUnit()
+		totalAmount := buyBand.TotalAmountIn(inBandBuyOrders, unit)
+		//if total order amount is below minimum band threshold
+		if (totalAmount.LessThan(buyBand.MinAmount)) {
+			//get order parameters
+			//amount to add in the band's unit, split over the band's ladder
+			for _, rung := range buyBand.Rungs(buyBand.AvgAmount.Sub(totalAmount), refPrice) {
+				if risk.MaxOpenOrders > 0 && openOrders >= risk.MaxOpenOrders {
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "openOrders": openOrders, "maxOpenOrders": risk.MaxOpenOrders}).Warn("Maximum open buy orders reached")
+					break
+				}
+				//price denominated in quote / base, rounded down to the exchange's precision so the bid pays no more than the band allows
+				price := precision.RoundBidPrice(rung.Price)
+				if !price.IsPositive() || !buyBand.Includes(price, refPrice) {
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "price": rung.Price, "roundedPrice": price}).Warn("Rounded buy price outside of band, skipping order")
+					continue
+				}
+				//amount to buy denominated in base token
+				buyAmount := ToBase(rung.Amount, price, unit)
+				//amount to pay denominated in quote token
+				payAmount := decimal.Min(buyAmount.Mul(price), availableQuoteBalance)
+				buyAmount = payAmount.Div(price)
+				//cap order size
+				if risk.MaxOrderAmount.IsPositive() && buyAmount.GreaterThan(risk.MaxOrderAmount) {
+					buyAmount = risk.MaxOrderAmount
+				}
+				//round down to the exchange's precision so the order never exceeds the balance or the band
+				buyAmount = precision.RoundBidAmount(buyAmount)
+				payAmount = buyAmount.Mul(price)
+				//verify order parameters
+				if (FromBase(buyAmount, price, unit).LessThan(buyBand.DustCutoff) || !payAmount.IsPositive() || !buyAmount.IsPositive()) {
+					continue
+				}
+				//lookup exchange token pair syntax
+				exchangeTokenPair := exchange.GetTokenPairName(tokenPair)
+				potentialRemainingQuoteBalance := availableQuoteBalance.Sub(payAmount)
+				//log attempted order creation
+				log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": exchangeTokenPair, "amount": buyAmount, "price": price, "potentialRemainingQuoteBalance": potentialRemainingQuoteBalance}).Info("Creating buy order...")
+				//create order - amount denominated in base token
+				resp, err := exchange.CreateOrder(ctx, exchangeTokenPair, "bid", buyAmount, price)
+				//check if order creation failed
+				if err != nil {
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "error": err.Error(), "pair": exchangeTokenPair, "amount": buyAmount, "price": price, "potentialRemainingQuoteBalance": potentialRemainingQuoteBalance}).Error("Creating buy order failed")
+					if skipRemainingOrders(exchange, tokenPair, "bid", err) {
+						return
+					}
+					continue
+				} else if resp.Status.Message != "OK" || resp.OrderId == "" {
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode, "pair": exchangeTokenPair, "amount": buyAmount, "price": price, "potentialRemainingBalance": potentialRemainingQuoteBalance}).Error("Creating buy order failed")
+					continue
+				}
+				availableQuoteBalance = potentialRemainingQuoteBalance
+				openOrders++
+				//log successful order creation
+				log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": resp.OrderId, "pair": exchangeTokenPair, "amount": buyAmount, "price": price, "remainingQuoteBalance": availableQuoteBalance}).Info("Created buy order")
+			}
+		}
+		inBandBuyOrders = nil
+	}
+	return
 }
 
-func TopUpSellBands(gatecoin *api.GatecoinClient, tokenPair string, orders []*Order, sellBands []SellBand, refPrice float64) {
+func TopUpSellBands(ctx context.Context, exchange api.Exchange, tokenPair string, orders []*Order, sellBands []SellBand, refPrice decimal.Decimal, risk config.RiskLimits) {
 	//lookup token pair components
 	base, _ := registry.LookupTokenPair(tokenPair)
 	//get balance of base token
-	availableBalance, err := gatecoin.GetBalance(base)
+	availableBalance, err := exchange.GetBalance(ctx, base)
 	if err != nil {
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "TopUpBuyBands", "error": err.Error()}).Error("Failed to get balances")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "TopUpSellBands", "error": err.Error()}).Error("Failed to get balances")
 		return
 	}
 	availableBaseBalance := availableBalance.Balance.AvailableBalance
-	precision := registry.LookupGatecoinTokenPairPrecision(tokenPair)
+	precision := exchange.GetTokenPairPrecision(tokenPair)
+	openOrders := len(orders)
 
 	inBandSellOrders := []*Order{}
  	//iterate through sell bands 
  	for _, sellBand := range sellBands {
+ 		//stop once the pair has as many resting asks as allowed
+ 		if risk.MaxOpenOrders > 0 && openOrders >= risk.MaxOpenOrders {
+ 			log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "openOrders": openOrders, "maxOpenOrders": risk.MaxOpenOrders}).Warn("Maximum open sell orders reached")
+ 			break
+ 		}
  		//iterate through all sell orders
  		for _, order := range orders {
  			//check if sell order is included in band 
@@ -235,125 +328,167 @@ func TopUpSellBands(gatecoin *api.GatecoinClient, tokenPair string, orders []*Or
  				inBandSellOrders = append(inBandSellOrders, order)
  			}
  		}
- 		//get total amount of all sell orders in band
- 		totalAmount := sellBand.TotalAmount(inBandSellOrders)
+ 		//get total amount of all sell orders in band denominated in the band's unit
+ 		unit := sellBand.GetUnit()
+ 		totalAmount := sellBand.TotalAmountIn(inBandSellOrders, unit)
  		//if total order amount is below minimum band threshold
- 		if (totalAmount < sellBand.MinAmount) {
+ 		if (totalAmount.LessThan(sellBand.MinAmount)) {
  			//get order parameters
- 			//price denominated in quote / base
- 			price := sellBand.AvgPrice(refPrice)
- 			//amount to pay denominated in base token
- 			payAmount := math.Min(sellBand.AvgAmount - totalAmount, availableBaseBalance)
- 			//amount to buy denominated in quote token
- 			buyAmount := payAmount * price
- 			//verify order parameters
- 			if ((payAmount >= sellBand.DustCutoff) && (payAmount > float64(0)) && (buyAmount > float64(0))) {
- 				//lookup Gatecoin token pair syntax
- 				gatecoinTokenPair := registry.LookupGatecoinTokenPairName(tokenPair)
- 				//adjust order amount for precision allowed by Gatecoin API
- 				adjustedAmount := strconv.FormatFloat(payAmount, 'f', precision.ASKAMOUNTPRECISION, 64)
- 				//adjust order price for precision allowed by Gatecoin API
- 				adjustedPrice := strconv.FormatFloat(price, 'f', precision.ASKPRICEPRECISION, 64)
+ 			//amount to add in the band's unit, split over the band's ladder
+ 			for _, rung := range sellBand.Rungs(sellBand.AvgAmount.Sub(totalAmount), refPrice) {
+ 				if risk.MaxOpenOrders > 0 && openOrders >= risk.MaxOpenOrders {
+ 					log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "openOrders": openOrders, "maxOpenOrders": risk.MaxOpenOrders}).Warn("Maximum open sell orders reached")
+ 					break
+ 				}
+ 				//price denominated in quote / base, rounded up to the exchange's precision so the ask sells no cheaper than the band allows
+ 				price := precision.RoundAskPrice(rung.Price)
+ 				if !price.IsPositive() || !sellBand.Includes(price, refPrice) {
+ 					log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "price": rung.Price, "roundedPrice": price}).Warn("Rounded sell price outside of band, skipping order")
+ 					continue
+ 				}
+ 				//amount to pay denominated in base token
+ 				payAmount := decimal.Min(ToBase(rung.Amount, price, unit), availableBaseBalance)
+ 				//cap order size
+ 				if risk.MaxOrderAmount.IsPositive() {
+ 					payAmount = decimal.Min(payAmount, risk.MaxOrderAmount)
+ 				}
+ 				//round down to the exchange's precision so the order never exceeds the balance or the band
+ 				payAmount = precision.RoundAskAmount(payAmount)
+ 				//amount to buy denominated in quote token
+ 				buyAmount := payAmount.Mul(price)
+ 				//verify order parameters
+ 				if (FromBase(payAmount, price, unit).LessThan(sellBand.DustCutoff) || !payAmount.IsPositive() || !buyAmount.IsPositive()) {
+ 					continue
+ 				}
+ 				//lookup exchange token pair syntax
+ 				exchangeTokenPair := exchange.GetTokenPairName(tokenPair)
  				//Log order creation
- 				log.WithFields(logrus.Fields{"client": "Gatecoin", "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "potentialRemainingBalance": availableBaseBalance - payAmount}).Info("Creating sell order...")
+ 				log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": exchangeTokenPair, "amount": payAmount, "price": price, "potentialRemainingBalance": availableBaseBalance.Sub(payAmount)}).Info("Creating sell order...")
  				//create order - amount denominated in base token
- 				resp, err := gatecoin.CreateOrder(gatecoinTokenPair, "ask", adjustedAmount, adjustedPrice)
- 				fmt.Printf("%+v\n", resp)
+ 				resp, err := exchange.CreateOrder(ctx, exchangeTokenPair, "ask", payAmount, price)
  				if err != nil {
- 					log.WithFields(logrus.Fields{"client": "Gatecoin", "error": err.Error(), "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "potentialRemainingBalance": availableBaseBalance - payAmount}).Error("Creating sell order failed")
+ 					log.WithFields(logrus.Fields{"client": exchange.GetName(), "error": err.Error(), "pair": exchangeTokenPair, "amount": payAmount, "price": price, "potentialRemainingBalance": availableBaseBalance.Sub(payAmount)}).Error("Creating sell order failed")
+ 					if skipRemainingOrders(exchange, tokenPair, "ask", err) {
+ 						return
+ 					}
  					continue
  				} else if resp.Status.Message != "OK" || resp.OrderId == "" {
- 					log.WithFields(logrus.Fields{"client": "Gatecoin", "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode, "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "potentialRemainingBalance": availableBaseBalance - payAmount}).Error("Creating buy order failed")
+ 					log.WithFields(logrus.Fields{"client": exchange.GetName(), "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode, "pair": exchangeTokenPair, "amount": payAmount, "price": price, "potentialRemainingBalance": availableBaseBalance.Sub(payAmount)}).Error("Creating sell order failed")
  					continue
  				}
- 				availableBaseBalance -= payAmount
- 				log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": resp.OrderId, "pair": gatecoinTokenPair, "amount": adjustedAmount, "price": adjustedPrice, "remainingBalance": availableBaseBalance - payAmount}).Info("Created sell order")
+ 				availableBaseBalance = availableBaseBalance.Sub(payAmount)
+ 				openOrders++
+ 				log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": resp.OrderId, "pair": exchangeTokenPair, "amount": payAmount, "price": price, "remainingBalance": availableBaseBalance}).Info("Created sell order")
  			}
  		}
- 		inBandSellOrders = nil
+		inBandSellOrders = nil
  	}
  	return
 }
 
-func GetFeedPrice(pair string, config *config.Config) (float64, error) {
-	if (strings.ToUpper(pair) == "DAIUSD") {
-		return 1.00, nil
-	} else if (strings.ToUpper(pair) == "ETHDAI") {
-		cmd := config.SetzerPath
-		exchanges := []string{"gemini", "gdax", "kraken"}
-		prices := []float64{}
-		for _, exchange := range exchanges {
-			out, err := exec.Command(cmd, "price", exchange).Output()
-			if err != nil {
-				log.WithFields(logrus.Fields{"function": "GetFeedPrice", "exchange": exchange, "pair": pair, "error": err.Error(), "output": string(out)}).Error("Setzer failed to fetch price")
-        		continue
-    		}
-    		log.WithFields(logrus.Fields{"function": "GetFeedPrice", "exchange": exchange, "pair": pair, "price": string(out)}).Debug("Setzer price fetch returned price")
-    		price, err := strconv.ParseFloat(string(out[:len(out) - 1]), 64)
-    		if err != nil {
-    			log.WithFields(logrus.Fields{"function": "GetFeedPrice", "exchange": exchange, "pair": pair, "price": string(out), "error": err.Error()}).Error("Failed to parse price from string to float")
-    			continue
-    		}
-    		log.WithFields(logrus.Fields{"function": "GetFeedPrice", "exchange": exchange, "pair": pair, "unparsedPrice": string(out), "parsedPrice": price}).Debug("Parsing setzer price returned")
-    		prices = append(prices, price)
-    	}
-    	if (len(prices) == 0) {
-    		log.WithFields(logrus.Fields{"function": "GetFeedPrice", "pair": pair}).Error("No valid price sources")
-    		return 0, fmt.Errorf("No valid price sources")
-    	}
-    	median := GetMedian(prices)
-    	log.WithFields(logrus.Fields{"function": "GetFeedPrice", "prices": prices, "median": median}).Debug("Median price of feed prices is...")
-    	return median, nil
-	} else if (strings.ToUpper(pair) == "MKRBTC") {
-		return 0, fmt.Errorf("No valid price sources")
-	} else if (strings.ToUpper(pair) == "MKRETH") {
-		return 0, fmt.Errorf("No valid price sources")
-	}
-	return 0, fmt.Errorf("no valid price sources\n")
+//Decides how a top-up goes on after the exchange rejected an order, true to skip the side's remaining orders.
+//A rejected price or amount only concerns its own order while a short balance or rejected credentials fail them all.
+func skipRemainingOrders(exchange api.Exchange, tokenPair string, way string, err error) (bool) {
+	switch {
+	case errors.Is(err, api.ErrInsufficientBalance):
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "way": way}).Warn("Insufficient balance, skipping remaining orders")
+		return true
+	case errors.Is(err, api.ErrAuthFailed):
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "way": way}).Error("Credentials rejected, skipping remaining orders")
+		return true
+	case errors.Is(err, api.ErrInvalidPrice), errors.Is(err, api.ErrInvalidAmount):
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "way": way, "precision": exchange.GetTokenPairPrecision(tokenPair)}).Warn("Order rejected, check the token pair's precision in the registry")
+	}
+	return false
 }
 
-func GetMedian(prices []float64) (float64) {
-	sum := 0.0
-	length := len(prices)
-	sort.Float64s(prices)
-	for _, price := range prices[1:(length - 1)] {
-		sum += price
+//Returns the reference price of a token pair from the feed defined in config
+func GetFeedPrice(ctx context.Context, pair string, CONFIG *config.Config) (float64, error) {
+	price, _, err := GetTimedFeedPrice(ctx, pair, CONFIG)
+	return price, err
+}
+
+//Returns the reference price of a token pair and the time of the oldest reading it was derived from
+func GetTimedFeedPrice(ctx context.Context, pair string, CONFIG *config.Config) (float64, time.Time, error) {
+	priceFeed, err := feed.NewPriceFeed(CONFIG.GetPairConfig(pair).Feed, CONFIG)
+	if err != nil {
+		log.WithFields(logrus.Fields{"function": "GetFeedPrice", "pair": pair, "error": err.Error()}).Error("Failed to create price feed")
+		return 0, time.Time{}, err
 	}
-	return sum / float64(length - 2)
+	return priceFeed.GetTimedPrice(ctx)
 }
 
-func CancelAllOrders(gatecoin *api.GatecoinClient) {
-	SynchronizeOrders(gatecoin)
-	log.WithFields(logrus.Fields{"client": "Gatecoin"}).Info("Cancelling all orders...")
+func GetMedian(prices []float64) (float64) {
+	return feed.GetMedian(prices)
+}
+
+func CancelAllOrders(ctx context.Context, exchange api.Exchange) {
+	SynchronizeOrders(ctx, exchange)
+	log.WithFields(logrus.Fields{"client": exchange.GetName()}).Info("Cancelling all orders...")
 	for _, quoteSet := range orderBook {
 		for _, orders := range quoteSet {
 			for id, _ := range orders.Bids {
-				log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": id}).Info("Cancelling order...")
-				resp, err := gatecoin.DeleteOrder(id)
+				log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": id}).Info("Cancelling order...")
+				resp, err := exchange.DeleteOrder(ctx, id)
 				if err != nil {
-					log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+					continue
 				} else if resp.Status.Message != "OK" {
-					log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+					continue
 				}
-				log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": id}).Info("Cancelled Order")
+				log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": id}).Info("Cancelled Order")
 			}
 			for id, _ := range orders.Asks {
-				log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": id}).Info("Cancelling order...")
-				resp, err := gatecoin.DeleteOrder(id)
+				log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": id}).Info("Cancelling order...")
+				resp, err := exchange.DeleteOrder(ctx, id)
 				if err != nil {
-					log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+					continue
 				} else if resp.Status.Message != "OK" {
-					log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+					log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+					continue
 				}
-				log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": id}).Info("Cancelled Order")
+				log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": id}).Info("Cancelled Order")
 			}
 		}
 	}
 }
 
-func CancelTokenPairOrders(gatecoin *api.GatecoinClient, pair string) {
+//Cancels every resting order, retrying until GetOrders confirms none remain or attempts run out
+func CancelAllOrdersAndVerify(ctx context.Context, exchange api.Exchange, attempts int, delay time.Duration) (error) {
+	remaining := -1
+	for attempt := 1; attempt <= attempts; attempt++ {
+		CancelAllOrders(ctx, exchange)
+		resp, err := exchange.GetOrders(ctx)
+		if err != nil {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrdersAndVerify", "attempt": attempt, "error": err.Error()}).Error("Failed to verify open orders")
+		} else if resp.Status.Message != "OK" {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrdersAndVerify", "attempt": attempt, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to verify open orders")
+		} else if len(resp.Orders) == 0 {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "attempt": attempt}).Info("Verified all orders cancelled")
+			return nil
+		} else {
+			remaining = len(resp.Orders)
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrdersAndVerify", "attempt": attempt, "remaining": remaining}).Warn("Orders still open after cancellation")
+		}
+		if attempt < attempts {
+			select {
+			case <-time.After(delay):
+			case <-ctx.Done():
+				return fmt.Errorf("Cancellation of all orders interrupted: %s", ctx.Err().Error())
+			}
+		}
+	}
+	if remaining < 0 {
+		return fmt.Errorf("Unable to verify orders were cancelled after %d attempts", attempts)
+	}
+	return fmt.Errorf("%d orders still open after %d cancellation attempts", remaining, attempts)
+}
+
+func CancelTokenPairOrders(ctx context.Context, exchange api.Exchange, pair string) {
 	base, quote := registry.LookupTokenPair(pair)
-	SynchronizeOrders(gatecoin)
+	SynchronizeOrders(ctx, exchange)
 	//Check if token pair exists in orderbook
 	if _, ok := orderBook[base]; !ok {
     	return
@@ -366,26 +501,30 @@ func CancelTokenPairOrders(gatecoin *api.GatecoinClient, pair string) {
 	//iterate over buy orders
 	for id, _ := range orders.Bids {
 		//cancel buy order
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": id}).Info("Cancelling order...")
-		resp, err := gatecoin.DeleteOrder(id)
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": id}).Info("Cancelling order...")
+		resp, err := exchange.DeleteOrder(ctx, id)
 		if err != nil {
-			log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+			continue
 		} else if resp.Status.Message != "OK" {
-			log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+			continue
 		}
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Info("Cancelled Order")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Info("Cancelled Order")
 	}
 	//iterate over sell orders
 	for id, _ := range orders.Asks {
 		//cancel sell order
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "orderId": id}).Info("Cancelling order...")
-		resp, err := gatecoin.DeleteOrder(id)
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "orderId": id}).Info("Cancelling order...")
+		resp, err := exchange.DeleteOrder(ctx, id)
 		if err != nil {
-			log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "error": err.Error()}).Error("Failed to cancel order")
+			continue
 		} else if resp.Status.Message != "OK" {
-			log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Error("Failed to cancel order")
+			continue
 		}
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Info("Cancelled Order")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "CancelAllOrders", "orderId": id, "message": resp.Status.Message, "errorCode": resp.Status.ErrorCode}).Info("Cancelled Order")
 	}
 }
 
@@ -428,15 +567,15 @@ func GetSellOrders(tokenPair string) (asks []*Order) {
 	return asks
 }
 
-func GetTotalOrderAmount(orders []*Order) (sum float64) {
+func GetTotalOrderAmount(orders []*Order) (sum decimal.Decimal) {
 	for _, order := range orders {
-		sum += order.RemQuantity
+		sum = sum.Add(order.RemQuantity)
 	}
 	return sum
 }
 
-func PrintOrderBook(gatecoin *api.GatecoinClient) (error) {
-	err := SynchronizeOrders(gatecoin)
+func PrintOrderBook(ctx context.Context, exchange api.Exchange) (error) {
+	err := SynchronizeOrders(ctx, exchange)
 	if err != nil {
 		return err
 	}
@@ -444,10 +583,10 @@ func PrintOrderBook(gatecoin *api.GatecoinClient) (error) {
 		for _, orders := range quoteSet {
 			data := [][]string{}
 			for _, order := range orders.Asks {
-				data = append(data, []string{order.Code, "Ask", order.OrderId, strconv.FormatFloat(order.Price, 'f', 6, 64), strconv.FormatFloat(order.InitQuantity, 'f', 6, 64), strconv.FormatFloat(order.RemQuantity, 'f', 6, 64), order.Date})
+				data = append(data, []string{order.Code, "Ask", order.OrderId, order.Price.StringFixed(6), order.InitQuantity.StringFixed(6), order.RemQuantity.StringFixed(6), order.Date})
 			}
 			for _, order := range orders.Bids {
-				data = append(data, []string{order.Code, "Bid", order.OrderId, strconv.FormatFloat(order.Price, 'f', 6, 64), strconv.FormatFloat(order.InitQuantity, 'f', 6, 64), strconv.FormatFloat(order.RemQuantity, 'f', 6, 64), order.Date})
+				data = append(data, []string{order.Code, "Bid", order.OrderId, order.Price.StringFixed(6), order.InitQuantity.StringFixed(6), order.RemQuantity.StringFixed(6), order.Date})
 			}
 			table := tablewriter.NewWriter(os.Stdout)
 			table.SetHeader([]string{"Pair", "Order Type", "Order ID", "Price", "Initial Quantity", "Remaining Quantity", "Timestamp"})
@@ -466,16 +605,16 @@ func PrintOrderBook(gatecoin *api.GatecoinClient) (error) {
 	return nil
 }
 
-func PrintBalances(gatecoin *api.GatecoinClient) (error) {
-	resp, err := gatecoin.GetBalances()
+func PrintBalances(ctx context.Context, exchange api.Exchange) (error) {
+	resp, err := exchange.GetBalances(ctx)
 	if err != nil {
-		log.WithFields(logrus.Fields{"client": "Gatecoin", "error": err}).Error("Failed to query token balances")
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "error": err}).Error("Failed to query token balances")
 		return err
 	}
 	data := [][]string{}
 	
 	for _, balance := range resp.Balances {
-		data = append(data, []string{balance.Currency, strconv.FormatFloat(balance.Balance, 'f', 6, 64), strconv.FormatFloat(balance.AvailableBalance, 'f', 6, 64), strconv.FormatFloat(balance.PendingIncoming, 'f', 6, 64), strconv.FormatFloat(balance.PendingOutgoing, 'f', 6, 64), strconv.FormatFloat(balance.OpenOrder, 'f', 6, 64)})
+		data = append(data, []string{balance.Currency, balance.Balance.StringFixed(6), balance.AvailableBalance.StringFixed(6), balance.PendingIncoming.StringFixed(6), balance.PendingOutgoing.StringFixed(6), balance.OpenOrder.StringFixed(6)})
 	}
 
 	table := tablewriter.NewWriter(os.Stdout)
@@ -1,59 +1,370 @@
 package maker
 
 import(
-	"encoding/json"
-	"io/ioutil"
-	"os"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 	"github.com/stretchr/testify/assert"
 	"github.com/niklaskunkel/market-maker/api"
 	"github.com/niklaskunkel/market-maker/config"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
 )
 
+//Emulator shared by all tests
+var emulator *api.GatecoinEmulator
+
 func SetupGatecoinClient(t *testing.T) (*api.GatecoinClient) {
-	credentials := new(config.Auth)
-	goPath, ok := os.LookupEnv("GOPATH")
-	assert.True(t, ok)
-	credentialsPath := goPath + "/src/github.com/niklaskunkel/market-maker/credentials.json"
-	raw, err := ioutil.ReadFile(credentialsPath)
+	if emulator == nil {
+		emulator = api.NewGatecoinEmulator("key", "secret", map[string]float64{"DAI": 100.0, "USD": 100.0})
+	}
+	return emulator.NewClient()
+}
+
+//Shorthand for decimal literals
+func dec(value float64) (decimal.Decimal) {
+	return decimal.NewFromFloat(value)
+}
+
+//fakeExchange is an in-memory api.Exchange used to drive the maker without a venue
+type fakeExchange struct {
+	orders		[]api.Order
+	balances	map[string]float64
+	created		[]api.NewOrder
+	deleted		[]string
+	failDeletes	int			//Number of DeleteOrder calls to fail before succeeding
+	createErr	error		//Error returned by CreateOrder, orders rejected with it are kept in rejected
+	rejected	[]api.NewOrder
+}
+
+func (fake *fakeExchange) GetName() (string) {
+	return "FAKE"
+}
+
+func (fake *fakeExchange) GetTokenPairName(pair string) (string) {
+	return pair
+}
+
+func (fake *fakeExchange) GetTokenPairPrecision(pair string) (registry.Precision) {
+	return registry.Precision{BIDPRICEPRECISION: 2, ASKPRICEPRECISION: 2, BIDAMOUNTPRECISION: 4, ASKAMOUNTPRECISION: 4}
+}
+
+func (fake *fakeExchange) GetMarketDepth(ctx context.Context, pair string) (*api.MarketDepthResponse, error) {
+	return &api.MarketDepthResponse{Status: api.ResponseStatus{Message: "OK"}}, nil
+}
+
+func (fake *fakeExchange) GetBalances(ctx context.Context) (*api.BalancesResponse, error) {
+	resp := &api.BalancesResponse{Status: api.ResponseStatus{Message: "OK"}}
+	for currency, balance := range fake.balances {
+		resp.Balances = append(resp.Balances, api.Balance{Currency: currency, Balance: dec(balance), AvailableBalance: dec(balance)})
+	}
+	return resp, nil
+}
+
+func (fake *fakeExchange) GetBalance(ctx context.Context, currency string) (*api.BalanceResponse, error) {
+	balance := dec(fake.balances[currency])
+	return &api.BalanceResponse{Balance: api.Balance{Currency: currency, Balance: balance, AvailableBalance: balance}, Status: api.ResponseStatus{Message: "OK"}}, nil
+}
+
+func (fake *fakeExchange) GetOrders(ctx context.Context) (*api.GetOrdersResponse, error) {
+	return &api.GetOrdersResponse{Orders: fake.orders, Status: api.ResponseStatus{Message: "OK"}}, nil
+}
+
+func (fake *fakeExchange) CreateOrder(ctx context.Context, pair string, way string, amount decimal.Decimal, price decimal.Decimal) (*api.CreateOrderResponse, error) {
+	if fake.createErr != nil {
+		fake.rejected = append(fake.rejected, api.NewOrder{Pair: pair, Way: way, Amount: amount.String(), Price: price.String()})
+		return nil, fake.createErr
+	}
+	fake.created = append(fake.created, api.NewOrder{Pair: pair, Way: way, Amount: amount.String(), Price: price.String()})
+	return &api.CreateOrderResponse{OrderId: fmt.Sprintf("FAKE%d", len(fake.created)), Status: api.ResponseStatus{Message: "OK"}}, nil
+}
+
+func (fake *fakeExchange) DeleteOrder(ctx context.Context, id string) (*api.KillOrderResponse, error) {
+	if fake.failDeletes > 0 {
+		fake.failDeletes--
+		return nil, fmt.Errorf("Connection reset")
+	}
+	fake.deleted = append(fake.deleted, id)
+	for i, order := range fake.orders {
+		if order.OrderId == id {
+			fake.orders = append(fake.orders[:i], fake.orders[i+1:]...)
+			break
+		}
+	}
+	return &api.KillOrderResponse{Status: api.ResponseStatus{Message: "OK"}}, nil
+}
+
+//Test orderbook is populated from any exchange implementation
+func Test_Maker_SynchronizeOrdersFake(t *testing.T) {
+	fake := &fakeExchange{orders: []api.Order{
+		api.Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(990.0), InitQuantity: dec(1.0), RemQuantity: dec(1.0)},
+		api.Order{Code: "ETHDAI", OrderId: "BK02", Side: 1, Price: dec(1010.0), InitQuantity: dec(1.0), RemQuantity: dec(0.5)},
+	}}
+	err := SynchronizeOrders(context.Background(), fake)
 	assert.Nil(t, err)
-	err = json.Unmarshal(raw, credentials)
+	assert.Len(t, GetBuyOrders("ETHDAI"), 1)	//one bid in orderbook
+	assert.Len(t, GetSellOrders("ETHDAI"), 1)	//one ask in orderbook
+}
+
+//Test cancelled orders are sent to the exchange and removed from the orderbook
+func Test_Maker_CancelExcessOrdersFake(t *testing.T) {
+	fake := &fakeExchange{orders: []api.Order{
+		api.Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(990.0), InitQuantity: dec(1.0), RemQuantity: dec(1.0)},
+		api.Order{Code: "ETHDAI", OrderId: "BK02", Side: 1, Price: dec(1010.0), InitQuantity: dec(1.0), RemQuantity: dec(0.5)},
+	}}
+	assert.Nil(t, SynchronizeOrders(context.Background(), fake))
+	CancelExcessOrders(context.Background(), fake, append(GetBuyOrders("ETHDAI"), GetSellOrders("ETHDAI")...))
+	assert.ElementsMatch(t, []string{"BK01", "BK02"}, fake.deleted)
+	assert.Empty(t, GetBuyOrders("ETHDAI"))		//bid removed from orderbook
+	assert.Empty(t, GetSellOrders("ETHDAI"))	//ask removed from orderbook
+}
+
+//Test empty bands are topped up on any exchange implementation
+func Test_Maker_TopUpBandsFake(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	assert.Nil(t, SynchronizeOrders(context.Background(), fake))
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+	}
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1000.0), config.RiskLimits{})
+	assert.Len(t, fake.created, 2)
+	assert.Equal(t, api.NewOrder{Pair: "ETHDAI", Way: "bid", Amount: "0.204", Price: "980"}, fake.created[0])	//200 DAI at 980, rounded down
+	assert.Equal(t, api.NewOrder{Pair: "ETHDAI", Way: "ask", Amount: "2", Price: "1020"}, fake.created[1])	//2 ETH at 1020
+}
+
+//Test risk limits cap order size and the number of resting orders
+func Test_Maker_TopUpBandsRiskLimits(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	assert.Nil(t, SynchronizeOrders(context.Background(), fake))
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+	}
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1000.0), config.RiskLimits{MaxOrderAmount: dec(0.1)})
+	assert.Len(t, fake.created, 2)
+	assert.Equal(t, "0.1", fake.created[0].Amount)	//bid capped at 0.1 ETH
+	assert.Equal(t, "0.1", fake.created[1].Amount)	//ask capped at 0.1 ETH
+
+	fake = &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	bid := &Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(900.0), RemQuantity: dec(1.0)}
+	TopUpBuyBands(context.Background(), fake, "ETHDAI", []*Order{bid}, bands.BuyBands, dec(1000.0), config.RiskLimits{MaxOpenOrders: 1})
+	assert.Empty(t, fake.created)	//already one resting bid
+}
+
+//Test laddered bands split the top-up into several orders within the risk limits
+func Test_Maker_TopUpBandsLadder(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	assert.Nil(t, SynchronizeOrders(context.Background(), fake))
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0), Ladder: &Ladder{Orders: 2}}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1), Ladder: &Ladder{Orders: 4}}}},
+	}
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1000.0), config.RiskLimits{MaxOpenOrders: 3})
+	assert.Equal(t, []api.NewOrder{
+		api.NewOrder{Pair: "ETHDAI", Way: "bid", Amount: "0.1015", Price: "985"},	//100 DAI at 985
+		api.NewOrder{Pair: "ETHDAI", Way: "bid", Amount: "0.1025", Price: "975"},	//100 DAI at 975, rounded down
+		api.NewOrder{Pair: "ETHDAI", Way: "ask", Amount: "0.5", Price: "1012.5"},
+		api.NewOrder{Pair: "ETHDAI", Way: "ask", Amount: "0.5", Price: "1017.5"},
+		api.NewOrder{Pair: "ETHDAI", Way: "ask", Amount: "0.5", Price: "1022.5"},	//fourth ask over MaxOpenOrders
+	}, fake.created)
+}
+
+//Test a rejected price only skips its own order while a short balance skips the rest of the side
+func Test_Maker_TopUpBandsRejected(t *testing.T) {
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0), Ladder: &Ladder{Orders: 2}}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1), Ladder: &Ladder{Orders: 3}}}},
+	}
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}, createErr: &api.Error{Code: api.GatecoinErrorInvalidPrice, Message: "Invalid order price"}}
+	assert.Nil(t, SynchronizeOrders(context.Background(), fake))
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1000.0), config.RiskLimits{})
+	assert.Len(t, fake.rejected, 5)
+
+	fake = &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}, createErr: &api.Error{Code: api.GatecoinErrorInsufficientFunds, Message: "Insufficient funds"}}
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1000.0), config.RiskLimits{})
+	assert.Equal(t, []string{"bid", "ask"}, []string{fake.rejected[0].Way, fake.rejected[1].Way})
+	assert.Len(t, fake.rejected, 2)
+}
+
+//Test orders which are already gone are dropped from the orderbook
+func Test_Maker_CancelExcessOrdersGone(t *testing.T) {
+	sim := api.NewSimulatedClient("SIMULATOR", map[string]float64{"ETH": 10.0})
+	created, err := sim.CreateOrder(context.Background(), "ETHDAI", "ask", dec(1), dec(1010))
 	assert.Nil(t, err)
-	client := api.NewGatecoinClient("GATECOIN", credentials.Key, credentials.Secret)
-	return client
+	assert.Nil(t, SynchronizeOrders(context.Background(), sim))
+	sim.DeleteOrder(context.Background(), created.OrderId)
+	CancelExcessOrders(context.Background(), sim, GetSellOrders("ETHDAI"))
+	assert.Empty(t, GetSellOrders("ETHDAI"))
+}
+
+//Test bands declaring their unit are topped up in that unit
+func Test_Maker_TopUpBandsUnits(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1), Unit: UnitBase}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1000.0), AvgAmount: dec(2000.0), MaxAmount: dec(3000.0), DustCutoff: dec(100.0), Unit: UnitQuote}}},
+	}
+	bid := &Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(975.0), RemQuantity: dec(0.5)}
+	TopUpBuyBands(context.Background(), fake, "ETHDAI", []*Order{bid}, bands.BuyBands, dec(1000.0), config.RiskLimits{})
+	ask := &Order{Code: "ETHDAI", OrderId: "BK02", Side: 1, Price: dec(1025.0), RemQuantity: dec(0.8)}	//820 DAI
+	TopUpSellBands(context.Background(), fake, "ETHDAI", []*Order{ask}, bands.SellBands, dec(1000.0), config.RiskLimits{})
+	assert.Equal(t, []api.NewOrder{
+		api.NewOrder{Pair: "ETHDAI", Way: "bid", Amount: "1.5", Price: "980"},	//1.5 ETH tops up to 2 ETH
+		api.NewOrder{Pair: "ETHDAI", Way: "ask", Amount: "1.1568", Price: "1020"},	//1180 DAI tops up to 2000 DAI, rounded down
+	}, fake.created)
+}
+
+//Test prices round away from the reference price and rungs rounded out of their band are skipped
+func Test_Maker_TopUpBandsRounding(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+	}
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1000.005), config.RiskLimits{})
+	assert.Equal(t, []api.NewOrder{
+		api.NewOrder{Pair: "ETHDAI", Way: "bid", Amount: "0.204", Price: "980"},	//980.0049 rounded down
+		api.NewOrder{Pair: "ETHDAI", Way: "ask", Amount: "2", Price: "1020.01"},	//1020.0051 rounded up
+	}, fake.created)
+
+	fake = &fakeExchange{balances: map[string]float64{"ETH": 10.0, "DAI": 10000.0}}
+	bands = Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.001), AvgMargin: dec(0.002), MaxMargin: dec(0.003), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.001), AvgMargin: dec(0.002), MaxMargin: dec(0.003), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+	}
+	TopUpBands(context.Background(), fake, "ETHDAI", bands, dec(1.0), config.RiskLimits{})
+	assert.Empty(t, fake.created)	//0.998 and 1.002 round to 0.99 and 1.01, outside of the bands
+}
+
+//Test failed cancellations are retried until no orders remain
+func Test_Maker_CancelAllOrdersAndVerify(t *testing.T) {
+	fake := &fakeExchange{failDeletes: 1, orders: []api.Order{
+		api.Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(990.0), InitQuantity: dec(1.0), RemQuantity: dec(1.0)},
+		api.Order{Code: "ETHDAI", OrderId: "BK02", Side: 1, Price: dec(1010.0), InitQuantity: dec(1.0), RemQuantity: dec(0.5)},
+	}}
+	err := CancelAllOrdersAndVerify(context.Background(), fake, 3, 0)
+	assert.Nil(t, err)
+	assert.Empty(t, fake.orders)
+	assert.ElementsMatch(t, []string{"BK01", "BK02"}, fake.deleted)
+
+	fake = &fakeExchange{failDeletes: 10, orders: []api.Order{
+		api.Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(990.0), InitQuantity: dec(1.0), RemQuantity: dec(1.0)},
+	}}
+	err = CancelAllOrdersAndVerify(context.Background(), fake, 2, 0)
+	assert.EqualError(t, err, "1 orders still open after 2 cancellation attempts")
+}
+
+//Test cancelling the context ends the cycle's requests
+func Test_Maker_Cancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	fake := &fakeExchange{failDeletes: 10, orders: []api.Order{
+		api.Order{Code: "ETHDAI", OrderId: "BK01", Side: 0, Price: dec(990.0), InitQuantity: dec(1.0), RemQuantity: dec(1.0)},
+	}}
+	err := CancelAllOrdersAndVerify(ctx, fake, 2, time.Hour)
+	assert.EqualError(t, err, "Cancellation of all orders interrupted: context canceled")
+
+	gatecoin := SetupGatecoinClient(t)
+	before, _ := gatecoin.GetOrders(context.Background())
+	configuration := &config.Config{ActivePairs: []string{"DAIUSD"}, Feeds: map[string]config.FeedConfig{
+		"DAIUSD": config.FeedConfig{Sources: []config.SourceConfig{config.SourceConfig{Type: "fixed", Price: 1.2}}, Aggregation: "mean", MinSources: 1},
+	}}
+	allBands := AllBands{"DAIUSD": Bands{
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(10.0), AvgAmount: dec(20.0), MaxAmount: dec(30.0), DustCutoff: dec(1.0)}}},
+	}}
+	MakeMarkets(ctx, gatecoin, configuration, allBands)
+	after, _ := gatecoin.GetOrders(context.Background())
+	assert.Equal(t, before.Orders, after.Orders)
 }
 
 func Test_Maker_SynchronizeOrders1(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
-	err := SynchronizeOrders(gatecoin)
+	err := SynchronizeOrders(context.Background(), gatecoin)
 	assert.Nil(t, err)
 }
 
 func Test_Maker_SynchronizeOrders2(t *testing.T) {
 	gatecoin := SetupGatecoinClient(t)
 	time.Sleep(1000 * time.Millisecond)
-	err := SynchronizeOrders(gatecoin)
+	err := SynchronizeOrders(context.Background(), gatecoin)
 	assert.Nil(t, err)
 	time.Sleep(1000 * time.Millisecond)
-	err = SynchronizeOrders(gatecoin)
+	err = SynchronizeOrders(context.Background(), gatecoin)
 	assert.Nil(t, err)
 }
 
+//Test full market making cycles against the Gatecoin emulator
+func Test_Maker_MakeMarketsEmulator(t *testing.T) {
+	gatecoin := SetupGatecoinClient(t)
+	configuration := &config.Config{ActivePairs: []string{"DAIUSD"}, Feeds: map[string]config.FeedConfig{
+		"DAIUSD": config.FeedConfig{Sources: []config.SourceConfig{config.SourceConfig{Type: "fixed", Price: 1.0}}, Aggregation: "mean", MinSources: 1},
+	}}
+	allBands := AllBands{"DAIUSD": Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(10.0), AvgAmount: dec(20.0), MaxAmount: dec(30.0), DustCutoff: dec(1.0)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(10.0), AvgAmount: dec(20.0), MaxAmount: dec(30.0), DustCutoff: dec(1.0)}}},
+	}}
+	//first cycle tops up empty bands
+	MakeMarkets(context.Background(), gatecoin, configuration, allBands)
+	orders, err := gatecoin.GetOrders(context.Background())
+	assert.Nil(t, err)
+	assert.Len(t, orders.Orders, 2)
+	assert.Equal(t, "0.98", orders.Orders[0].Price.String())				//bid at avgMargin
+	assert.Equal(t, "20.4081632653", orders.Orders[0].RemQuantity.String())	//20 USD worth of DAI
+	assert.Equal(t, "1.02", orders.Orders[1].Price.String())				//ask at avgMargin
+	assert.Equal(t, "20", orders.Orders[1].RemQuantity.String())
+
+	//second cycle cancels order outside of all bands and keeps the rest
+	outside, err := gatecoin.CreateOrder(context.Background(), "DAIUSD", "ask", dec(5), dec(1.5))
+	assert.Nil(t, err)
+	MakeMarkets(context.Background(), gatecoin, configuration, allBands)
+	orders, _ = gatecoin.GetOrders(context.Background())
+	assert.Len(t, orders.Orders, 2)
+	for _, order := range orders.Orders {
+		assert.NotEqual(t, outside.OrderId, order.OrderId)
+	}
+
+	//third cycle replaces filled bid
+	emulator.Exchange.Replay("DAIUSD", []float64{0.975, 1.0})	//market dips through bid and recovers
+	orders, _ = gatecoin.GetOrders(context.Background())
+	assert.Len(t, orders.Orders, 1)
+	MakeMarkets(context.Background(), gatecoin, configuration, allBands)
+	orders, _ = gatecoin.GetOrders(context.Background())
+	assert.Len(t, orders.Orders, 2)
+	balance, _ := gatecoin.GetBalance(context.Background(), "DAI")
+	assert.Equal(t, "120.4081632653", balance.Balance.Balance.String())	//filled bid bought DAI
+}
+
 func Test_Maker_GetFeedPrice1(t *testing.T) {
 	configuration := new(config.Config)
-	config.LoadConfig(configuration)
-	refPrice, err := GetFeedPrice("DAIUSD", configuration)
+	assert.Nil(t, config.LoadConfig(configuration))	//testdata/config.json
+	refPrice, err := GetFeedPrice(context.Background(), "DAIUSD", configuration)
 	assert.Nil(t, err)
 	assert.Equal(t, refPrice, 1.0)
 }
 
+//Test the median of the exchange sources in testdata/config.json, served by a stub instead of the live exchanges
 func Test_Maker_GetFeedPrice2(t *testing.T) {
+	tickers := map[string]string{
+		"/v1/pubticker/ethusd": `{"last":"1000.25","volume":{"timestamp":1515755942000}}`,
+		"/products/ETH-USD/ticker": `{"price":"1001.10","time":"2018-01-12T11:19:02.000Z"}`,
+		"/0/public/Ticker?pair=ETHUSD": `{"error":[],"result":{"XETHZUSD":{"c":["1002.50","0.5"]}}}`,
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(tickers[r.URL.RequestURI()]))
+	}))
+	defer server.Close()
 	configuration := new(config.Config)
-	config.LoadConfig(configuration)
-	refPrice, err := GetFeedPrice("ETHDAI", configuration)
+	assert.Nil(t, config.LoadConfig(configuration))
+	for i, _ := range configuration.Feeds["ETHDAI"].Sources {
+		configuration.Feeds["ETHDAI"].Sources[i].Url = server.URL
+	}
+	refPrice, err := GetFeedPrice(context.Background(), "ETHDAI", configuration)
 	assert.Nil(t, err)
-	assert.NotZero(t, refPrice)
+	assert.Equal(t, 1001.10, refPrice)
 }
 
 func Test_Maker_GetMedian1(t *testing.T) {
@@ -0,0 +1,136 @@
+package maker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+	"github.com/niklaskunkel/market-maker/api"
+	"github.com/niklaskunkel/market-maker/config"
+	"github.com/sirupsen/logrus"
+)
+
+//Snapshot is a validated version of the config and bands. A cycle uses one snapshot from start to end.
+type Snapshot struct {
+	Version		int
+	Config		*config.Config
+	Bands		AllBands
+	LoadedAt	time.Time
+}
+
+//Reloader keeps the last good snapshot and swaps in new versions of the config and bands files once they validate
+type Reloader struct {
+	requireCredentials	bool
+	mutex				sync.Mutex
+	current				*Snapshot
+	modTimes			map[string]time.Time	//Modification times of the files last loaded, valid or not, keyed by path
+}
+
+//Loads the first snapshot, which must be valid
+func NewReloader(requireCredentials bool) (*Reloader, error) {
+	reloader := &Reloader{requireCredentials: requireCredentials}
+	if err := reloader.Reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+//Returns the snapshot to use for the next cycle
+func (reloader *Reloader) Current() (*Snapshot) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	return reloader.current
+}
+
+//Loads and validates the config and bands files. The new version replaces the current snapshot only if
+//both are valid, otherwise the last good snapshot stays in use and the problems are returned.
+func (reloader *Reloader) Reload() (error) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	//a rejected version is not retried until the files change again
+	reloader.modTimes = reloader.readModTimes()
+	CONFIG, err := config.Load(reloader.requireCredentials)
+	if err != nil {
+		return reloader.reject(err)
+	}
+	allBands, err := ReadBands(config.ResolvePath(config.BandsFile))
+	if err != nil {
+		return reloader.reject(err)
+	}
+	version := 1
+	if reloader.current != nil {
+		version = reloader.current.Version + 1
+	}
+	reloader.current = &Snapshot{version, CONFIG, allBands, time.Now()}
+	log.WithFields(logrus.Fields{"version": version, "activePairs": CONFIG.ActivePairs}).Info("Loaded config and bands")
+	return nil
+}
+
+//Logs a rejected version, keeping the current snapshot. Caller must hold mutex.
+func (reloader *Reloader) reject(err error) (error) {
+	if reloader.current == nil {
+		return err
+	}
+	log.WithFields(logrus.Fields{"function": "Reload", "version": reloader.current.Version, "error": err.Error()}).Error("Rejected new config or bands, keeping last good version")
+	return fmt.Errorf("Keeping version %d: %s", reloader.current.Version, err.Error())
+}
+
+//Returns whether the config or bands file changed since they were last loaded
+func (reloader *Reloader) Changed() (bool) {
+	reloader.mutex.Lock()
+	defer reloader.mutex.Unlock()
+	for path, modTime := range reloader.readModTimes() {
+		if !modTime.Equal(reloader.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+//Reloads whenever a file changes, checking every poll, or a signal arrives on hup, until stop is closed
+func (reloader *Reloader) Watch(poll time.Duration, hup <-chan os.Signal, stop <-chan struct{}) {
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case sig := <-hup:
+			log.WithFields(logrus.Fields{"signal": sig.String()}).Info("Reloading config and bands")
+			reloader.Reload()
+		case <-ticker.C:
+			if reloader.Changed() {
+				log.Info("Config or bands file changed, reloading")
+				reloader.Reload()
+			}
+		}
+	}
+}
+
+//Modification times of the config and bands files, missing files are left out. Caller must hold mutex.
+func (reloader *Reloader) readModTimes() (map[string]time.Time) {
+	modTimes := make(map[string]time.Time)
+	for _, filename := range []string{config.ConfigFile, config.BandsFile} {
+		path := config.ResolvePath(filename)
+		if info, err := os.Stat(path); err == nil {
+			modTimes[path] = info.ModTime()
+		}
+	}
+	return modTimes
+}
+
+//Runs one market making cycle with a snapshot and records its version in the status of every pair quoted.
+//CONFIG is the snapshot's config, possibly restricted to the pairs of exchange.
+func MakeMarketsWithSnapshot(ctx context.Context, exchange api.Exchange, CONFIG *config.Config, snapshot *Snapshot) {
+	log.WithFields(logrus.Fields{"client": exchange.GetName(), "version": snapshot.Version, "loadedAt": snapshot.LoadedAt}).Info("Starting market making cycle")
+	MakeMarkets(ctx, exchange, CONFIG, snapshot.Bands)
+	statusMutex.Lock()
+	defer statusMutex.Unlock()
+	for _, tokenPair := range CONFIG.ActivePairs {
+		if status, ok := pairStatus[tokenPair]; ok {
+			status.Version = snapshot.Version
+			pairStatus[tokenPair] = status
+		}
+	}
+}
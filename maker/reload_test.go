@@ -0,0 +1,79 @@
+package maker
+
+import(
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+const reloadConfig = `{"activePairs": ["DAIUSD"], "feeds": {"DAIUSD": {"sources": [{"type": "fixed", "price": 1.0}], "aggregation": "mean", "minSources": 1}}}`
+const reloadBands = `{"DAIUSD": {"buyBands": [{"minMargin": 0.01, "avgMargin": 0.02, "maxMargin": 0.03, "minAmount": 1, "avgAmount": 2, "maxAmount": %s, "dustCutoff": 0.1}], "sellBands": []}}`
+
+//Points the config package at a temporary root holding config.json and bands.json
+func SetupReloadRoot(t *testing.T) (func()) {
+	root, configFile, bandsFile := config.Root, config.ConfigFile, config.BandsFile
+	config.Root, config.ConfigFile, config.BandsFile = t.TempDir(), "config.json", "bands.json"
+	os.WriteFile(filepath.Join(config.Root, "config.json"), []byte(reloadConfig), 0644)
+	writeBands(t, "3")
+	return func() { config.Root, config.ConfigFile, config.BandsFile = root, configFile, bandsFile }
+}
+
+//Number of times bands.json was written, used to give every write a distinct modification time
+var bandsWrites = 0
+
+//Writes bands with a max amount and moves the file's modification time forward
+func writeBands(t *testing.T, maxAmount string) {
+	path := filepath.Join(config.Root, "bands.json")
+	assert.Nil(t, os.WriteFile(path, []byte(fmt.Sprintf(reloadBands, maxAmount)), 0644))
+	bandsWrites++
+	modTime := time.Now().Add(time.Duration(bandsWrites) * time.Second)
+	os.Chtimes(path, modTime, modTime)
+}
+
+//Test an invalid version is rejected and the last good version kept
+func Test_Reload_KeepsLastGoodVersion(t *testing.T) {
+	defer SetupReloadRoot(t)()
+	reloader, err := NewReloader(false)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, reloader.Current().Version)
+	assert.False(t, reloader.Changed())
+
+	writeBands(t, "0.5")	//max amount below min amount
+	assert.True(t, reloader.Changed())
+	err = reloader.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, 1, reloader.Current().Version)
+	assert.Equal(t, "3", reloader.Current().Bands["DAIUSD"].BuyBands[0].MaxAmount.String())
+	assert.False(t, reloader.Changed())	//rejected version is not retried until the next change
+
+	writeBands(t, "4.0")
+	assert.Nil(t, reloader.Reload())
+	assert.Equal(t, 2, reloader.Current().Version)
+	assert.Equal(t, "4", reloader.Current().Bands["DAIUSD"].BuyBands[0].MaxAmount.String())
+}
+
+//Test the first version must be valid
+func Test_Reload_InvalidFirstVersion(t *testing.T) {
+	defer SetupReloadRoot(t)()
+	writeBands(t, "not a number")
+	_, err := NewReloader(false)
+	assert.Error(t, err)
+}
+
+//Test cycles record the version they used
+func Test_Reload_CycleVersion(t *testing.T) {
+	defer SetupReloadRoot(t)()
+	reloader, err := NewReloader(false)
+	assert.Nil(t, err)
+	fake := &fakeExchange{balances: map[string]float64{"DAI": 10.0, "USD": 10.0}}
+	snapshot := reloader.Current()
+	MakeMarketsWithSnapshot(context.Background(), fake, snapshot.Config, snapshot)
+	status, ok := GetPairStatus("DAIUSD")
+	assert.True(t, ok)
+	assert.Equal(t, 1, status.Version)
+}
@@ -0,0 +1,104 @@
+package maker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"github.com/niklaskunkel/market-maker/api"
+	"github.com/niklaskunkel/market-maker/registry"
+	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
+)
+
+//InventorySkew shifts the bands of a pair towards selling down whichever token is held in excess of a target allocation.
+//With too much base token sell margins shrink and sell amounts grow while buy margins grow and buy amounts shrink, and vice versa.
+type InventorySkew struct {
+	TargetBaseRatio	float64	`json:"targetBaseRatio"`	//Target share of the pair's holdings held in base token, valued at the reference price
+	MaxMarginShift	float64	`json:"maxMarginShift"`		//Fraction by which margins shrink or grow at full imbalance, below 1
+	MaxAmountShift	float64	`json:"maxAmountShift"`		//Fraction by which amounts shrink or grow at full imbalance, below 1
+}
+
+func (skew *InventorySkew) Verify() (error) {
+	if skew.TargetBaseRatio <= 0 || skew.TargetBaseRatio >= 1 {
+		return fmt.Errorf("Error: Inventory skew verification failed, TargetBaseRatio(%f) must be between 0 and 1.\n", skew.TargetBaseRatio)
+	}
+	if skew.MaxMarginShift < 0 || skew.MaxMarginShift >= 1 {
+		return fmt.Errorf("Error: Inventory skew verification failed, MaxMarginShift(%f) must be at least 0 and below 1.\n", skew.MaxMarginShift)
+	}
+	if skew.MaxAmountShift < 0 || skew.MaxAmountShift >= 1 {
+		return fmt.Errorf("Error: Inventory skew verification failed, MaxAmountShift(%f) must be at least 0 and below 1.\n", skew.MaxAmountShift)
+	}
+	return nil
+}
+
+//Returns how far holdings are from the target allocation, from -1 when holding only quote token to 1 when holding only base token.
+//0 when on target or holding nothing.
+func (skew *InventorySkew) Imbalance(baseBalance float64, quoteBalance float64, refPrice float64) (float64) {
+	baseValue := baseBalance * refPrice
+	if baseValue + quoteBalance <= 0 {
+		return 0
+	}
+	deviation := baseValue / (baseValue + quoteBalance) - skew.TargetBaseRatio
+	if deviation > 0 {
+		return math.Min(deviation / (1 - skew.TargetBaseRatio), 1)
+	}
+	return math.Max(deviation / skew.TargetBaseRatio, -1)
+}
+
+//Returns a copy of the bands shifted for an imbalance, see Imbalance. The bands themselves are left untouched.
+//Margins and amounts of all bands on a side are scaled by the same factor, so bands which did not overlap still don't.
+func (bands Bands) Skewed(imbalance float64) (Bands) {
+	if bands.InventorySkew == nil || imbalance == 0 {
+		return bands
+	}
+	marginShift := bands.InventorySkew.MaxMarginShift * imbalance
+	amountShift := bands.InventorySkew.MaxAmountShift * imbalance
+	skewed := bands
+	skewed.BuyBands = make([]BuyBand, len(bands.BuyBands))
+	for i, buyBand := range bands.BuyBands {
+		buyBand.Band = buyBand.Band.scaled(1 + marginShift, 1 - amountShift)
+		skewed.BuyBands[i] = buyBand
+	}
+	skewed.SellBands = make([]SellBand, len(bands.SellBands))
+	for i, sellBand := range bands.SellBands {
+		sellBand.Band = sellBand.Band.scaled(1 - marginShift, 1 + amountShift)
+		skewed.SellBands[i] = sellBand
+	}
+	return skewed
+}
+
+//Returns a copy of the band with margins and amounts multiplied by the given factors.
+//The factors are float estimates, the scaled margins and amounts are exact decimals from here on.
+func (band Band) scaled(marginFactor float64, amountFactor float64) (Band) {
+	margin, amount := decimal.NewFromFloat(marginFactor), decimal.NewFromFloat(amountFactor)
+	band.MinMargin = band.MinMargin.Mul(margin)
+	band.AvgMargin = band.AvgMargin.Mul(margin)
+	band.MaxMargin = band.MaxMargin.Mul(margin)
+	band.MinAmount = band.MinAmount.Mul(amount)
+	band.AvgAmount = band.AvgAmount.Mul(amount)
+	band.MaxAmount = band.MaxAmount.Mul(amount)
+	return band
+}
+
+//Returns the bands of a token pair skewed for the balances held on the exchange.
+//The bands are used unskewed if they have no inventory skew or the balances can't be fetched.
+func SkewBands(ctx context.Context, exchange api.Exchange, tokenPair string, bands Bands, refPrice decimal.Decimal) (Bands) {
+	if bands.InventorySkew == nil {
+		return bands
+	}
+	base, quote := registry.LookupTokenPair(tokenPair)
+	baseBalance, err := exchange.GetBalance(ctx, base)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "SkewBands", "token": base, "error": err.Error()}).Error("Failed to get balances, using unskewed bands")
+		return bands
+	}
+	quoteBalance, err := exchange.GetBalance(ctx, quote)
+	if err != nil {
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "SkewBands", "token": quote, "error": err.Error()}).Error("Failed to get balances, using unskewed bands")
+		return bands
+	}
+	//total balances, including what is locked in resting orders, the imbalance is only a ratio so floats are precise enough
+	imbalance := bands.InventorySkew.Imbalance(baseBalance.Balance.Balance.InexactFloat64(), quoteBalance.Balance.Balance.InexactFloat64(), refPrice.InexactFloat64())
+	log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "baseBalance": baseBalance.Balance.Balance, "quoteBalance": quoteBalance.Balance.Balance, "refPrice": refPrice, "targetBaseRatio": bands.InventorySkew.TargetBaseRatio, "imbalance": imbalance}).Info("Skewing bands for inventory")
+	return bands.Skewed(imbalance)
+}
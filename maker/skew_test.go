@@ -0,0 +1,66 @@
+package maker
+
+import(
+	"context"
+	"testing"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/config"
+)
+
+func Test_Skew_Imbalance(t *testing.T) {
+	skew := &InventorySkew{TargetBaseRatio: 0.5}
+	assert.Equal(t, 0.0, skew.Imbalance(1.0, 1000.0, 1000.0))		//even split
+	assert.Equal(t, 1.0, skew.Imbalance(1.0, 0.0, 1000.0))			//base only
+	assert.Equal(t, -1.0, skew.Imbalance(0.0, 1000.0, 1000.0))		//quote only
+	assert.InDelta(t, 0.5, skew.Imbalance(3.0, 1000.0, 1000.0), 1e-9)	//75% base
+	assert.Equal(t, 0.0, skew.Imbalance(0.0, 0.0, 1000.0))			//nothing held
+	skew.TargetBaseRatio = 0.2
+	assert.InDelta(t, -0.5, skew.Imbalance(1.0, 9000.0, 1000.0), 1e-9)	//10% base against 20% target
+}
+
+//Test excess base token tightens and enlarges sell bands and widens and shrinks buy bands
+func Test_Skew_Skewed(t *testing.T) {
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+		InventorySkew: &InventorySkew{TargetBaseRatio: 0.5, MaxMarginShift: 0.5, MaxAmountShift: 0.2},
+	}
+	skewed := bands.Skewed(1.0)
+	assert.InDelta(t, 0.03, skewed.BuyBands[0].AvgMargin.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 160.0, skewed.BuyBands[0].AvgAmount.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 0.01, skewed.SellBands[0].AvgMargin.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 2.4, skewed.SellBands[0].AvgAmount.InexactFloat64(), 1e-9)
+	assert.Equal(t, "0.02", bands.SellBands[0].AvgMargin.String())		//original bands untouched
+	assert.True(t, skewed.VerifyBands())
+
+	skewed = bands.Skewed(-0.5)
+	assert.InDelta(t, 0.015, skewed.BuyBands[0].AvgMargin.InexactFloat64(), 1e-9)
+	assert.InDelta(t, 0.025, skewed.SellBands[0].AvgMargin.InexactFloat64(), 1e-9)
+
+	bands.InventorySkew = nil
+	assert.Equal(t, bands, bands.Skewed(1.0))
+}
+
+func Test_Skew_Verify(t *testing.T) {
+	assert.NoError(t, (&InventorySkew{TargetBaseRatio: 0.5, MaxMarginShift: 0.5}).Verify())
+	assert.Error(t, (&InventorySkew{TargetBaseRatio: 1.0}).Verify())
+	assert.Error(t, (&InventorySkew{TargetBaseRatio: 0.5, MaxMarginShift: 1.0}).Verify())
+	assert.Error(t, (&InventorySkew{TargetBaseRatio: 0.5, MaxAmountShift: -0.1}).Verify())
+}
+
+//Test holding mostly base token quotes asks closer to the reference price
+func Test_Skew_TopUpBands(t *testing.T) {
+	fake := &fakeExchange{balances: map[string]float64{"ETH": 3.0, "DAI": 1000.0}}	//75% ETH at 1000 DAI
+	assert.Nil(t, SynchronizeOrders(context.Background(), fake))
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0)}}},
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+		InventorySkew: &InventorySkew{TargetBaseRatio: 0.5, MaxMarginShift: 0.5, MaxAmountShift: 0.5},
+	}
+	TopUpBands(context.Background(), fake, "ETHDAI", SkewBands(context.Background(), fake, "ETHDAI", bands, dec(1000.0)), dec(1000.0), config.RiskLimits{})
+	assert.Len(t, fake.created, 2)
+	assert.Equal(t, "975", fake.created[0].Price)		//bid margin 2% widened to 2.5%
+	assert.Equal(t, "0.1538", fake.created[0].Amount)	//150 DAI
+	assert.Equal(t, "1015", fake.created[1].Price)		//ask margin 2% tightened to 1.5%
+	assert.Equal(t, "2.5", fake.created[1].Amount)
+}
@@ -0,0 +1,191 @@
+package maker
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+	"github.com/niklaskunkel/market-maker/api"
+	"github.com/sirupsen/logrus"
+)
+
+//Volatility sources
+const (
+	VolatilityFeed		= "feed"	//Reference prices of past cycles
+	VolatilityTrades	= "trades"	//Trade prints of the exchange, see api.TradesExchange
+)
+
+//How long reference prices are kept for volatility estimates
+const MaxPriceHistory = 24 * time.Hour
+
+//AdaptiveMargins scales the margins of every band of a pair by realised volatility,
+//widening them in fast markets and narrowing them in calm ones
+type AdaptiveMargins struct {
+	Source				string	`json:"source"`				//One of the volatility sources, defaults to feed
+	WindowSec			int		`json:"windowSec"`			//Lookback of the realised volatility
+	MinSamples			int		`json:"minSamples"`			//Prices needed within the window, margins are used as configured with fewer
+	TargetVolatility	float64	`json:"targetVolatility"`	//Hourly realised volatility at which margins are used as configured
+	MinFactor			float64	`json:"minFactor"`			//Floor of the margin scaling factor
+	MaxFactor			float64	`json:"maxFactor"`			//Ceiling of the margin scaling factor
+}
+
+//PricePoint is a price observed at a point in time
+type PricePoint struct {
+	Price	float64
+	Time	time.Time
+}
+
+//Globals
+var historyMutex sync.Mutex
+var priceHistory = make(map[string][]PricePoint)
+
+func (adaptive *AdaptiveMargins) GetSource() (string) {
+	if adaptive.Source == "" {
+		return VolatilityFeed
+	}
+	return adaptive.Source
+}
+
+func (adaptive *AdaptiveMargins) Verify() (error) {
+	if adaptive.GetSource() != VolatilityFeed && adaptive.GetSource() != VolatilityTrades {
+		return fmt.Errorf("Error: Adaptive margins verification failed, unknown source %q.\n", adaptive.Source)
+	}
+	if adaptive.WindowSec <= 0 || time.Duration(adaptive.WindowSec) * time.Second > MaxPriceHistory {
+		return fmt.Errorf("Error: Adaptive margins verification failed, WindowSec(%d) must be between 1 and %d.\n", adaptive.WindowSec, int(MaxPriceHistory.Seconds()))
+	}
+	if adaptive.MinSamples < 2 {
+		return fmt.Errorf("Error: Adaptive margins verification failed, MinSamples(%d) must be at least 2.\n", adaptive.MinSamples)
+	}
+	if adaptive.TargetVolatility <= 0 {
+		return fmt.Errorf("Error: Adaptive margins verification failed, TargetVolatility(%f) must be above zero.\n", adaptive.TargetVolatility)
+	}
+	if adaptive.MinFactor <= 0 || adaptive.MinFactor > adaptive.MaxFactor {
+		return fmt.Errorf("Error: Adaptive margins verification failed, MinFactor(%f) > MaxFactor(%f) and must be above zero.\n", adaptive.MinFactor, adaptive.MaxFactor)
+	}
+	return nil
+}
+
+//Returns the margin scaling factor for a realised volatility, bounded by MinFactor and MaxFactor
+func (adaptive *AdaptiveMargins) Factor(volatility float64) (float64) {
+	return math.Max(adaptive.MinFactor, math.Min(adaptive.MaxFactor, volatility / adaptive.TargetVolatility))
+}
+
+//Records a reference price of a token pair, prices not newer than the last one recorded are ignored
+func RecordPrice(pair string, price float64, at time.Time) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	history := priceHistory[pair]
+	if len(history) > 0 && !at.After(history[len(history) - 1].Time) {
+		return
+	}
+	history = append(history, PricePoint{price, at})
+	//drop prices too old to be used
+	first := 0
+	for first < len(history) && at.Sub(history[first].Time) > MaxPriceHistory {
+		first++
+	}
+	priceHistory[pair] = history[first:]
+}
+
+//Returns the recorded reference prices of a token pair since a time, oldest first
+func GetPriceHistory(pair string, since time.Time) ([]PricePoint) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	points := []PricePoint{}
+	for _, point := range priceHistory[pair] {
+		if !point.Time.Before(since) {
+			points = append(points, point)
+		}
+	}
+	return points
+}
+
+//Returns the trade prints of a token pair since a time, oldest first
+func GetTradePrices(ctx context.Context, exchange api.Exchange, tokenPair string, since time.Time) ([]PricePoint, error) {
+	trades, ok := exchange.(api.TradesExchange)
+	if !ok {
+		return nil, fmt.Errorf("Exchange %s does not publish trades", exchange.GetName())
+	}
+	resp, err := trades.GetTransactions(ctx, exchange.GetTokenPairName(tokenPair))
+	if err != nil {
+		return nil, err
+	}
+	points := []PricePoint{}
+	for _, transaction := range resp.Transactions {
+		seconds, err := strconv.ParseInt(transaction.Time, 10, 64)
+		if err != nil || !transaction.Price.IsPositive() {
+			continue
+		}
+		if at := time.Unix(seconds, 0); !at.Before(since) {
+			points = append(points, PricePoint{transaction.Price.InexactFloat64(), at})
+		}
+	}
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+	return points, nil
+}
+
+//Returns the hourly realised volatility of prices ordered oldest first: the square root of the
+//sum of squared log returns scaled from the time they span to an hour. False if they span no time.
+func RealisedVolatility(points []PricePoint) (float64, bool) {
+	if len(points) < 2 {
+		return 0, false
+	}
+	span := points[len(points) - 1].Time.Sub(points[0].Time)
+	if span <= 0 {
+		return 0, false
+	}
+	sumSquares := 0.0
+	for i := 1; i < len(points); i++ {
+		logReturn := math.Log(points[i].Price / points[i-1].Price)
+		sumSquares += logReturn * logReturn
+	}
+	return math.Sqrt(sumSquares * float64(time.Hour) / float64(span)), true
+}
+
+//Returns the bands of a token pair with margins scaled by realised volatility.
+//The bands are used as configured if they have no adaptive margins or too few prices are available.
+func AdaptMargins(ctx context.Context, exchange api.Exchange, tokenPair string, bands Bands, now time.Time) (Bands) {
+	adaptive := bands.AdaptiveMargins
+	if adaptive == nil {
+		return bands
+	}
+	since := now.Add(-time.Duration(adaptive.WindowSec) * time.Second)
+	var points []PricePoint
+	if adaptive.GetSource() == VolatilityTrades {
+		var err error
+		points, err = GetTradePrices(ctx, exchange, tokenPair, since)
+		if err != nil {
+			log.WithFields(logrus.Fields{"client": exchange.GetName(), "function": "AdaptMargins", "pair": tokenPair, "error": err.Error()}).Error("Failed to get trades, using configured margins")
+			return bands
+		}
+	} else {
+		points = GetPriceHistory(tokenPair, since)
+	}
+	volatility, ok := RealisedVolatility(points)
+	if !ok || len(points) < adaptive.MinSamples {
+		log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "source": adaptive.GetSource(), "samples": len(points), "minSamples": adaptive.MinSamples}).Info("Too few prices for volatility, using configured margins")
+		return bands
+	}
+	factor := adaptive.Factor(volatility)
+	log.WithFields(logrus.Fields{"client": exchange.GetName(), "pair": tokenPair, "source": adaptive.GetSource(), "samples": len(points), "volatility": volatility, "targetVolatility": adaptive.TargetVolatility, "factor": factor}).Info("Scaling margins for volatility")
+	return bands.ScaledMargins(factor)
+}
+
+//Returns a copy of the bands with the margins of every band multiplied by factor
+func (bands Bands) ScaledMargins(factor float64) (Bands) {
+	scaled := bands
+	scaled.BuyBands = make([]BuyBand, len(bands.BuyBands))
+	for i, buyBand := range bands.BuyBands {
+		buyBand.Band = buyBand.Band.scaled(factor, 1)
+		scaled.BuyBands[i] = buyBand
+	}
+	scaled.SellBands = make([]SellBand, len(bands.SellBands))
+	for i, sellBand := range bands.SellBands {
+		sellBand.Band = sellBand.Band.scaled(factor, 1)
+		scaled.SellBands[i] = sellBand
+	}
+	return scaled
+}
@@ -0,0 +1,114 @@
+package maker
+
+import(
+	"context"
+	"math"
+	"strconv"
+	"testing"
+	"time"
+	"github.com/stretchr/testify/assert"
+	"github.com/niklaskunkel/market-maker/api"
+)
+
+//fakeTradesExchange also publishes trades
+type fakeTradesExchange struct {
+	fakeExchange
+	transactions	[]api.Transaction
+}
+
+func (fake *fakeTradesExchange) GetTransactions(ctx context.Context, pair string) (*api.TransactionsResponse, error) {
+	return &api.TransactionsResponse{Transactions: fake.transactions, Status: api.ResponseStatus{Message: "OK"}}, nil
+}
+
+func Test_Volatility_RealisedVolatility(t *testing.T) {
+	start := time.Unix(1515755900, 0)
+	points := []PricePoint{
+		PricePoint{100.0, start},
+		PricePoint{101.0, start.Add(15 * time.Minute)},
+		PricePoint{100.0, start.Add(30 * time.Minute)},
+	}
+	volatility, ok := RealisedVolatility(points)
+	assert.True(t, ok)
+	logReturn := math.Log(1.01)
+	assert.InDelta(t, math.Sqrt(2 * logReturn * logReturn * 2), volatility, 1e-9)	//two returns over half an hour
+	_, ok = RealisedVolatility(points[:1])
+	assert.False(t, ok)
+	_, ok = RealisedVolatility([]PricePoint{PricePoint{100.0, start}, PricePoint{101.0, start}})
+	assert.False(t, ok)
+}
+
+func Test_Volatility_Factor(t *testing.T) {
+	adaptive := &AdaptiveMargins{TargetVolatility: 0.01, MinFactor: 0.5, MaxFactor: 3.0}
+	assert.InDelta(t, 2.0, adaptive.Factor(0.02), 1e-9)
+	assert.Equal(t, 3.0, adaptive.Factor(0.1))	//ceiling
+	assert.Equal(t, 0.5, adaptive.Factor(0.0))	//floor
+}
+
+func Test_Volatility_Verify(t *testing.T) {
+	adaptive := AdaptiveMargins{WindowSec: 3600, MinSamples: 5, TargetVolatility: 0.01, MinFactor: 0.5, MaxFactor: 3.0}
+	assert.NoError(t, adaptive.Verify())
+	invalid := adaptive
+	invalid.Source = "orderbook"
+	assert.Error(t, invalid.Verify())
+	invalid = adaptive
+	invalid.MinSamples = 1
+	assert.Error(t, invalid.Verify())
+	invalid = adaptive
+	invalid.MinFactor = 4.0
+	assert.Error(t, invalid.Verify())
+	invalid = adaptive
+	invalid.WindowSec = 2 * 24 * 3600
+	assert.Error(t, invalid.Verify())
+
+	bands := Bands{SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.1), AvgMargin: dec(0.2), MaxMargin: dec(0.4), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}}, AdaptiveMargins: &adaptive}
+	assert.False(t, bands.VerifyBands())	//MaxMargin 0.4 scaled by 3 reaches 1
+	adaptive.MaxFactor = 2.0
+	assert.True(t, bands.VerifyBands())
+}
+
+func Test_Volatility_RecordPrice(t *testing.T) {
+	start := time.Unix(1515755900, 0)
+	RecordPrice("VOLRECORD", 100.0, start)
+	RecordPrice("VOLRECORD", 101.0, start.Add(time.Minute))
+	RecordPrice("VOLRECORD", 102.0, start.Add(time.Minute))	//same time as last price
+	assert.Len(t, GetPriceHistory("VOLRECORD", start), 2)
+	assert.Len(t, GetPriceHistory("VOLRECORD", start.Add(time.Second)), 1)
+	RecordPrice("VOLRECORD", 103.0, start.Add(MaxPriceHistory + 30 * time.Second))
+	assert.Equal(t, []PricePoint{PricePoint{101.0, start.Add(time.Minute)}, PricePoint{103.0, start.Add(MaxPriceHistory + 30 * time.Second)}}, GetPriceHistory("VOLRECORD", start))
+}
+
+//Test margins widen with the volatility of the feed price history
+func Test_Volatility_AdaptMarginsFeed(t *testing.T) {
+	now := time.Now()
+	bands := Bands{
+		SellBands: []SellBand{SellBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(1.0), AvgAmount: dec(2.0), MaxAmount: dec(3.0), DustCutoff: dec(0.1)}}},
+		AdaptiveMargins: &AdaptiveMargins{WindowSec: 3600, MinSamples: 3, TargetVolatility: 0.01, MinFactor: 0.5, MaxFactor: 2.0},
+	}
+	fake := &fakeExchange{}
+	RecordPrice("VOLFEED", 100.0, now.Add(-30 * time.Minute))
+	RecordPrice("VOLFEED", 110.0, now.Add(-20 * time.Minute))
+	assert.Equal(t, bands, AdaptMargins(context.Background(), fake, "VOLFEED", bands, now))	//too few samples
+	RecordPrice("VOLFEED", 100.0, now.Add(-10 * time.Minute))
+	adapted := AdaptMargins(context.Background(), fake, "VOLFEED", bands, now)
+	assert.InDelta(t, 0.04, adapted.SellBands[0].AvgMargin.InexactFloat64(), 1e-9)	//fast market, capped at twice the margin
+	assert.Equal(t, "0.02", bands.SellBands[0].AvgMargin.String())			//configured bands untouched
+}
+
+//Test margins narrow with calm trade prints
+func Test_Volatility_AdaptMarginsTrades(t *testing.T) {
+	now := time.Now()
+	unix := func(ago time.Duration) (string) { return strconv.FormatInt(now.Add(-ago).Unix(), 10) }
+	fake := &fakeTradesExchange{transactions: []api.Transaction{
+		api.Transaction{Time: unix(10 * time.Minute), Price: dec(100.01)},	//newest first as published
+		api.Transaction{Time: unix(20 * time.Minute), Price: dec(100.0)},
+		api.Transaction{Time: unix(30 * time.Minute), Price: dec(100.01)},
+		api.Transaction{Time: unix(3 * time.Hour), Price: dec(50.0)},		//outside window
+	}}
+	bands := Bands{
+		BuyBands: []BuyBand{BuyBand{Band{MinMargin: dec(0.01), AvgMargin: dec(0.02), MaxMargin: dec(0.03), MinAmount: dec(100.0), AvgAmount: dec(200.0), MaxAmount: dec(300.0), DustCutoff: dec(1.0)}}},
+		AdaptiveMargins: &AdaptiveMargins{Source: VolatilityTrades, WindowSec: 3600, MinSamples: 3, TargetVolatility: 0.01, MinFactor: 0.5, MaxFactor: 2.0},
+	}
+	adapted := AdaptMargins(context.Background(), fake, "ETHDAI", bands, now)
+	assert.InDelta(t, 0.01, adapted.BuyBands[0].AvgMargin.InexactFloat64(), 1e-9)		//calm market, floored at half the margin
+	assert.Equal(t, bands, AdaptMargins(context.Background(), &fakeExchange{}, "ETHDAI", bands, now))	//no trades published
+}
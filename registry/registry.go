@@ -2,9 +2,8 @@ package registry
 
 import(
 	"strings"
-	"time"
 	"github.com/niklaskunkel/market-maker/logger"
-	"github.com/sirupsen/logrus"
+	"github.com/shopspring/decimal"
 )
 
 //Globals
@@ -35,11 +34,38 @@ type Precision struct {
 	ASKAMOUNTPRECISION 	int
 }
 
-type ApiTimeout struct {
-	PUBLICTIMEOUT			int64
-	PRIVATETIMEOUT			int64
-	LastPublicExecution 	int64
-	LastPrivateExecution	int64
+//Rounds a bid price down to BIDPRICEPRECISION decimals, so a bid never pays more than its band asks for
+func (precision Precision) RoundBidPrice(price decimal.Decimal) (decimal.Decimal) {
+	return price.RoundFloor(int32(precision.BIDPRICEPRECISION))
+}
+
+//Rounds an ask price up to ASKPRICEPRECISION decimals, so an ask never sells for less than its band asks for
+func (precision Precision) RoundAskPrice(price decimal.Decimal) (decimal.Decimal) {
+	return price.RoundCeil(int32(precision.ASKPRICEPRECISION))
+}
+
+//Rounds a bid amount down to BIDAMOUNTPRECISION decimals, so a bid never costs more than the balance it was sized for
+func (precision Precision) RoundBidAmount(amount decimal.Decimal) (decimal.Decimal) {
+	return amount.RoundFloor(int32(precision.BIDAMOUNTPRECISION))
+}
+
+//Rounds an ask amount down to ASKAMOUNTPRECISION decimals, so an ask never sells more than the balance it was sized for
+func (precision Precision) RoundAskAmount(amount decimal.Decimal) (decimal.Decimal) {
+	return amount.RoundFloor(int32(precision.ASKAMOUNTPRECISION))
+}
+
+//RateBudget is a token bucket: Rate requests per second on average in bursts of up to Burst requests. A zero Rate is unlimited.
+type RateBudget struct {
+	Rate	float64	`json:"rate"`
+	Burst	int		`json:"burst"`
+}
+
+//RateLimits are the request budgets of an exchange API. A request spends from the public or private
+//budget and from the budget of its endpoint, if it has one.
+type RateLimits struct {
+	Public		RateBudget				`json:"public"`
+	Private		RateBudget				`json:"private"`
+	Endpoints	map[string]RateBudget	`json:"endpoints,omitempty"`	//Keyed by endpoint, e.g. Trade/Orders
 }
 //////////////////////////////////////////////////////
 //                   Registry Data                  //
@@ -50,19 +76,28 @@ var TokenPairRegistry = map[string]TokenPair {
 	"ETHDAI": TokenPair{"ETH", "DAI"},
 	"MKRBTC": TokenPair{"MKR", "BTC"},
 	"MKRETH": TokenPair{"MKR", "ETH"},
+	//reference pairs used to derive cross rates
+	"BTCUSD": TokenPair{"BTC", "USD"},
+	"ETHUSD": TokenPair{"ETH", "USD"},
+	"MKRUSD": TokenPair{"MKR", "USD"},
 }
 
 var ExchangeTokenPairRegistry = map[string]ExchangeList {
 	"DAIUSD": ExchangeList{GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "DAIUSD", PRECISION: Precision{BIDPRICEPRECISION: 10, ASKPRICEPRECISION: 10, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}}},
 	"ETHBTC": ExchangeList{GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "ETHBTC", PRECISION: Precision{BIDPRICEPRECISION: 10, ASKPRICEPRECISION: 10, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}}},
-	"ETHDAI": ExchangeList{GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "ETHDAI", PRECISION: Precision{BIDPRICEPRECISION: 2, ASKPRICEPRECISION: 2, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}}},
+	"ETHDAI": ExchangeList{
+		GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "ETHDAI", PRECISION: Precision{BIDPRICEPRECISION: 2, ASKPRICEPRECISION: 2, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}},
+		ETHFINEX: ExchangeTokenInfo{TOKENPAIRNAME: "ETHDAI", PRECISION: Precision{BIDPRICEPRECISION: 2, ASKPRICEPRECISION: 2, BIDAMOUNTPRECISION: 8, ASKAMOUNTPRECISION: 8}}},
 	"MKRBTC": ExchangeList{GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "MKRBTC", PRECISION: Precision{BIDPRICEPRECISION: 10, ASKPRICEPRECISION: 10, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}}},
-	"MKRETH": ExchangeList{GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "MKRETH", PRECISION: Precision{BIDPRICEPRECISION: 10, ASKPRICEPRECISION: 10, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}}},
+	"MKRETH": ExchangeList{
+		GATECOIN: ExchangeTokenInfo{TOKENPAIRNAME: "MKRETH", PRECISION: Precision{BIDPRICEPRECISION: 10, ASKPRICEPRECISION: 10, BIDAMOUNTPRECISION: 10, ASKAMOUNTPRECISION: 10}},
+		ETHFINEX: ExchangeTokenInfo{TOKENPAIRNAME: "MKRETH", PRECISION: Precision{BIDPRICEPRECISION: 5, ASKPRICEPRECISION: 5, BIDAMOUNTPRECISION: 8, ASKAMOUNTPRECISION: 8}}},
 }
 
-var ExchangeApiTimeoutRegistry = map[string]*ApiTimeout {
-	"GATECOIN": &ApiTimeout{PUBLICTIMEOUT: 1000, PRIVATETIMEOUT: 1000, LastPublicExecution: 0, LastPrivateExecution: 0},
-	"ETHFINEX": &ApiTimeout{PUBLICTIMEOUT: 1000, PRIVATETIMEOUT: 1000, LastPublicExecution: 0, LastPrivateExecution: 0},
+//Default request budgets of each supported exchange, overridden by rateLimits in config
+var ExchangeRateLimitRegistry = map[string]RateLimits {
+	"GATECOIN": RateLimits{Public: RateBudget{Rate: 1, Burst: 1}, Private: RateBudget{Rate: 1, Burst: 1}},
+	"ETHFINEX": RateLimits{Public: RateBudget{Rate: 1, Burst: 1}, Private: RateBudget{Rate: 1, Burst: 1}},
 }
 
 //////////////////////////////////////////////////////
@@ -80,60 +115,26 @@ func LookupGatecoinTokenPairPrecision(pair string) (Precision) {
 	return ExchangeTokenPairRegistry[pair].GATECOIN.PRECISION
 }
 
-func MakeTimestamp() (int64) {
-	return time.Now().UnixNano() / int64(time.Millisecond)
+func LookupEthfinexTokenPairName(pair string) (string) {
+	return ExchangeTokenPairRegistry[pair].ETHFINEX.TOKENPAIRNAME
 }
 
-func GetExchangeApiPublicTimeout(exchange string) (int64) {
-	if reg, ok := ExchangeApiTimeoutRegistry[strings.ToUpper(exchange)]; ok {
-		timestamp := MakeTimestamp()
-		timeToSleep := reg.PUBLICTIMEOUT + reg.LastPublicExecution - timestamp
-		log.WithFields(logrus.Fields{"function": "GetExchangeApiPublicTimeout", "exchange": exchange, "lastExecution": reg.LastPublicExecution, "currentTime": timestamp, "interval": reg.PUBLICTIMEOUT, "sleepTime": timeToSleep}).Debug("Getting public timeout")
-		if (timeToSleep <= 0) {
-			return 0
-		} else {
-			return timeToSleep
-		}
-	}
-	log.WithFields(logrus.Fields{"function": "GetExchangeApiPublicTimeout", "exchange": exchange}).Error("Could not find exchange in ApiTimeoutRegistry")
-	return 0
-}
-
-func GetExchangeApiPrivateTimeout(exchange string) (int64) {
-	if reg, ok := ExchangeApiTimeoutRegistry[strings.ToUpper(exchange)]; ok {
-		timestamp := MakeTimestamp()
-    	timeToSleep := reg.PRIVATETIMEOUT + reg.LastPrivateExecution - timestamp
-    	log.WithFields(logrus.Fields{"function": "GetExchangeApiPrivateTimeout", "exchange": exchange, "lastExecution": reg.LastPrivateExecution, "currentTime": timestamp, "interval": reg.PRIVATETIMEOUT, "sleepTime": timeToSleep}).Debug("Getting private timeout")
-		if (timeToSleep <= 0) {
-			return 0
-		} else {
-			return timeToSleep
-		}
-	}
-	log.WithFields(logrus.Fields{"function": "GetExchangeApiPrivateTimeout", "exchange": exchange}).Error("Could not find exchange in ApiTimeoutRegistry")
-	return 0
+func LookupEthfinexTokenPairPrecision(pair string) (Precision) {
+	return ExchangeTokenPairRegistry[pair].ETHFINEX.PRECISION
 }
 
-func SetExchangeApiPublicTimeout(exchange string) {
-	exchange = strings.ToUpper(exchange)
-	if _, ok := ExchangeApiTimeoutRegistry[exchange]; ok {
-		timestamp := MakeTimestamp()
-		log.WithFields(logrus.Fields{"function": "SetExchangeApiPublicTimeout", "exchange": exchange, "time": timestamp}).Debug("Setting public API last execution time")
-		ExchangeApiTimeoutRegistry[exchange].LastPublicExecution = timestamp
-		return
+//Returns the name of a token pair on an exchange, or an empty string if the exchange does not trade it
+func LookupExchangeTokenPairName(exchange string, pair string) (string) {
+	switch strings.ToUpper(exchange) {
+	case "GATECOIN":
+		return LookupGatecoinTokenPairName(pair)
+	case "ETHFINEX":
+		return LookupEthfinexTokenPairName(pair)
 	}
-	log.WithFields(logrus.Fields{"function": "SetExchangeApiPublicTimeout", "exchange": exchange}).Error("Could not find exchange in ApiTimeoutRegistry")
-	return
+	return ""
 }
 
-func SetExchangeApiPrivateTimeout(exchange string) {
-	exchange = strings.ToUpper(exchange)
-	if _, ok := ExchangeApiTimeoutRegistry[exchange]; ok {
-		timestamp := MakeTimestamp()
-		log.WithFields(logrus.Fields{"function": "SetExchangeApiPrivateTimeout", "exchange": exchange, "time": timestamp}).Debug("Setting private API last execution time")
-		ExchangeApiTimeoutRegistry[exchange].LastPrivateExecution = timestamp
-		return
-	}
-	log.WithFields(logrus.Fields{"function": "SetExchangeApiPrivateTimeout", "exchange": exchange}).Error("Could not find exchange in ApiTimeoutRegistry")
-	return
-}
\ No newline at end of file
+//Returns the default request budgets of an exchange, unlimited for unknown exchanges
+func LookupExchangeRateLimits(exchange string) (RateLimits) {
+	return ExchangeRateLimitRegistry[strings.ToUpper(exchange)]
+}